@@ -0,0 +1,416 @@
+package api
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+	"github.com/wanliqun/web-fetcher/fetcher"
+	"github.com/wanliqun/web-fetcher/store"
+	"github.com/wanliqun/web-fetcher/types"
+)
+
+const (
+	// URL path prefix of the API endpoints.
+	pathPrefix = "/api/v1/"
+
+	// HTTP header of the API key, which can also be sent as a bearer token.
+	APIKeyHeader = "X-API-Key"
+)
+
+// JobStatus is the status of a fetch job.
+type JobStatus string
+
+const (
+	JobStatusQueued  JobStatus = "queued"
+	JobStatusRunning JobStatus = "running"
+	// JobStatusDone indicates at least one of the web pages is fetched successfully.
+	JobStatusDone JobStatus = "done"
+	// JobStatusFailed indicates all the web pages failed to be fetched.
+	JobStatusFailed JobStatus = "failed"
+)
+
+// JobRequest is the request body to submit a fetch job.
+type JobRequest struct {
+	// URLs: The web page URLs to fetch.
+	URLs []string `json:",omitempty"`
+	// Requests: The fetch requests with per-request overrides.
+	Requests []*types.FetchRequest `json:",omitempty"`
+}
+
+// Job is a fetch job of one or more web pages.
+type Job struct {
+	ID         string
+	Status     JobStatus
+	Requests   []*types.FetchRequest
+	Results    []*types.FetchRecord `json:",omitempty"`
+	CreatedAt  time.Time
+	StartedAt  *time.Time `json:",omitempty"`
+	FinishedAt *time.Time `json:",omitempty"`
+}
+
+// Config configures the API server.
+type Config struct {
+	// APIKeys: The API keys allowed to access the API.
+	APIKeys []string
+	// QueueSize: The max number of queued jobs, new jobs are rejected if full.
+	QueueSize int
+	// Workers: The number of jobs to run concurrently.
+	Workers int
+	// MaxJobs: The max number of jobs kept in memory for polling, the oldest
+	// finished jobs are evicted first.
+	MaxJobs int
+	// RootDir: The root directory of the store.
+	RootDir string
+	// FetcherOptions: The options to create fetchers for the jobs.
+	FetcherOptions []fetcher.FetcherOption
+}
+
+// Server serves the REST API to submit fetch jobs, poll job status, retrieve
+// metadata and download stored files.
+type Server struct {
+	*Config
+
+	mu     sync.Mutex
+	jobs   map[string]*Job
+	jobIDs []string // job IDs in the order of creation
+	closed bool
+
+	queue      chan *Job
+	wg         sync.WaitGroup
+	fileServer http.Handler
+	// The HTTP client shared by the fetchers of all jobs, so that the politeness
+	// limits apply to the server as a whole.
+	client *fetcher.ThrottleClient
+}
+
+// NewServer creates an API server, and starts the workers to run the jobs.
+func NewServer(config *Config) (*Server, error) {
+	if len(config.APIKeys) == 0 {
+		return nil, errors.New("at least one API key is required")
+	}
+
+	if config.QueueSize <= 0 {
+		config.QueueSize = 100
+	}
+	if config.Workers <= 0 {
+		config.Workers = 4
+	}
+	if config.MaxJobs <= 0 {
+		config.MaxJobs = 1000
+	}
+
+	rootDir := store.ResolveRootDir(config.RootDir)
+	s := &Server{
+		Config: config,
+		jobs:   make(map[string]*Job),
+		queue:  make(chan *Job, config.QueueSize),
+		fileServer: http.StripPrefix(
+			pathPrefix+"files", http.FileServer(http.Dir(rootDir)),
+		),
+		client: fetcher.NewFetcher(config.FetcherOptions...).Client(),
+	}
+
+	for i := 0; i < config.Workers; i++ {
+		s.wg.Add(1)
+		go s.work()
+	}
+
+	return s, nil
+}
+
+// Close stops accepting new jobs, and waits for the queued jobs to finish.
+func (s *Server) Close() {
+	s.mu.Lock()
+	if !s.closed {
+		s.closed = true
+		close(s.queue)
+	}
+	s.mu.Unlock()
+
+	s.wg.Wait()
+}
+
+// ServeHTTP implements the http.Handler interface.
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if !s.authorize(r) {
+		writeError(w, http.StatusUnauthorized, errors.New("invalid API key"))
+		return
+	}
+
+	path := strings.TrimPrefix(r.URL.Path, pathPrefix)
+	switch {
+	case path == "jobs" && r.Method == http.MethodPost:
+		s.submitJob(w, r)
+	case strings.HasPrefix(path, "jobs/") && r.Method == http.MethodGet:
+		s.getJob(w, strings.TrimPrefix(path, "jobs/"))
+	case path == "metadata" && r.Method == http.MethodGet:
+		s.getMetadata(w, r)
+	case path == "document" && r.Method == http.MethodGet:
+		s.getDocument(w, r)
+	case strings.HasPrefix(path, "files/") && r.Method == http.MethodGet:
+		s.fileServer.ServeHTTP(w, r)
+	default:
+		writeError(w, http.StatusNotFound, errors.New("not found"))
+	}
+}
+
+func (s *Server) authorize(r *http.Request) bool {
+	key := r.Header.Get(APIKeyHeader)
+	if len(key) == 0 {
+		key, _ = strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+	}
+
+	if len(key) == 0 {
+		return false
+	}
+
+	for _, apiKey := range s.APIKeys {
+		if subtle.ConstantTimeCompare([]byte(key), []byte(apiKey)) == 1 {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (s *Server) submitJob(w http.ResponseWriter, r *http.Request) {
+	var jobReq JobRequest
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, 1<<20)).Decode(&jobReq); err != nil {
+		writeError(w, http.StatusBadRequest, errors.WithMessage(err, "invalid request body"))
+		return
+	}
+
+	requests := jobReq.Requests
+	for _, u := range jobReq.URLs {
+		requests = append(requests, &types.FetchRequest{URL: u})
+	}
+
+	if len(requests) == 0 {
+		writeError(w, http.StatusBadRequest, errors.New("no URL to fetch"))
+		return
+	}
+
+	for _, req := range requests {
+		if !strings.HasPrefix(req.URL, "http://") && !strings.HasPrefix(req.URL, "https://") {
+			writeError(w, http.StatusBadRequest, errors.Errorf("invalid web URL %v", req.URL))
+			return
+		}
+	}
+
+	job := &Job{
+		ID:        newJobID(),
+		Status:    JobStatusQueued,
+		Requests:  requests,
+		CreatedAt: time.Now(),
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.closed {
+		writeError(w, http.StatusServiceUnavailable, errors.New("server is closed"))
+		return
+	}
+
+	select {
+	case s.queue <- job:
+	default:
+		writeError(w, http.StatusServiceUnavailable, errors.New("job queue is full"))
+		return
+	}
+
+	s.jobs[job.ID] = job
+	s.jobIDs = append(s.jobIDs, job.ID)
+	s.evictJobs()
+
+	writeJSON(w, http.StatusAccepted, job)
+}
+
+// evictJobs evicts the oldest finished jobs if exceeding the max number of jobs.
+func (s *Server) evictJobs() {
+	for i := 0; len(s.jobs) > s.MaxJobs && i < len(s.jobIDs); {
+		job := s.jobs[s.jobIDs[i]]
+		if job.FinishedAt == nil {
+			i++
+			continue
+		}
+
+		delete(s.jobs, job.ID)
+		s.jobIDs = append(s.jobIDs[:i], s.jobIDs[i+1:]...)
+	}
+}
+
+func (s *Server) getJob(w http.ResponseWriter, id string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	job, ok := s.jobs[id]
+	if !ok {
+		writeError(w, http.StatusNotFound, errors.New("job not found"))
+		return
+	}
+
+	writeJSON(w, http.StatusOK, job)
+}
+
+func (s *Server) getMetadata(w http.ResponseWriter, r *http.Request) {
+	fs, ok := s.openFileStore(w, r)
+	if !ok {
+		return
+	}
+
+	metadata, err := fs.LoadMetadata()
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	if metadata == nil {
+		writeError(w, http.StatusNotFound, errors.New("metadata not found"))
+		return
+	}
+
+	writeJSON(w, http.StatusOK, metadata)
+}
+
+func (s *Server) getDocument(w http.ResponseWriter, r *http.Request) {
+	fs, ok := s.openFileStore(w, r)
+	if !ok {
+		return
+	}
+
+	metadata, err := fs.LoadMetadata()
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	// Non-HTML content such as PDFs and images are stored as content files.
+	contentType := "text/html; charset=utf-8"
+	if metadata != nil && !store.IsHTMLType(metadata.ContentType) {
+		contentType = metadata.ContentType
+	}
+
+	content, err := os.ReadFile(fs.DocFilePath(contentType))
+	if os.IsNotExist(err) {
+		writeError(w, http.StatusNotFound, errors.New("document not found"))
+		return
+	}
+
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", contentType)
+	w.Write(content)
+}
+
+// openFileStore opens the file store of the web page URL from the `url` query.
+func (s *Server) openFileStore(w http.ResponseWriter, r *http.Request) (*store.FileStore, bool) {
+	pageURL := r.URL.Query().Get("url")
+	if len(pageURL) == 0 {
+		writeError(w, http.StatusBadRequest, errors.New("missing url query parameter"))
+		return nil, false
+	}
+
+	fs, err := fetcher.OpenFileStore(s.RootDir, pageURL)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return nil, false
+	}
+
+	return fs, true
+}
+
+// work runs the queued jobs until the queue is closed.
+func (s *Server) work() {
+	defer s.wg.Done()
+
+	for job := range s.queue {
+		s.run(job)
+	}
+}
+
+func (s *Server) run(job *Job) {
+	s.mu.Lock()
+	now := time.Now()
+	job.Status, job.StartedAt = JobStatusRunning, &now
+	s.mu.Unlock()
+
+	logger := logrus.WithField("jobID", job.ID)
+	logger.WithField("numPages", len(job.Requests)).Debug("Job started.")
+
+	options := append([]fetcher.FetcherOption{}, s.FetcherOptions...)
+	f := fetcher.NewFetcher(append(options,
+		fetcher.RootDir(s.RootDir), fetcher.HTTPClient(s.client), fetcher.Async())...)
+
+	var numSucceeded int
+	f.OnFetched(func(result *types.FetchResult) {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+
+		if result.Err == nil {
+			numSucceeded++
+		}
+		job.Results = append(job.Results, types.NewFetchRecord(result))
+	})
+
+	for _, req := range job.Requests {
+		// The fetcher canonicalizes the request URL in place, which is copied so
+		// that the job requests are never mutated while being polled.
+		fetchReq := *req
+		if err := f.FetchRequest(&fetchReq); err != nil {
+			now := time.Now()
+			result := &types.FetchResult{
+				URL: req.URL, Request: req, StartedAt: now, FinishedAt: now, Err: err,
+			}
+
+			s.mu.Lock()
+			job.Results = append(job.Results, types.NewFetchRecord(result))
+			s.mu.Unlock()
+		}
+	}
+	f.Wait()
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now = time.Now()
+	job.FinishedAt = &now
+	if numSucceeded > 0 {
+		job.Status = JobStatusDone
+	} else {
+		job.Status = JobStatusFailed
+	}
+
+	logger.WithField("status", job.Status).Debug("Job finished.")
+}
+
+func newJobID() string {
+	b := make([]byte, 8)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+func writeJSON(w http.ResponseWriter, statusCode int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(statusCode)
+
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		logrus.WithError(err).Error("Failed to write JSON response.")
+	}
+}
+
+func writeError(w http.ResponseWriter, statusCode int, err error) {
+	writeJSON(w, statusCode, map[string]string{"Error": err.Error()})
+}
@@ -0,0 +1,101 @@
+package api_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/wanliqun/web-fetcher/api"
+	"github.com/wanliqun/web-fetcher/types"
+)
+
+const testAPIKey = "test-api-key"
+
+func TestServer(t *testing.T) {
+	site := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html")
+		w.Write([]byte(`<html><body><a href="/a">A</a><img src="/b.png"></body></html>`))
+	}))
+	defer site.Close()
+
+	apiServer, err := api.NewServer(&api.Config{
+		APIKeys: []string{testAPIKey}, RootDir: t.TempDir(),
+	})
+	assert.NoError(t, err)
+	defer apiServer.Close()
+
+	server := httptest.NewServer(apiServer)
+	defer server.Close()
+
+	do := func(method, path, apiKey string, body any) (int, []byte) {
+		var reader io.Reader
+		if body != nil {
+			data, err := json.Marshal(body)
+			assert.NoError(t, err)
+			reader = bytes.NewReader(data)
+		}
+
+		req, err := http.NewRequest(method, server.URL+path, reader)
+		assert.NoError(t, err)
+		req.Header.Set("Authorization", "Bearer "+apiKey)
+
+		resp, err := http.DefaultClient.Do(req)
+		assert.NoError(t, err)
+		defer resp.Body.Close()
+
+		data, err := io.ReadAll(resp.Body)
+		assert.NoError(t, err)
+		return resp.StatusCode, data
+	}
+
+	// Unauthorized access should be rejected.
+	status, _ := do(http.MethodGet, "/api/v1/jobs/unknown", "bad-key", nil)
+	assert.Equal(t, http.StatusUnauthorized, status)
+
+	status, _ = do(http.MethodPost, "/api/v1/jobs", testAPIKey, &api.JobRequest{})
+	assert.Equal(t, http.StatusBadRequest, status)
+
+	status, data := do(http.MethodPost, "/api/v1/jobs", testAPIKey, &api.JobRequest{
+		Requests: []*types.FetchRequest{{URL: site.URL + "/page?utm_source=x", Tags: []string{"api"}}},
+	})
+	assert.Equal(t, http.StatusAccepted, status)
+
+	var job api.Job
+	assert.NoError(t, json.Unmarshal(data, &job))
+	assert.NotEmpty(t, job.ID)
+
+	// Poll the job status until finished.
+	assert.Eventually(t, func() bool {
+		status, data := do(http.MethodGet, "/api/v1/jobs/"+job.ID, testAPIKey, nil)
+		assert.Equal(t, http.StatusOK, status)
+		assert.NoError(t, json.Unmarshal(data, &job))
+		return job.FinishedAt != nil
+	}, 5*time.Second, 10*time.Millisecond)
+
+	assert.Equal(t, api.JobStatusDone, job.Status)
+	// The job requests are kept as submitted rather than canonicalized.
+	assert.Equal(t, site.URL+"/page?utm_source=x", job.Requests[0].URL)
+	assert.Len(t, job.Results, 1)
+	assert.Equal(t, site.URL+"/page", job.Results[0].URL)
+	assert.Equal(t, []string{"api"}, job.Results[0].Tags)
+	assert.Equal(t, 1, job.Results[0].Metadata.NumLinks)
+
+	query := "?url=" + url.QueryEscape(site.URL+"/page")
+
+	status, data = do(http.MethodGet, "/api/v1/metadata"+query, testAPIKey, nil)
+	assert.Equal(t, http.StatusOK, status)
+
+	var metadata types.Metadata
+	assert.NoError(t, json.Unmarshal(data, &metadata))
+	assert.Equal(t, 1, metadata.NumImages)
+
+	status, data = do(http.MethodGet, "/api/v1/document"+query, testAPIKey, nil)
+	assert.Equal(t, http.StatusOK, status)
+	assert.Contains(t, string(data), `<a href="/a">A</a>`)
+}
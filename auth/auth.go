@@ -0,0 +1,113 @@
+package auth
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+)
+
+// Authenticator authenticates HTTP requests by the per-host credentials, and
+// runs the form login step of each host once before its first request.
+type Authenticator struct {
+	creds *Credentials
+
+	mu     sync.Mutex
+	logins map[*FormLogin]*loginState
+}
+
+// loginState is the state of a form login step.
+type loginState struct {
+	once sync.Once
+	err  error
+}
+
+// NewAuthenticator creates an authenticator with the credentials.
+func NewAuthenticator(creds *Credentials) *Authenticator {
+	return &Authenticator{creds: creds, logins: make(map[*FormLogin]*loginState)}
+}
+
+// Authorize authorizes the request by the credentials of the request host if
+// any. The form login step is run with the HTTP client, whose cookie jar is
+// expected to capture the session cookies, before the first request to the host.
+func (a *Authenticator) Authorize(ctx context.Context, client *http.Client, req *http.Request) error {
+	creds, ok := a.creds.Lookup(req.URL.Hostname())
+	if !ok {
+		return nil
+	}
+
+	if creds.Login != nil && req.URL.String() != creds.Login.URL {
+		if err := a.login(ctx, client, creds.Login); err != nil {
+			return err
+		}
+	}
+
+	// Explicit authorization of the request takes precedence.
+	if len(req.Header.Get("Authorization")) > 0 {
+		return nil
+	}
+
+	switch {
+	case creds.Basic != nil:
+		req.SetBasicAuth(creds.Basic.Username, string(creds.Basic.Password))
+	case len(creds.Bearer) > 0:
+		req.Header.Set("Authorization", "Bearer "+string(creds.Bearer))
+	}
+
+	return nil
+}
+
+// login runs the form login step once, the error is kept for later requests.
+func (a *Authenticator) login(ctx context.Context, client *http.Client, login *FormLogin) error {
+	a.mu.Lock()
+	state, ok := a.logins[login]
+	if !ok {
+		state = &loginState{}
+		a.logins[login] = state
+	}
+	a.mu.Unlock()
+
+	state.once.Do(func() {
+		state.err = doLogin(ctx, client, login)
+	})
+
+	return state.err
+}
+
+// doLogin posts the login form, the field values are never included in errors
+// or logs.
+func doLogin(ctx context.Context, client *http.Client, login *FormLogin) error {
+	if client.Jar == nil {
+		return errors.New("cookie jar is required for form login")
+	}
+
+	form := url.Values{}
+	for name, val := range login.Fields {
+		form.Set(name, string(val))
+	}
+
+	req, err := http.NewRequestWithContext(
+		ctx, http.MethodPost, login.URL, strings.NewReader(form.Encode()),
+	)
+	if err != nil {
+		return errors.WithMessage(err, "failed to create login request")
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return errors.WithMessage(err, "failed to do login request")
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 399 {
+		return errors.Errorf("login failed with HTTP status code %d", resp.StatusCode)
+	}
+
+	logrus.WithField("URL", login.URL).Debug("Form login done.")
+	return nil
+}
@@ -0,0 +1,171 @@
+package auth_test
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/wanliqun/web-fetcher/auth"
+)
+
+func TestLoadCredentials(t *testing.T) {
+	filePath := filepath.Join(t.TempDir(), "credentials.yaml")
+	assert.NoError(t, os.WriteFile(filePath, []byte(`
+hosts:
+  intranet.example.com:
+    basic:
+      username: alice
+      password: ${INTRANET_PASSWORD}
+  .example.org:
+    bearer: ${API_TOKEN}
+`), 0600))
+
+	env := map[string]string{"INTRANET_PASSWORD": "p@ss: #word"}
+	lookupEnv := func(key string) (string, bool) {
+		val, ok := env[key]
+		return val, ok
+	}
+
+	_, err := auth.LoadCredentials(filePath, lookupEnv)
+	assert.ErrorContains(t, err, "API_TOKEN")
+
+	env["API_TOKEN"] = "secret-token"
+	creds, err := auth.LoadCredentials(filePath, lookupEnv)
+	assert.NoError(t, err)
+
+	c, ok := creds.Lookup("intranet.example.com")
+	assert.True(t, ok)
+	assert.Equal(t, auth.Secret("p@ss: #word"), c.Basic.Password)
+
+	c, ok = creds.Lookup("docs.example.org")
+	assert.True(t, ok)
+	assert.Equal(t, auth.Secret("secret-token"), c.Bearer)
+
+	_, ok = creds.Lookup("example.com")
+	assert.False(t, ok)
+
+	// Secrets are never formatted.
+	for _, format := range []string{"%v", "%+v", "%#v", "%s"} {
+		assert.NotContains(t, fmt.Sprintf(format, c), "secret-token")
+	}
+}
+
+func TestCredentialsLookup(t *testing.T) {
+	creds := &auth.Credentials{Hosts: map[string]*auth.HostCredentials{
+		".example.com":    {Bearer: "outer"},
+		".a.example.com":  {Bearer: "inner"},
+		"b.a.example.com": {Bearer: "exact"},
+	}}
+
+	// Overlapping domains are matched deterministically by the longest one.
+	for i := 0; i < 10; i++ {
+		c, ok := creds.Lookup("x.a.example.com")
+		assert.True(t, ok)
+		assert.Equal(t, auth.Secret("inner"), c.Bearer)
+	}
+
+	c, ok := creds.Lookup("x.example.com")
+	assert.True(t, ok)
+	assert.Equal(t, auth.Secret("outer"), c.Bearer)
+
+	c, ok = creds.Lookup("b.a.example.com")
+	assert.True(t, ok)
+	assert.Equal(t, auth.Secret("exact"), c.Bearer)
+}
+
+func TestJarRejectedCookies(t *testing.T) {
+	jar := auth.NewJar()
+	jar.SetCookies(&url.URL{Scheme: "http", Host: "evil.com", Path: "/"}, []*http.Cookie{
+		{Name: "injected", Value: "1", Domain: "bank.com"},
+		{Name: "suffix", Value: "1", Domain: "com"},
+		{Name: "own", Value: "1", Domain: "evil.com"},
+	})
+
+	// Cookies rejected by the cookie jar are never persisted.
+	cookiesFile := filepath.Join(t.TempDir(), "cookies.txt")
+	assert.NoError(t, jar.SaveFile(cookiesFile))
+
+	content, err := os.ReadFile(cookiesFile)
+	assert.NoError(t, err)
+	assert.NotContains(t, string(content), "injected")
+	assert.NotContains(t, string(content), "suffix")
+	assert.Contains(t, string(content), ".evil.com\tTRUE\t/\tFALSE\t0\town\t1")
+
+	newJar := auth.NewJar()
+	assert.NoError(t, newJar.LoadFile(cookiesFile))
+	assert.Empty(t, newJar.Cookies(&url.URL{Scheme: "http", Host: "bank.com", Path: "/"}))
+}
+
+func TestAuthenticator(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/login":
+			if r.PostFormValue("password") != "secret" {
+				w.WriteHeader(http.StatusForbidden)
+				return
+			}
+			http.SetCookie(w, &http.Cookie{Name: "session", Value: "s1", MaxAge: 3600})
+		case "/page":
+			session, err := r.Cookie("session")
+			user, pass, _ := r.BasicAuth()
+			if err != nil || session.Value != "s1" || user != "alice" || pass != "pw" {
+				w.WriteHeader(http.StatusUnauthorized)
+			}
+		}
+	}))
+	defer server.Close()
+
+	serverURL, _ := url.Parse(server.URL)
+	creds := &auth.Credentials{Hosts: map[string]*auth.HostCredentials{
+		serverURL.Hostname(): {
+			Basic: &auth.BasicAuth{Username: "alice", Password: "pw"},
+			Login: &auth.FormLogin{
+				URL:    server.URL + "/login",
+				Fields: map[string]auth.Secret{"username": "alice", "password": "secret"},
+			},
+		},
+	}}
+
+	jar := auth.NewJar()
+	client := &http.Client{Jar: jar}
+	authenticator := auth.NewAuthenticator(creds)
+
+	req, _ := http.NewRequest(http.MethodGet, server.URL+"/page", nil)
+	assert.NoError(t, authenticator.Authorize(context.Background(), client, req))
+
+	resp, err := client.Do(req)
+	assert.NoError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+
+	// Persist the session cookies and load them into a new jar.
+	cookiesFile := filepath.Join(t.TempDir(), "cookies.txt")
+	assert.NoError(t, jar.SaveFile(cookiesFile))
+
+	content, err := os.ReadFile(cookiesFile)
+	assert.NoError(t, err)
+	assert.True(t, strings.HasPrefix(string(content), "# Netscape HTTP Cookie File"))
+
+	newJar := auth.NewJar()
+	assert.NoError(t, newJar.LoadFile(cookiesFile))
+
+	cookies := newJar.Cookies(&url.URL{Scheme: "http", Host: serverURL.Host, Path: "/page"})
+	assert.Len(t, cookies, 1)
+	assert.Equal(t, "s1", cookies[0].Value)
+
+	// Failed form login fails the requests to the host.
+	creds.Hosts[serverURL.Hostname()].Login.Fields["password"] = "wrong"
+	authenticator = auth.NewAuthenticator(creds)
+
+	req, _ = http.NewRequest(http.MethodGet, server.URL+"/page", nil)
+	err = authenticator.Authorize(context.Background(), &http.Client{Jar: auth.NewJar()}, req)
+	assert.ErrorContains(t, err, "403")
+	assert.NotContains(t, err.Error(), "wrong")
+}
@@ -0,0 +1,156 @@
+package auth
+
+import (
+	"bytes"
+	"os"
+	"regexp"
+	"strings"
+
+	"github.com/pkg/errors"
+	"gopkg.in/yaml.v3"
+)
+
+// redacted is the placeholder of secrets when formatted.
+const redacted = "[REDACTED]"
+
+// envRefPattern matches the `${VAR}` env var references.
+var envRefPattern = regexp.MustCompile(`\$\{([A-Za-z_][A-Za-z0-9_]*)\}`)
+
+// Secret is a sensitive string such as a password or token, which is redacted
+// when formatted or marshaled so that it never gets logged.
+type Secret string
+
+// String implements the fmt.Stringer interface.
+func (s Secret) String() string {
+	return redacted
+}
+
+// GoString implements the fmt.GoStringer interface.
+func (s Secret) GoString() string {
+	return redacted
+}
+
+// MarshalText implements the encoding.TextMarshaler interface.
+func (s Secret) MarshalText() ([]byte, error) {
+	return []byte(redacted), nil
+}
+
+// BasicAuth is the HTTP basic authentication credential.
+type BasicAuth struct {
+	Username string `yaml:"username"`
+	Password Secret `yaml:"password"`
+}
+
+// FormLogin is the scripted form login step, which posts the form fields to the
+// login URL and captures the session cookies before fetching.
+type FormLogin struct {
+	// URL: The URL to post the login form to.
+	URL string `yaml:"url"`
+	// Fields: The form fields such as the username and password.
+	Fields map[string]Secret `yaml:"fields"`
+}
+
+// HostCredentials are the credentials of a host.
+type HostCredentials struct {
+	Basic  *BasicAuth `yaml:"basic"`
+	Bearer Secret     `yaml:"bearer"`
+	Login  *FormLogin `yaml:"login"`
+}
+
+// Credentials are the per-host credentials, keyed by the host name such as
+// `intranet.example.com`, or the domain with a leading dot such as `.example.com`
+// to match all its subdomains.
+type Credentials struct {
+	Hosts map[string]*HostCredentials `yaml:"hosts"`
+}
+
+// LoadCredentials loads the credentials from the YAML file, within which the
+// `${VAR}` references of the values are expanded from the env vars looked up,
+// so that secrets can be kept out of the file.
+func LoadCredentials(filePath string, lookupEnv func(key string) (string, bool)) (*Credentials, error) {
+	data, err := os.ReadFile(filePath)
+	if err != nil {
+		return nil, errors.WithMessage(err, "failed to read credentials file")
+	}
+
+	decoder := yaml.NewDecoder(bytes.NewReader(data))
+	decoder.KnownFields(true)
+
+	var creds Credentials
+	if err := decoder.Decode(&creds); err != nil {
+		return nil, errors.WithMessagef(err, "invalid credentials file %v", filePath)
+	}
+
+	// Expand the env var references after decoding, so that the secrets won't
+	// break the YAML syntax or be quoted by the YAML errors.
+	var missing []string
+	expand := func(s string) string {
+		return envRefPattern.ReplaceAllStringFunc(s, func(ref string) string {
+			key := envRefPattern.FindStringSubmatch(ref)[1]
+			val, ok := lookupEnv(key)
+			if !ok {
+				missing = append(missing, key)
+			}
+			return val
+		})
+	}
+
+	for host, c := range creds.Hosts {
+		if c == nil {
+			return nil, errors.Errorf("credentials of host %v is empty", host)
+		}
+
+		c.Bearer = Secret(expand(string(c.Bearer)))
+
+		if c.Basic != nil {
+			c.Basic.Username = expand(c.Basic.Username)
+			c.Basic.Password = Secret(expand(string(c.Basic.Password)))
+		}
+
+		if c.Login != nil {
+			c.Login.URL = expand(c.Login.URL)
+			if !strings.HasPrefix(c.Login.URL, "http://") && !strings.HasPrefix(c.Login.URL, "https://") {
+				return nil, errors.Errorf("login URL of host %v expected an absolute web URL", host)
+			}
+
+			for name, val := range c.Login.Fields {
+				c.Login.Fields[name] = Secret(expand(string(val)))
+			}
+		}
+	}
+
+	if len(missing) > 0 {
+		return nil, errors.Errorf("env %s referenced by credentials file not set", strings.Join(missing, ", "))
+	}
+
+	return &creds, nil
+}
+
+// Lookup looks up the credentials of the host name, with the exact match
+// preferred over the domain matches, and the longest domain match over others.
+func (c *Credentials) Lookup(hostname string) (*HostCredentials, bool) {
+	if c == nil {
+		return nil, false
+	}
+
+	hostname = strings.ToLower(hostname)
+	if creds, ok := c.Hosts[hostname]; ok {
+		return creds, true
+	}
+
+	// The longest matching domain wins, such as `.a.example.com` over `.example.com`.
+	var matched string
+	var matchedCreds *HostCredentials
+	for host, creds := range c.Hosts {
+		domain := strings.ToLower(host)
+		if !strings.HasPrefix(domain, ".") || len(domain) <= len(matched) {
+			continue
+		}
+
+		if strings.HasSuffix(hostname, domain) || hostname == domain[1:] {
+			matched, matchedCreds = domain, creds
+		}
+	}
+
+	return matchedCreds, matchedCreds != nil
+}
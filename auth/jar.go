@@ -0,0 +1,268 @@
+package auth
+
+import (
+	"bufio"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/cookiejar"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+	"golang.org/x/net/publicsuffix"
+)
+
+// Prefix of the cookies.txt lines for HTTP-only cookies.
+const httpOnlyPrefix = "#HttpOnly_"
+
+// Jar is a cookie jar which can be loaded from and saved to a Netscape
+// cookies.txt file, so that sessions are persisted between runs.
+type Jar struct {
+	*cookiejar.Jar
+
+	mu sync.Mutex
+	// The cookies received, keyed by domain, path and name, as the cookie jar
+	// provides no way to enumerate them for persistence.
+	cookies map[string]*jarCookie
+}
+
+// jarCookie is a cookie along with its domain attributes.
+type jarCookie struct {
+	*http.Cookie
+	// Whether the cookie is sent to the subdomains too.
+	includeSubdomains bool
+}
+
+// NewJar creates an empty cookie jar.
+func NewJar() *Jar {
+	jar, _ := cookiejar.New(&cookiejar.Options{PublicSuffixList: publicsuffix.List})
+	return &Jar{Jar: jar, cookies: make(map[string]*jarCookie)}
+}
+
+// SetCookies implements the http.CookieJar interface. Cookies rejected by the
+// cookie jar are not kept for persistence either.
+func (j *Jar) SetCookies(u *url.URL, cookies []*http.Cookie) {
+	j.Jar.SetCookies(u, cookies)
+
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	for _, c := range cookies {
+		jc := &jarCookie{Cookie: c, includeSubdomains: len(c.Domain) > 0}
+
+		if len(c.Domain) > 0 {
+			ok, hostOnly := checkDomain(u.Hostname(), c.Domain)
+			if !ok {
+				continue
+			}
+
+			if hostOnly {
+				jc.Cookie = copyCookie(c)
+				jc.Domain, jc.includeSubdomains = u.Hostname(), false
+			}
+		}
+
+		// Normalize the domain and path as the cookie jar does.
+		if len(c.Domain) == 0 {
+			jc.Cookie = copyCookie(c)
+			jc.Domain = u.Hostname()
+		}
+		if len(c.Path) == 0 || !strings.HasPrefix(c.Path, "/") {
+			jc.Cookie = copyCookie(jc.Cookie)
+			jc.Path = defaultPath(u.Path)
+		}
+
+		domain := strings.TrimPrefix(strings.ToLower(jc.Domain), ".")
+		key := domain + ";" + jc.Path + ";" + c.Name
+
+		if c.MaxAge < 0 || (!c.Expires.IsZero() && c.Expires.Before(time.Now())) {
+			delete(j.cookies, key)
+			continue
+		}
+
+		if c.MaxAge > 0 {
+			jc.Cookie = copyCookie(jc.Cookie)
+			jc.Expires = time.Now().Add(time.Duration(c.MaxAge) * time.Second)
+		}
+
+		j.cookies[key] = jc
+	}
+}
+
+// checkDomain checks the domain attribute of the cookie set by the host by RFC
+// 6265 as the cookie jar does, such as cookies for other sites or public suffixes
+// are rejected. The domain of IP addresses or public suffixes set by the host
+// itself is host-only.
+func checkDomain(host, domain string) (ok, hostOnly bool) {
+	host = strings.ToLower(host)
+	domain = strings.TrimPrefix(strings.ToLower(domain), ".")
+	if len(domain) == 0 || strings.HasSuffix(domain, ".") {
+		return false, false
+	}
+
+	if net.ParseIP(host) != nil {
+		return host == domain, true
+	}
+
+	if suffix, _ := publicsuffix.PublicSuffix(domain); suffix == domain {
+		return host == domain, true
+	}
+
+	return host == domain || strings.HasSuffix(host, "."+domain), false
+}
+
+func copyCookie(c *http.Cookie) *http.Cookie {
+	cc := *c
+	return &cc
+}
+
+// defaultPath returns the default cookie path of the URL path by RFC 6265.
+func defaultPath(path string) string {
+	if len(path) == 0 || path[0] != '/' {
+		return "/"
+	}
+
+	i := strings.LastIndex(path, "/")
+	if i == 0 {
+		return "/"
+	}
+
+	return path[:i]
+}
+
+// LoadFile loads the cookies from the Netscape cookies.txt file, which is
+// skipped if not existing.
+func (j *Jar) LoadFile(filePath string) error {
+	f, err := os.Open(filePath)
+	if os.IsNotExist(err) {
+		return nil
+	}
+
+	if err != nil {
+		return errors.WithMessage(err, "failed to open cookies file")
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	for lineNo := 1; scanner.Scan(); lineNo++ {
+		line := strings.TrimSpace(scanner.Text())
+
+		httpOnly := strings.HasPrefix(line, httpOnlyPrefix)
+		line = strings.TrimPrefix(line, httpOnlyPrefix)
+
+		if len(line) == 0 || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		// Cookie values are never included in errors so that they won't be logged.
+		fields := strings.Split(line, "\t")
+		if len(fields) != 7 {
+			return errors.Errorf("invalid cookies file line %d: expected 7 tab-separated fields", lineNo)
+		}
+
+		expires, err := strconv.ParseInt(fields[4], 10, 64)
+		if err != nil {
+			return errors.Errorf("invalid cookies file line %d: invalid expiration", lineNo)
+		}
+
+		domain, secure := fields[0], strings.EqualFold(fields[3], "TRUE")
+		cookie := &http.Cookie{
+			Name:     fields[5],
+			Value:    fields[6],
+			Path:     fields[2],
+			Secure:   secure,
+			HttpOnly: httpOnly,
+		}
+
+		// Host-only cookies are set without the domain attribute.
+		if strings.EqualFold(fields[1], "TRUE") {
+			cookie.Domain = domain
+		}
+
+		// Session cookies with zero expiration are kept for the run.
+		if expires > 0 {
+			cookie.Expires = time.Unix(expires, 0)
+		}
+
+		scheme := "http"
+		if secure {
+			scheme = "https"
+		}
+
+		j.SetCookies(&url.URL{
+			Scheme: scheme, Host: strings.TrimPrefix(domain, "."), Path: cookie.Path,
+		}, []*http.Cookie{cookie})
+	}
+
+	if err := scanner.Err(); err != nil {
+		return errors.WithMessage(err, "failed to read cookies file")
+	}
+
+	return nil
+}
+
+// SaveFile saves the unexpired cookies into the Netscape cookies.txt file,
+// which is only readable by the owner.
+func (j *Jar) SaveFile(filePath string) error {
+	j.mu.Lock()
+	keys := make([]string, 0, len(j.cookies))
+	for key := range j.cookies {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	var b strings.Builder
+	b.WriteString("# Netscape HTTP Cookie File\n")
+
+	now := time.Now()
+	for _, key := range keys {
+		c := j.cookies[key]
+		if !c.Expires.IsZero() && c.Expires.Before(now) {
+			continue
+		}
+
+		if c.HttpOnly {
+			b.WriteString(httpOnlyPrefix)
+		}
+
+		domain := strings.TrimPrefix(c.Domain, ".")
+		if c.includeSubdomains {
+			domain = "." + domain
+		}
+
+		var expires int64
+		if !c.Expires.IsZero() {
+			expires = c.Expires.Unix()
+		}
+
+		fmt.Fprintf(&b, "%s\t%s\t%s\t%s\t%d\t%s\t%s\n",
+			domain, boolString(c.includeSubdomains), c.Path, boolString(c.Secure),
+			expires, c.Name, c.Value,
+		)
+	}
+	j.mu.Unlock()
+
+	if err := os.MkdirAll(filepath.Dir(filePath), 0755); err != nil {
+		return errors.WithMessage(err, "failed to create directory")
+	}
+
+	if err := os.WriteFile(filePath, []byte(b.String()), 0600); err != nil {
+		return errors.WithMessage(err, "failed to write cookies file")
+	}
+
+	return nil
+}
+
+func boolString(b bool) string {
+	if b {
+		return "TRUE"
+	}
+	return "FALSE"
+}
@@ -0,0 +1,216 @@
+package cmd
+
+import (
+	"os"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+	"github.com/wanliqun/web-fetcher/auth"
+	"github.com/wanliqun/web-fetcher/config"
+	"github.com/wanliqun/web-fetcher/extract"
+	"github.com/wanliqun/web-fetcher/fetcher"
+)
+
+const (
+	// Env vars of the config file and profile if not specified by flags.
+	envConfigFile = "FETCHER_CONFIG"
+	envProfile    = "FETCHER_PROFILE"
+)
+
+var (
+	// Used for config flags.
+	configFile  string
+	profile     string
+	storeDir    string
+	parallelism int
+	timeout     time.Duration
+	userAgent   string
+	delay       time.Duration
+	credentials string
+	cookies     string
+
+	// Settings resolved from the config file, env vars and flags.
+	settings = config.Default()
+
+	// Cookie jar and authenticator set up from the auth settings if any.
+	cookieJar     *auth.Jar
+	authenticator *auth.Authenticator
+
+	// Custom extraction rules loaded from the rules file if any.
+	extractRules extract.Rules
+)
+
+func init() {
+	rootCmd.PersistentFlags().StringVar(
+		&configFile, "config", "",
+		"Config file in YAML or TOML format (env "+envConfigFile+")",
+	)
+
+	rootCmd.PersistentFlags().StringVar(
+		&profile, "profile", "",
+		"Named profile within the config file (env "+envProfile+")",
+	)
+
+	rootCmd.PersistentFlags().StringVar(
+		&storeDir, "store-dir", "",
+		"Root directory of the file store (env "+config.EnvRootDir+")",
+	)
+
+	rootCmd.PersistentFlags().IntVar(
+		&parallelism, "parallelism", 0,
+		"Max number of concurrent HTTP requests, 0 for unlimited (env "+config.EnvParallelism+")",
+	)
+
+	rootCmd.PersistentFlags().DurationVar(
+		&timeout, "timeout", 0,
+		"Time limit of each HTTP request (env "+config.EnvTimeout+")",
+	)
+
+	rootCmd.PersistentFlags().StringVar(
+		&userAgent, "user-agent", "",
+		"User agent of HTTP requests (env "+config.EnvUserAgent+")",
+	)
+
+	rootCmd.PersistentFlags().DurationVar(
+		&delay, "delay", 0,
+		"Min delay between HTTP requests to the same host (env "+config.EnvDelay+")",
+	)
+
+	rootCmd.PersistentFlags().StringVar(
+		&credentials, "credentials", "",
+		"YAML file of per-host credentials, with ${VAR} expanded from env (env "+config.EnvCredentials+")",
+	)
+
+	rootCmd.PersistentFlags().StringVar(
+		&cookies, "cookies", "",
+		"Netscape cookies.txt file to load cookies from and persist them into (env "+config.EnvCookies+")",
+	)
+
+	rootCmd.PersistentPreRun = func(cmd *cobra.Command, args []string) {
+		setupLogger()
+		loadSettings(cmd.Flags())
+	}
+}
+
+// loadSettings resolves the settings in the precedence of flags, env vars, the
+// selected profile and the base settings of the config file, and then validates
+// them.
+func loadSettings(flags *pflag.FlagSet) {
+	filePath, profileName := configFile, profile
+	if !flags.Changed("config") {
+		filePath = os.Getenv(envConfigFile)
+	}
+	if !flags.Changed("profile") {
+		profileName = os.Getenv(envProfile)
+	}
+
+	cfg, err := config.Load(filePath, profileName)
+	if err != nil {
+		logrus.WithError(err).Fatalln("Failed to load config")
+	}
+
+	if err := cfg.ApplyEnv(os.LookupEnv); err != nil {
+		logrus.WithError(err).Fatalln("Invalid env config")
+	}
+
+	applyFlags(flags, cfg)
+
+	if err := cfg.Validate(); err != nil {
+		logrus.WithError(err).Fatalln("Invalid config")
+	}
+
+	settings = cfg
+	setupAuth()
+	setupExtract()
+}
+
+// setupExtract loads the custom extraction rules from the rules file if any.
+func setupExtract() {
+	if len(settings.Fetcher.Extract) == 0 {
+		return
+	}
+
+	rules, err := extract.LoadRules(settings.Fetcher.Extract)
+	if err != nil {
+		logrus.WithError(err).Fatalln("Failed to load extraction rules")
+	}
+	extractRules = rules
+}
+
+// setupAuth sets up the cookie jar and authenticator from the auth settings.
+func setupAuth() {
+	if len(settings.Auth.CookiesFile) > 0 {
+		cookieJar = auth.NewJar()
+		if err := cookieJar.LoadFile(settings.Auth.CookiesFile); err != nil {
+			logrus.WithError(err).Fatalln("Failed to load cookies")
+		}
+	}
+
+	if len(settings.Auth.CredentialsFile) > 0 {
+		creds, err := auth.LoadCredentials(settings.Auth.CredentialsFile, os.LookupEnv)
+		if err != nil {
+			logrus.WithError(err).Fatalln("Failed to load credentials")
+		}
+
+		// Form login requires a cookie jar to capture the session cookies.
+		if cookieJar == nil {
+			cookieJar = auth.NewJar()
+		}
+		authenticator = auth.NewAuthenticator(creds)
+	}
+}
+
+// authFetcherOptions creates the fetcher options of the cookie jar and
+// authenticator if set up.
+func authFetcherOptions() []fetcher.FetcherOption {
+	var options []fetcher.FetcherOption
+	if cookieJar != nil {
+		options = append(options, fetcher.CookieJar(cookieJar))
+	}
+	if authenticator != nil {
+		options = append(options, fetcher.Auth(authenticator))
+	}
+
+	return options
+}
+
+// saveCookies persists the cookies into the cookies file if specified.
+func saveCookies() {
+	if cookieJar == nil || len(settings.Auth.CookiesFile) == 0 {
+		return
+	}
+
+	if err := cookieJar.SaveFile(settings.Auth.CookiesFile); err != nil {
+		logrus.WithError(err).Error("Failed to save cookies")
+	}
+}
+
+// applyFlags overrides the settings with the flags explicitly set.
+func applyFlags(flags *pflag.FlagSet, cfg *config.Config) {
+	for name, apply := range map[string]func(){
+		"mirror":         func() { cfg.Fetcher.Mirror = mirror },
+		"readable":       func() { cfg.Fetcher.Readable = readable },
+		"snapshot":       func() { cfg.Fetcher.Snapshot = snapshot },
+		"history":        func() { cfg.Fetcher.History = history },
+		"keep-last":      func() { cfg.Fetcher.KeepLast = keepLast },
+		"keep-days":      func() { cfg.Fetcher.KeepDays = keepDays },
+		"normalize-hash": func() { cfg.Fetcher.NormalizeHash = normalizeHash },
+		"volatile":       func() { cfg.Fetcher.Volatile = volatile },
+		"track":          func() { cfg.Fetcher.Track = track },
+		"extract":        func() { cfg.Fetcher.Extract = extractFile },
+		"sanitize":       func() { cfg.Fetcher.Sanitize = sanitize },
+		"store-dir":      func() { cfg.Storage.RootDir = storeDir },
+		"parallelism":    func() { cfg.Client.Parallelism = parallelism },
+		"timeout":        func() { cfg.Client.Timeout = timeout },
+		"user-agent":     func() { cfg.Client.UserAgent = userAgent },
+		"delay":          func() { cfg.Politeness.Delay = delay },
+		"credentials":    func() { cfg.Auth.CredentialsFile = credentials },
+		"cookies":        func() { cfg.Auth.CookiesFile = cookies },
+	} {
+		if flags.Changed(name) {
+			apply()
+		}
+	}
+}
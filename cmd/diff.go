@@ -0,0 +1,130 @@
+package cmd
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+	"github.com/wanliqun/web-fetcher/diff"
+	"github.com/wanliqun/web-fetcher/fetcher"
+	"github.com/wanliqun/web-fetcher/parser"
+	"github.com/wanliqun/web-fetcher/store"
+)
+
+var (
+	// Used for diff flags.
+	diffText    bool
+	diffDOM     bool
+	diffContext int
+
+	diffCmd = &cobra.Command{
+		Use:   "diff [--text] [--dom] [--context <lines>] [--volatile <selector>] <URL> [version1 [version2]]",
+		Short: "Show differences between two snapshot versions of a web page.",
+		Long: "Show differences between two snapshot versions of a web page, " +
+			"which defaults to the last two versions if not specified.",
+		Args: cobra.RangeArgs(1, 3),
+		Run:  runDiff,
+	}
+)
+
+func init() {
+	diffCmd.Flags().BoolVar(
+		&diffText, "text", false, "Show textual diff of the visible text",
+	)
+
+	diffCmd.Flags().BoolVar(
+		&diffDOM, "dom", false, "Show DOM-level diff of the normalized DOM",
+	)
+
+	diffCmd.Flags().IntVarP(
+		&diffContext, "context", "U", 3, "Number of context lines",
+	)
+
+	rootCmd.AddCommand(diffCmd)
+}
+
+func runDiff(cmd *cobra.Command, args []string) {
+	// Show both textual and DOM-level diffs by default.
+	if !diffText && !diffDOM {
+		diffText, diffDOM = true, true
+	}
+
+	// The file store is opened by the canonical URL as the fetcher stores it.
+	fileStore, err := fetcher.OpenFileStore(settings.Storage.RootDir, args[0])
+	if err != nil {
+		logrus.WithField("URL", args[0]).WithError(err).Fatalln("Failed to open file store")
+	}
+
+	oldVersion, newVersion, err := resolveDiffVersions(fileStore, args[1:])
+	if err != nil {
+		logrus.WithField("URL", args[0]).WithError(err).Fatalln("Failed to resolve versions")
+	}
+
+	oldParser, err := loadSnapshotParser(fileStore, oldVersion)
+	if err != nil {
+		logrus.WithField("version", oldVersion).WithError(err).Fatalln("Failed to load snapshot")
+	}
+
+	newParser, err := loadSnapshotParser(fileStore, newVersion)
+	if err != nil {
+		logrus.WithField("version", newVersion).WithError(err).Fatalln("Failed to load snapshot")
+	}
+
+	volatileSelectors := settings.Fetcher.Volatile
+
+	if diffText {
+		edits := diff.Lines(oldParser.TextLines(volatileSelectors...), newParser.TextLines(volatileSelectors...))
+		fmt.Print(diff.Unified(
+			oldVersion+" (text)", newVersion+" (text)", edits, diffContext,
+		))
+	}
+
+	if diffDOM {
+		edits := diff.Lines(oldParser.DOMLines(volatileSelectors...), newParser.DOMLines(volatileSelectors...))
+		fmt.Print(diff.Unified(
+			oldVersion+" (DOM)", newVersion+" (DOM)", edits, diffContext,
+		))
+	}
+}
+
+// resolveDiffVersions resolves the versions to compare, which defaults to the
+// last two versions.
+func resolveDiffVersions(fs *store.FileStore, versions []string) (string, string, error) {
+	if len(versions) == 2 {
+		return versions[0], versions[1], nil
+	}
+
+	snapshots, err := fs.ListSnapshots()
+	if err != nil {
+		return "", "", errors.WithMessage(err, "failed to list snapshots")
+	}
+
+	if len(versions) == 1 { // Compare against the latest version.
+		if len(snapshots) == 0 {
+			return "", "", errors.New("no snapshot found")
+		}
+		return versions[0], snapshots[len(snapshots)-1], nil
+	}
+
+	if len(snapshots) < 2 {
+		return "", "", errors.Errorf("expected at least 2 snapshots got %d", len(snapshots))
+	}
+
+	return snapshots[len(snapshots)-2], snapshots[len(snapshots)-1], nil
+}
+
+func loadSnapshotParser(fs *store.FileStore, version string) (*parser.Parser, error) {
+	content, err := fs.LoadSnapshot(version)
+	if os.IsNotExist(err) {
+		return nil, errors.Errorf("snapshot version %v not found", version)
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	return parser.NewParser(bytes.NewReader(content))
+}
@@ -0,0 +1,108 @@
+package cmd
+
+import (
+	"context"
+	"regexp"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+	"github.com/wanliqun/web-fetcher/discovery"
+	"github.com/wanliqun/web-fetcher/types"
+)
+
+var (
+	sitemaps []string
+	feeds    []string
+	since    string
+	match    string
+)
+
+func init() {
+	rootCmd.Flags().StringSliceVar(
+		&sitemaps, "sitemap", nil,
+		"Fetch the URLs of the sitemap, or the sitemaps discovered from robots.txt if a site root URL",
+	)
+
+	rootCmd.Flags().StringSliceVar(
+		&feeds, "feed", nil,
+		"Fetch the URLs of the RSS or Atom feed",
+	)
+
+	rootCmd.Flags().StringVar(
+		&since, "since", "",
+		"Only fetch sitemap or feed URLs modified since the date (e.g. 2024-01-31) or duration ago (e.g. 72h)",
+	)
+
+	rootCmd.Flags().StringVar(
+		&match, "match", "",
+		"Only fetch sitemap or feed URLs matching the regular expression",
+	)
+}
+
+// newDiscoveryFilter creates the discovery filter by the --since and --match flags.
+func newDiscoveryFilter() (*discovery.Filter, error) {
+	filter := &discovery.Filter{}
+
+	if len(since) > 0 {
+		t, err := discovery.ParseSince(since, time.Now())
+		if err != nil {
+			return nil, errors.WithMessage(err, "invalid --since flag")
+		}
+		filter.Since = t
+	}
+
+	if len(match) > 0 {
+		pattern, err := regexp.Compile(match)
+		if err != nil {
+			return nil, errors.WithMessage(err, "invalid --match flag")
+		}
+		filter.Pattern = pattern
+	}
+
+	return filter, nil
+}
+
+// discoverURLs discovers the URLs of the sitemaps and feeds filtered by the
+// filter, and submits them to fetch.
+func discoverURLs(client discovery.Client, filter *discovery.Filter,
+	submit func(req *types.FetchRequest) error) error {
+
+	ctx := context.Background()
+	discoverer := discovery.NewDiscoverer(client, filter)
+
+	submitEntries := func(source string, entries []*discovery.Entry) error {
+		logrus.WithField("source", source).WithField("count", len(entries)).Info("URLs discovered.")
+
+		for _, e := range entries {
+			if err := submit(&types.FetchRequest{URL: e.URL}); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	for _, sitemapURL := range sitemaps {
+		entries, err := discoverer.Sitemap(ctx, sitemapURL)
+		if err != nil {
+			return err
+		}
+
+		if err := submitEntries(sitemapURL, entries); err != nil {
+			return err
+		}
+	}
+
+	for _, feedURL := range feeds {
+		entries, err := discoverer.Feed(ctx, feedURL)
+		if err != nil {
+			return err
+		}
+
+		if err := submitEntries(feedURL, entries); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,66 @@
+package cmd
+
+import (
+	"context"
+	"net"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+	"github.com/wanliqun/web-fetcher/rpc"
+	"google.golang.org/grpc"
+)
+
+var (
+	// Used for grpc flags.
+	grpcAddr string
+
+	grpcCmd = &cobra.Command{
+		Use:   "grpc [--addr <host:port>]",
+		Short: "Serve the gRPC fetcher service.",
+		Args:  cobra.NoArgs,
+		Run:   runGRPCServer,
+	}
+)
+
+func init() {
+	grpcCmd.Flags().StringVar(
+		&grpcAddr, "addr", "127.0.0.1:9090", "Address to listen on",
+	)
+
+	rootCmd.AddCommand(grpcCmd)
+}
+
+func runGRPCServer(cmd *cobra.Command, args []string) {
+	startMetricsServer()
+
+	shutdownTracing := setupTracing()
+	defer shutdownTracing()
+	defer saveCookies()
+
+	listener, err := net.Listen("tcp", grpcAddr)
+	if err != nil {
+		logrus.WithError(err).Fatalln("Failed to listen")
+	}
+
+	server := grpc.NewServer()
+	rpc.NewServer(newFetcherOptions()...).Register(server)
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	go func() {
+		logrus.WithField("addr", grpcAddr).Info("gRPC server started")
+
+		if err := server.Serve(listener); err != nil {
+			logrus.WithError(err).Fatalln("Failed to serve gRPC")
+		}
+	}()
+
+	<-ctx.Done()
+
+	server.GracefulStop()
+	logrus.Info("gRPC server stopped")
+}
@@ -0,0 +1,156 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"text/tabwriter"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+	"github.com/wanliqun/web-fetcher/fetcher"
+	"github.com/wanliqun/web-fetcher/store"
+)
+
+var (
+	// Used for history flags.
+	historyAt string
+
+	historyCmd = &cobra.Command{
+		Use:   "history",
+		Short: "Manage the versions kept in history of web pages.",
+	}
+
+	historyListCmd = &cobra.Command{
+		Use:   "list <URL>",
+		Short: "List the versions kept in history of a web page.",
+		Args:  cobra.ExactArgs(1),
+		Run:   runHistoryList,
+	}
+
+	historyRestoreCmd = &cobra.Command{
+		Use:   "restore <URL> [<version> | --at <time>]",
+		Short: "Restore a version of a web page as the latest.",
+		Args:  cobra.RangeArgs(1, 2),
+		Run:   runHistoryRestore,
+	}
+
+	historyOpenCmd = &cobra.Command{
+		Use:   "open <URL> [<version> | --at <time>]",
+		Short: "Print the HTML document file path of a version of a web page.",
+		Args:  cobra.RangeArgs(1, 2),
+		Run:   runHistoryOpen,
+	}
+)
+
+func init() {
+	for _, cmd := range []*cobra.Command{historyRestoreCmd, historyOpenCmd} {
+		cmd.Flags().StringVar(
+			&historyAt, "at", "",
+			"Point in time (RFC3339 or YYYYMMDDhhmmss) to find the latest version fetched before",
+		)
+	}
+
+	historyCmd.AddCommand(historyListCmd, historyRestoreCmd, historyOpenCmd)
+	rootCmd.AddCommand(historyCmd)
+}
+
+func runHistoryList(cmd *cobra.Command, args []string) {
+	fileStore := openHistoryFileStore(args[0])
+	versions, err := fileStore.ListVersions()
+	if err != nil {
+		logrus.WithError(err).Fatalln("Failed to list versions")
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "VERSION\tFETCHED AT\tCONTENT HASH")
+	for _, v := range versions {
+		fmt.Fprintf(w, "%s\t%s\t%s\n", v.Version, v.FetchedAt.Format(time.RFC3339), v.ContentHash)
+	}
+	w.Flush()
+}
+
+func runHistoryRestore(cmd *cobra.Command, args []string) {
+	fileStore := openHistoryFileStore(args[0])
+	version := resolveHistoryVersion(fileStore, args[1:])
+
+	if err := fileStore.RestoreVersion(version); err != nil {
+		logrus.WithError(err).Fatalln("Failed to restore version")
+	}
+
+	logrus.WithField("version", version).Info("Version restored")
+}
+
+func runHistoryOpen(cmd *cobra.Command, args []string) {
+	fileStore := openHistoryFileStore(args[0])
+	version := resolveHistoryVersion(fileStore, args[1:])
+
+	// The document file of non-HTML resources is looked up by the content type.
+	versionStore := fileStore.VersionStore(version)
+	metadata, err := versionStore.LoadMetadata()
+	if err != nil {
+		logrus.WithField("version", version).WithError(err).Fatalln("Failed to load metadata")
+	}
+
+	var contentType string
+	if metadata != nil {
+		contentType = metadata.ContentType
+	}
+
+	docPath := versionStore.DocFilePath(contentType)
+	if _, err := os.Stat(docPath); err != nil {
+		logrus.WithField("version", version).WithError(err).Fatalln("Version not found")
+	}
+
+	fmt.Println(docPath)
+}
+
+func openHistoryFileStore(rawURL string) *store.FileStore {
+	// The file store is opened by the canonical URL as the fetcher stores it.
+	fileStore, err := fetcher.OpenFileStore(settings.Storage.RootDir, rawURL)
+	if err != nil {
+		logrus.WithField("URL", rawURL).WithError(err).Fatalln("Failed to open file store")
+	}
+
+	return fileStore
+}
+
+// resolveHistoryVersion resolves the version from the argument, or the `--at`
+// point in time, or the latest version otherwise.
+func resolveHistoryVersion(fs *store.FileStore, args []string) string {
+	if len(args) > 0 {
+		return args[0]
+	}
+
+	at := time.Now()
+	if len(historyAt) > 0 {
+		var err error
+		if at, err = parsePointInTime(historyAt); err != nil {
+			logrus.WithError(err).Fatalln("Invalid point in time")
+		}
+	}
+
+	version, err := fs.VersionAt(at)
+	if err != nil {
+		logrus.WithError(err).Fatalln("Failed to find version")
+	}
+
+	if version == nil {
+		logrus.WithField("at", at).Fatalln("No version found")
+	}
+
+	return version.Version
+}
+
+func parsePointInTime(s string) (time.Time, error) {
+	if t, err := time.Parse(time.RFC3339, s); err == nil {
+		return t, nil
+	}
+
+	if t, err := time.Parse("20060102150405", s); err == nil {
+		return t, nil
+	}
+
+	return time.Time{}, errors.Errorf("expected RFC3339 or YYYYMMDDhhmmss got %s", s)
+}
@@ -0,0 +1,86 @@
+package cmd
+
+import (
+	"bufio"
+	"encoding/json"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+	"github.com/wanliqun/web-fetcher/types"
+)
+
+// readInput reads fetch requests line by line from the input file, or from stdin
+// if the file path is `-`. Each line is either a plain URL or a JSON object of
+// `types.FetchRequest`, blank lines and lines starting with `#` are skipped.
+// Malformed lines are logged and skipped rather than failing the whole input.
+func readInput(filePath string, handler func(*types.FetchRequest) error) error {
+	return readInputLines(filePath, parseInputLine, handler)
+}
+
+// readInputLines reads the non-blank and non-comment lines from the input file,
+// or from stdin if the file path is `-`, and handles each line once parsed. The
+// lines failed to parse are logged along with the line numbers and skipped.
+func readInputLines[T any](
+	filePath string, parse func(line string) (T, error), handler func(T) error) error {
+
+	var reader io.Reader = os.Stdin
+	if filePath != "-" {
+		file, err := os.Open(filePath)
+		if err != nil {
+			return errors.WithMessage(err, "failed to open input file")
+		}
+		defer file.Close()
+
+		reader = file
+	}
+
+	scanner := bufio.NewScanner(reader)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+
+	for lineNo := 1; scanner.Scan(); lineNo++ {
+		line := strings.TrimSpace(scanner.Text())
+		if len(line) == 0 || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		v, err := parse(line)
+		if err != nil {
+			logrus.WithField("line", lineNo).WithError(err).Warn("Invalid input line skipped")
+			continue
+		}
+
+		if err := handler(v); err != nil {
+			return errors.WithMessagef(err, "invalid input at line %d", lineNo)
+		}
+	}
+
+	if err := scanner.Err(); err != nil {
+		return errors.WithMessage(err, "failed to read input")
+	}
+
+	return nil
+}
+
+func parseInputLine(line string) (*types.FetchRequest, error) {
+	if !strings.HasPrefix(line, "{") {
+		return &types.FetchRequest{URL: line}, nil
+	}
+
+	var req types.FetchRequest
+	if err := json.Unmarshal([]byte(line), &req); err != nil {
+		return nil, errors.WithMessage(err, "JSON unmarshal error")
+	}
+
+	if len(req.URL) == 0 {
+		return nil, errors.New("missing URL")
+	}
+
+	if req.Depth < 0 {
+		return nil, errors.Errorf("negative depth %d", req.Depth)
+	}
+
+	return &req, nil
+}
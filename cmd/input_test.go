@@ -0,0 +1,76 @@
+package cmd
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+	"github.com/sirupsen/logrus/hooks/test"
+	"github.com/stretchr/testify/assert"
+	"github.com/wanliqun/web-fetcher/fetcher"
+	"github.com/wanliqun/web-fetcher/types"
+)
+
+func TestReadInput(t *testing.T) {
+	filePath := filepath.Join(t.TempDir(), "input.txt")
+	content := "# comment\n" +
+		"http://example.com/a\n" +
+		"\n" +
+		`{"URL": "http://example.com/b", "Depth": 1}` + "\n" +
+		`{"URL": "http://example.com/c",` + "\n" +
+		`{"Depth": 1}` + "\n" +
+		`{"URL": "http://example.com/d"}` + "\n"
+	assert.NoError(t, os.WriteFile(filePath, []byte(content), 0644))
+
+	hook := test.NewGlobal()
+	defer hook.Reset()
+
+	var urls []string
+	err := readInput(filePath, func(req *types.FetchRequest) error {
+		urls = append(urls, req.URL)
+		return nil
+	})
+	assert.NoError(t, err)
+
+	// Malformed lines are logged along with the line numbers and skipped.
+	assert.Equal(t, []string{"http://example.com/a", "http://example.com/b", "http://example.com/d"}, urls)
+
+	var lineNos []any
+	for _, entry := range hook.AllEntries() {
+		assert.Equal(t, logrus.WarnLevel, entry.Level)
+		lineNos = append(lineNos, entry.Data["line"])
+	}
+	assert.Equal(t, []any{5, 6}, lineNos)
+
+	// Failures to submit fail the input.
+	err = readInput(filePath, func(req *types.FetchRequest) error {
+		return errors.New("journal closed")
+	})
+	assert.ErrorContains(t, err, "invalid input at line 2: journal closed")
+}
+
+func TestReadWatchInput(t *testing.T) {
+	filePath := filepath.Join(t.TempDir(), "watch.txt")
+	content := `{"URL": "http://example.com/a", "Interval": "1h"}` + "\n" +
+		`{"URL": "http://example.com/b", "Interval": "1x"}` + "\n" +
+		`{"URL": "http://example.com/c", "Cron": "0 */6 * * *"}` + "\n"
+	assert.NoError(t, os.WriteFile(filePath, []byte(content), 0644))
+
+	hook := test.NewGlobal()
+	defer hook.Reset()
+
+	// Malformed watch lines are skipped as well.
+	var urls []string
+	err := readInputLines(filePath, parseWatchLine, func(req *fetcher.ScheduledRequest) error {
+		urls = append(urls, req.URL)
+		return nil
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"http://example.com/a", "http://example.com/c"}, urls)
+
+	if assert.Len(t, hook.AllEntries(), 1) {
+		assert.Equal(t, 2, hook.LastEntry().Data["line"])
+	}
+}
@@ -0,0 +1,34 @@
+package cmd
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"github.com/sirupsen/logrus"
+)
+
+// startMetricsServer serves the Prometheus metrics on the metrics address in
+// background if specified.
+func startMetricsServer() {
+	if len(metricsAddr) == 0 {
+		return
+	}
+
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", promhttp.Handler())
+
+	server := &http.Server{
+		Addr:              metricsAddr,
+		Handler:           mux,
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+
+	go func() {
+		logrus.WithField("addr", metricsAddr).Info("Metrics server started")
+
+		if err := server.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+			logrus.WithError(err).Error("Failed to serve metrics")
+		}
+	}()
+}
@@ -0,0 +1,75 @@
+package cmd
+
+import (
+	"encoding/json"
+	"io"
+	"sync"
+
+	"github.com/pkg/errors"
+	"github.com/wanliqun/web-fetcher/types"
+)
+
+const (
+	outputFormatText   = "text"
+	outputFormatJSON   = "json"
+	outputFormatNDJSON = "ndjson"
+)
+
+// resultPrinter prints machine-readable fetch result records to the writer.
+// It is safe for concurrent use.
+type resultPrinter struct {
+	mu      sync.Mutex
+	w       io.Writer
+	format  string
+	records []*types.FetchRecord
+}
+
+func newResultPrinter(format string, w io.Writer) (*resultPrinter, error) {
+	switch format {
+	case outputFormatText, outputFormatJSON, outputFormatNDJSON:
+		return &resultPrinter{w: w, format: format}, nil
+	}
+
+	return nil, errors.Errorf(
+		"output format expected one of %s, %s or %s got %s",
+		outputFormatText, outputFormatJSON, outputFormatNDJSON, format,
+	)
+}
+
+// Print prints the record of the fetch result, or buffers it until flushed
+// for JSON format.
+func (p *resultPrinter) Print(result *types.FetchResult) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	record := types.NewFetchRecord(result)
+	switch p.format {
+	case outputFormatNDJSON:
+		return json.NewEncoder(p.w).Encode(record)
+	case outputFormatJSON:
+		p.records = append(p.records, record)
+	}
+
+	return nil
+}
+
+// Flush prints all the buffered records as a JSON array for JSON format.
+func (p *resultPrinter) Flush() error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.format != outputFormatJSON {
+		return nil
+	}
+
+	records := p.records
+	if records == nil {
+		records = []*types.FetchRecord{}
+	}
+
+	p.records = nil
+
+	encoder := json.NewEncoder(p.w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(records)
+}
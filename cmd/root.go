@@ -4,39 +4,177 @@ import (
 	"fmt"
 	"os"
 
-	"github.com/PuerkitoBio/purell"
+	"github.com/pkg/errors"
 	"github.com/sirupsen/logrus"
 	"github.com/spf13/cobra"
 	"github.com/wanliqun/web-fetcher/fetcher"
+	"github.com/wanliqun/web-fetcher/store"
 	"github.com/wanliqun/web-fetcher/types"
+	"github.com/wanliqun/web-fetcher/webhook"
 )
 
 var (
 	// Used for flags.
 	printMetadata bool
 	mirror        bool
+	readable      bool
 	verbose       bool
+	output        string
+	input         string
+	journal       string
+	resume        bool
+	snapshot      bool
+	history       bool
+	keepLast      int
+	keepDays      int
+	normalizeHash bool
+	volatile      []string
+	track         []string
+	extractFile   string
+	sanitize      string
+	webhooks      []string
+	webhookSecret string
+	webhookRetry  int
+	metricsAddr   string
+	traceExporter string
+	report        string
+	failOn        string
+
+	// Exit code of the command once executed.
+	exitCode int
 
 	rootCmd = &cobra.Command{
-		Use:   "./fetch [--metadata | -a] [--mirror | -m] [--verbose | -v] <URL> [URL2] ...",
+		Use:   "./fetch [--config <file> [--profile <name>]] [--metadata | -a] [--mirror | -m] [--readable | -r] [--output | -o text|json|ndjson] [--input | -i <file>|-] [--sitemap <URL>] [--feed <URL>] [--since <date>|<duration>] [--match <regexp>] [--journal <file> [--resume]] [--report <file>] [--fail-on any|all|none] [--snapshot] [--history [--keep-last N] [--keep-days D]] [--normalize-hash] [--volatile <selector>] [--track <selector>] [--extract <file>] [--sanitize none|basic|strict] [--webhook <URL>] [--metrics-addr <host:port>] [--trace-exporter none|stdout|otlp] [--verbose | -v] [URL] [URL2] ...",
 		Short: "CLI tool for web page scraping.",
-		Args:  cobra.MinimumNArgs(1),
-		Run:   run,
+		Args: func(cmd *cobra.Command, args []string) error {
+			if len(input) == 0 && len(sitemaps) == 0 && len(feeds) == 0 {
+				return cobra.MinimumNArgs(1)(cmd, args)
+			}
+			return nil
+		},
+		RunE: run,
+		// Errors are printed by Execute.
+		SilenceErrors: true,
 	}
 )
 
 func init() {
-	rootCmd.Flags().BoolVarP(
+	rootCmd.PersistentFlags().BoolVarP(
 		&printMetadata, "metadata", "a", false,
 		"Print detailed metadata about fetched web pages",
 	)
 
-	rootCmd.Flags().BoolVarP(
+	rootCmd.PersistentFlags().BoolVarP(
 		&mirror, "mirror", "m", false,
 		"Download web page assets for local mirror",
 	)
 
-	rootCmd.Flags().BoolVarP(
+	rootCmd.PersistentFlags().BoolVarP(
+		&readable, "readable", "r", false,
+		"Extract readable main content as Markdown and plain text",
+	)
+
+	rootCmd.PersistentFlags().StringVarP(
+		&output, "output", "o", outputFormatText,
+		"Output format of fetch results (text, json or ndjson)",
+	)
+
+	rootCmd.Flags().StringVarP(
+		&input, "input", "i", "",
+		"Read URLs or JSON lines of fetch requests from file, or stdin with `-`",
+	)
+
+	rootCmd.Flags().StringVar(
+		&journal, "journal", "",
+		"Journal file to durably track the states of fetch requests",
+	)
+
+	rootCmd.Flags().BoolVar(
+		&resume, "resume", false,
+		"Resume an interrupted run from the journal file without re-fetching completed pages",
+	)
+
+	rootCmd.Flags().StringVar(
+		&report, "report", "",
+		"Write the run summary into the report file, in HTML format if ending with .html otherwise JSON",
+	)
+
+	rootCmd.Flags().StringVar(
+		&failOn, "fail-on", failOnAny,
+		"Exit non-zero if any (2, or 3 if all) or all (3) pages failed, or never with none",
+	)
+
+	rootCmd.PersistentFlags().BoolVar(
+		&snapshot, "snapshot", false,
+		"Keep versioned snapshots of fetched web pages",
+	)
+
+	rootCmd.PersistentFlags().BoolVar(
+		&history, "history", false,
+		"Keep complete versions of fetched web pages in history",
+	)
+
+	rootCmd.PersistentFlags().IntVar(
+		&keepLast, "keep-last", 0,
+		"Keep the last N versions in history (0 for unlimited)",
+	)
+
+	rootCmd.PersistentFlags().IntVar(
+		&keepDays, "keep-days", 0,
+		"Keep the versions fetched within D days in history (0 for unlimited)",
+	)
+
+	rootCmd.PersistentFlags().BoolVar(
+		&normalizeHash, "normalize-hash", false,
+		"Compute content hash over the normalized DOM to detect changes",
+	)
+
+	rootCmd.PersistentFlags().StringSliceVar(
+		&volatile, "volatile", nil,
+		"CSS selectors of volatile elements ignored by the normalized DOM (implies --normalize-hash)",
+	)
+
+	rootCmd.PersistentFlags().StringSliceVar(
+		&track, "track", nil,
+		"CSS selectors of elements whose content changes are tracked individually",
+	)
+
+	rootCmd.PersistentFlags().StringVar(
+		&extractFile, "extract", "",
+		"YAML file of custom extraction rules, whose fields are stored into the metadata",
+	)
+
+	rootCmd.PersistentFlags().StringVar(
+		&sanitize, "sanitize", "",
+		"Sanitization profile of saved HTML documents for safe offline viewing: none, basic (scripts) or strict (scripts, trackers, third-party frames and network)",
+	)
+
+	rootCmd.PersistentFlags().StringSliceVar(
+		&webhooks, "webhook", nil,
+		"Webhook URLs to notify upon fetch failures and content changes",
+	)
+
+	rootCmd.PersistentFlags().StringVar(
+		&webhookSecret, "webhook-secret", "",
+		"Secret to sign webhook notifications with HMAC-SHA256 (env WEBHOOK_SECRET)",
+	)
+
+	rootCmd.PersistentFlags().IntVar(
+		&webhookRetry, "webhook-retries", 3,
+		"Max number of retries for webhook notifications",
+	)
+
+	rootCmd.PersistentFlags().StringVar(
+		&metricsAddr, "metrics-addr", "",
+		"Address to expose Prometheus metrics on /metrics, disabled if empty",
+	)
+
+	rootCmd.PersistentFlags().StringVar(
+		&traceExporter, "trace-exporter", traceExporterNone,
+		"OpenTelemetry trace exporter: none, stdout or otlp (configured by OTEL_EXPORTER_OTLP_* env)",
+	)
+
+	rootCmd.PersistentFlags().BoolVarP(
 		&verbose, "verbose", "v", false, "Verbose output",
 	)
 }
@@ -46,60 +184,219 @@ func Execute() {
 		fmt.Fprintln(os.Stderr, err)
 		os.Exit(1)
 	}
+
+	os.Exit(exitCode)
 }
 
-func run(cmd *cobra.Command, args []string) {
+// run fetches the web pages, and returns an error rather than exiting so that
+// the deferred cleanups such as closing the journal and saving cookies are done.
+func run(cmd *cobra.Command, args []string) error {
+	// Usage is only printed for invalid flags or arguments.
+	cmd.SilenceUsage = true
+
+	startMetricsServer()
+
+	shutdownTracing := setupTracing()
+	defer shutdownTracing()
+	defer saveCookies()
+
+	printer, err := newResultPrinter(output, os.Stdout)
+	if err != nil {
+		return errors.WithMessage(err, "invalid output format")
+	}
+
+	if failOn != failOnAny && failOn != failOnAll && failOn != failOnNone {
+		return errors.Errorf("the --fail-on flag expected one of %s, %s or %s", failOnAny, failOnAll, failOnNone)
+	}
+
+	discoveryFilter, err := newDiscoveryFilter()
+	if err != nil {
+		return errors.WithMessage(err, "invalid discovery filter")
+	}
+
+	options := newFetcherOptions()
+
+	if resume && len(journal) == 0 {
+		return errors.New("the --resume flag requires the --journal flag")
+	}
+
+	var fetchJournal *store.Journal
+	if len(journal) > 0 {
+		fetchJournal, err = store.OpenJournal(journal, resume)
+		if err != nil {
+			return errors.WithMessage(err, "failed to open journal")
+		}
+		defer fetchJournal.Close()
+
+		options = append(options, fetcher.Journal(fetchJournal))
+	}
+
+	fetcher := fetcher.NewFetcher(options...)
+
+	summary := newRunSummary()
+
+	fetcher.OnFetched(newFetchedCallback(printer))
+	fetcher.OnFetched(summary.Add)
+	closeWebhook := registerWebhook(fetcher)
+	defer closeWebhook()
+
+	// URLs are canonicalized and deduped by the fetcher.
+	submit := func(req *types.FetchRequest) error {
+		return fetcher.FetchRequest(req)
+	}
+
+	// Start fetching, the web pages submitted are still fetched and summarized
+	// if any fails to submit.
+	submitErr := func() error {
+		if resume {
+			for _, req := range fetchJournal.Unfinished() {
+				if err := submit(req); err != nil {
+					return errors.WithMessage(err, "failed to submit URL")
+				}
+			}
+		}
+
+		for i := range args {
+			if err := submit(&types.FetchRequest{URL: args[i]}); err != nil {
+				return errors.WithMessage(err, "failed to submit URL")
+			}
+		}
+
+		if len(input) > 0 {
+			if err := readInput(input, submit); err != nil {
+				return errors.WithMessage(err, "failed to read input")
+			}
+		}
+
+		if len(sitemaps) > 0 || len(feeds) > 0 {
+			if err := discoverURLs(fetcher.Client(), discoveryFilter, submit); err != nil {
+				return errors.WithMessage(err, "failed to discover URLs")
+			}
+		}
+
+		return nil
+	}()
+
+	// Wait for all done.
+	fetcher.Wait()
+
+	if err := printer.Flush(); err != nil {
+		logrus.WithError(err).Error("Failed to print fetch results")
+	}
+
+	summary.Finish()
+	summary.Print(os.Stderr)
+
+	if len(report) > 0 {
+		if err := summary.WriteReport(report); err != nil {
+			logrus.WithError(err).Error("Failed to write report")
+		}
+	}
+
+	exitCode = summary.ExitCode(failOn)
+	return submitErr
+}
+
+// setupLogger sets up the logger by the verbose flag.
+func setupLogger() {
+	// Logs always go to stderr, leaving stdout for the machine-readable output.
+	logrus.SetOutput(os.Stderr)
 	if verbose {
 		logrus.SetLevel(logrus.DebugLevel)
 	} else {
 		logrus.SetLevel(logrus.InfoLevel)
 	}
+}
 
-	options := []fetcher.FetcherOption{fetcher.Async()}
-	if mirror {
-		options = append(options, fetcher.Mirror())
+// newFetcherOptions creates fetcher options from the resolved settings.
+func newFetcherOptions() []fetcher.FetcherOption {
+	options := append(settings.FetcherOptions(), authFetcherOptions()...)
+	if extractRules != nil {
+		options = append(options, fetcher.ExtractRules(extractRules))
 	}
-	fetcher := fetcher.NewFetcher(options...)
+	return append(options, fetcher.Async())
+}
+
+// registerWebhook registers the webhook notifier to the fetcher if any webhook
+// URL is configured, and returns the function to flush and close the notifier.
+func registerWebhook(f *fetcher.Fetcher) func() {
+	if len(webhooks) == 0 {
+		return func() {}
+	}
+
+	secret := webhookSecret
+	if len(secret) == 0 {
+		secret = os.Getenv("WEBHOOK_SECRET")
+	}
+
+	notifier := webhook.NewNotifier(&webhook.Config{
+		URLs:       webhooks,
+		Secret:     secret,
+		MaxRetries: webhookRetry,
+	})
+	f.OnFetched(notifier.OnFetched)
+
+	return notifier.Close
+}
 
-	fetcher.OnFetched(func(result *types.FetchResult) {
+// newFetchedCallback creates the callback to print or log the fetch results.
+func newFetchedCallback(printer *resultPrinter) fetcher.FetchedCallback {
+	return func(result *types.FetchResult) {
 		logger := logrus.WithField("URL", result.URL)
 
+		if err := printer.Print(result); err != nil {
+			logger.WithError(err).Error("Failed to print fetch result")
+		}
+
 		if result.Err != nil {
 			logger.WithError(result.Err).Error("Failed to fetch web page")
 			return
 		}
 
+		if output != outputFormatText {
+			return
+		}
+
+		if len(result.DuplicateOf) > 0 {
+			logger.WithField("duplicateOf", result.DuplicateOf).Info("Web page skipped as duplicate")
+			return
+		}
+
+		if len(result.SkippedBy) > 0 {
+			logger.WithField("skippedBy", result.SkippedBy).Info("Web page skipped by middleware")
+			return
+		}
+
 		if printMetadata && result.Metadata != nil {
 			logger = logger.WithFields(logrus.Fields{
 				"numLinks":      result.Metadata.NumLinks,
 				"images":        result.Metadata.NumImages,
 				"lastFetchedAt": result.Metadata.LastFetchedAt,
+				"contentHash":   result.Metadata.ContentHash,
+				"changed":       result.Metadata.ContentChanged,
 			})
-		}
 
-		logger.Info("Web page fetched")
-	})
-
-	urlSet := make(map[string]struct{})
-	for i := range args {
-		// Normalized passed in URL argument at first.
-		normURL, err := purell.NormalizeURLString(args[i], purell.FlagsSafe)
-		if err != nil {
-			logrus.WithField("URL", args[i]).
-				WithError(err).
-				Fatalln("Failed to normalize URL")
+			if settings.Fetcher.Readable {
+				logger = logger.WithField("numWords", result.Metadata.NumWords)
+			}
+			if len(result.Metadata.Fields) > 0 {
+				logger = logger.WithField("fields", result.Metadata.Fields)
+			}
 		}
 
-		if _, ok := urlSet[normURL]; !ok { // dedupe
-			urlSet[normURL] = struct{}{}
+		if printMetadata && result.Timings != nil {
+			logger = logger.WithFields(logrus.Fields{
+				"dns":      result.Timings.DNS,
+				"connect":  result.Timings.Connect,
+				"tls":      result.Timings.TLS,
+				"ttfb":     result.Timings.TTFB,
+				"download": result.Timings.Download,
+				"parse":    result.Timings.Parse,
+				"assets":   result.Timings.Assets,
+				"store":    result.Timings.Store,
+			})
 		}
-	}
 
-	// Start fetching
-	for u := range urlSet {
-		fetcher.Fetch(u)
+		logger.Info("Web page fetched")
 	}
-
-	// Wait for all done.
-	fetcher.Wait()
 }
@@ -0,0 +1,71 @@
+package cmd
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/sirupsen/logrus/hooks/test"
+	"github.com/spf13/cobra"
+	"github.com/stretchr/testify/assert"
+	"github.com/wanliqun/web-fetcher/config"
+	"github.com/wanliqun/web-fetcher/types"
+)
+
+func TestFetchedCallbackReadable(t *testing.T) {
+	defer func(format string, metadata bool, cfg *config.Config) {
+		output, printMetadata, settings = format, metadata, cfg
+	}(output, printMetadata, settings)
+
+	// Readable extraction enabled by the config file rather than the flag.
+	output, printMetadata = outputFormatText, true
+	settings = config.Default()
+	settings.Fetcher.Readable = true
+
+	printer, err := newResultPrinter(output, io.Discard)
+	assert.NoError(t, err)
+
+	hook := test.NewGlobal()
+	defer hook.Reset()
+
+	newFetchedCallback(printer)(&types.FetchResult{
+		URL: "http://example.com", Metadata: &types.Metadata{NumWords: 42},
+	})
+
+	if assert.NotNil(t, hook.LastEntry()) {
+		assert.Equal(t, 42, hook.LastEntry().Data["numWords"])
+	}
+}
+
+func TestRunFailure(t *testing.T) {
+	defer func(format, mode, exporter, inputFile, reportFile string, cfg *config.Config) {
+		output, failOn, traceExporter, input, report, settings = format, mode, exporter, inputFile, reportFile, cfg
+	}(output, failOn, traceExporter, input, report, settings)
+
+	site := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html")
+		w.Write([]byte(`<html><body>page</body></html>`))
+	}))
+	defer site.Close()
+
+	dir := t.TempDir()
+	output, failOn, traceExporter = outputFormatText, failOnAny, traceExporterNone
+	input, report = filepath.Join(dir, "missing.txt"), filepath.Join(dir, "report.json")
+	settings = config.Default()
+	settings.Storage.RootDir = dir
+
+	// The web pages submitted are still fetched and reported upon errors.
+	err := run(&cobra.Command{}, []string{site.URL})
+	assert.ErrorContains(t, err, "failed to read input")
+
+	data, err := os.ReadFile(report)
+	assert.NoError(t, err)
+
+	var summary runSummary
+	assert.NoError(t, json.Unmarshal(data, &summary))
+	assert.Equal(t, 1, summary.PagesSucceeded)
+}
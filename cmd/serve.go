@@ -0,0 +1,67 @@
+package cmd
+
+import (
+	"context"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+	"github.com/wanliqun/web-fetcher/replay"
+)
+
+var (
+	// Used for serve flags.
+	serveAddr string
+
+	serveCmd = &cobra.Command{
+		Use:   "serve [--addr <host:port>]",
+		Short: "Serve the archived web pages over HTTP for replay.",
+		Long: "Serve the archived web pages within the store directory over HTTP, " +
+			"which replays a web page at `/web/<timestamp>/<url>` with links rewritten on the fly.",
+		Args: cobra.NoArgs,
+		Run:  runServe,
+	}
+)
+
+func init() {
+	serveCmd.Flags().StringVar(
+		&serveAddr, "addr", "127.0.0.1:8080", "Address to listen on",
+	)
+
+	rootCmd.AddCommand(serveCmd)
+}
+
+func runServe(cmd *cobra.Command, args []string) {
+	server := replay.NewServer(serveAddr, settings.Storage.RootDir)
+	runHTTPServer(server)
+}
+
+// runHTTPServer runs the HTTP server until interrupt or termination signals,
+// and then shuts it down gracefully.
+func runHTTPServer(server *http.Server) {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	go func() {
+		logrus.WithField("addr", server.Addr).Info("HTTP server started")
+
+		if err := server.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+			logrus.WithError(err).Fatalln("Failed to serve HTTP")
+		}
+	}()
+
+	<-ctx.Done()
+
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	if err := server.Shutdown(shutdownCtx); err != nil {
+		logrus.WithError(err).Error("Failed to shut down HTTP server")
+	}
+
+	logrus.Info("HTTP server stopped")
+}
@@ -0,0 +1,80 @@
+package cmd
+
+import (
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+	"github.com/wanliqun/web-fetcher/api"
+)
+
+var (
+	// Used for server flags.
+	serverAddr      string
+	serverAPIKeys   []string
+	serverQueueSize int
+	serverWorkers   int
+
+	serverCmd = &cobra.Command{
+		Use:   "server [--addr <host:port>] [--api-key <key>] [--queue-size N] [--workers N]",
+		Short: "Serve the REST API for on-demand fetches.",
+		Long: "Serve the REST API for on-demand fetches, API keys are read from the " +
+			"comma separated env API_KEYS if not specified by flags.",
+		Args: cobra.NoArgs,
+		Run:  runServer,
+	}
+)
+
+func init() {
+	serverCmd.Flags().StringVar(
+		&serverAddr, "addr", "127.0.0.1:8090", "Address to listen on",
+	)
+
+	serverCmd.Flags().StringSliceVar(
+		&serverAPIKeys, "api-key", nil, "API keys allowed to access the API",
+	)
+
+	serverCmd.Flags().IntVar(
+		&serverQueueSize, "queue-size", 100, "Max number of queued jobs",
+	)
+
+	serverCmd.Flags().IntVar(
+		&serverWorkers, "workers", 4, "Number of jobs to run concurrently",
+	)
+
+	rootCmd.AddCommand(serverCmd)
+}
+
+func runServer(cmd *cobra.Command, args []string) {
+	startMetricsServer()
+
+	shutdownTracing := setupTracing()
+	defer shutdownTracing()
+	defer saveCookies()
+
+	apiKeys := serverAPIKeys
+	if len(apiKeys) == 0 && len(os.Getenv("API_KEYS")) > 0 {
+		apiKeys = strings.Split(os.Getenv("API_KEYS"), ",")
+	}
+
+	apiServer, err := api.NewServer(&api.Config{
+		APIKeys:        apiKeys,
+		QueueSize:      serverQueueSize,
+		Workers:        serverWorkers,
+		RootDir:        settings.Storage.RootDir,
+		FetcherOptions: newFetcherOptions(),
+	})
+	if err != nil {
+		logrus.WithError(err).Fatalln("Failed to create API server")
+	}
+	defer apiServer.Close()
+
+	runHTTPServer(&http.Server{
+		Addr:              serverAddr,
+		Handler:           apiServer,
+		ReadHeaderTimeout: 10 * time.Second,
+	})
+}
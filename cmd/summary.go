@@ -0,0 +1,195 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"html/template"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/wanliqun/web-fetcher/types"
+)
+
+const (
+	// Fail the run if any of the web pages failed.
+	failOnAny = "any"
+	// Fail the run only if all of the web pages failed.
+	failOnAll = "all"
+	// Never fail the run due to failed web pages.
+	failOnNone = "none"
+
+	// Exit code if some but not all of the web pages failed.
+	exitCodePartialFailure = 2
+	// Exit code if all of the web pages failed.
+	exitCodeAllFailed = 3
+
+	// Max number of the top errors in the summary.
+	maxTopErrors = 5
+)
+
+var reportTemplate = template.Must(template.New("report").Parse(`<!DOCTYPE html>
+<html>
+<head><meta charset="utf-8"><title>Web Fetcher Report</title></head>
+<body>
+<h1>Web Fetcher Report</h1>
+<table border="1" cellpadding="4" cellspacing="0">
+<tr><th>Started At</th><td>{{.StartedAt.Format "2006-01-02 15:04:05 MST"}}</td></tr>
+<tr><th>Duration</th><td>{{.Duration}}</td></tr>
+<tr><th>Pages Succeeded</th><td>{{.PagesSucceeded}}</td></tr>
+<tr><th>Pages Failed</th><td>{{.PagesFailed}}</td></tr>
+<tr><th>Assets Downloaded</th><td>{{.AssetsDownloaded}}</td></tr>
+<tr><th>Assets Skipped</th><td>{{.AssetsSkipped}}</td></tr>
+<tr><th>Bytes</th><td>{{.Bytes}}</td></tr>
+</table>
+{{if .TopErrors}}<h2>Top Errors</h2>
+<table border="1" cellpadding="4" cellspacing="0">
+<tr><th>Count</th><th>Error</th></tr>
+{{range .TopErrors}}<tr><td>{{.Count}}</td><td>{{.Error}}</td></tr>
+{{end}}</table>
+{{end}}{{if .FailedURLs}}<h2>Failed Pages</h2>
+<ul>
+{{range .FailedURLs}}<li>{{.}}</li>
+{{end}}</ul>
+{{end}}</body>
+</html>
+`))
+
+// errorCount is the number of web pages failed with the same error.
+type errorCount struct {
+	Error string
+	Count int
+}
+
+// runSummary summarizes the fetch results of a run. It is safe for concurrent use.
+type runSummary struct {
+	mu sync.Mutex
+
+	StartedAt        time.Time
+	FinishedAt       time.Time
+	Duration         string
+	PagesSucceeded   int
+	PagesFailed      int
+	AssetsDownloaded int
+	AssetsSkipped    int
+	Bytes            int64
+	TopErrors        []*errorCount `json:",omitempty"`
+	FailedURLs       []string      `json:",omitempty"`
+
+	errors map[string]int
+}
+
+func newRunSummary() *runSummary {
+	return &runSummary{StartedAt: time.Now(), errors: make(map[string]int)}
+}
+
+// Add adds the fetch result into the summary.
+func (s *runSummary) Add(result *types.FetchResult) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.Bytes += result.NumBytes
+	s.AssetsSkipped += result.NumSkippedAssets
+
+	if result.Err != nil {
+		s.PagesFailed++
+		s.FailedURLs = append(s.FailedURLs, result.URL)
+		s.errors[result.Err.Error()]++
+		return
+	}
+
+	s.PagesSucceeded++
+	if result.Files != nil {
+		s.AssetsDownloaded += len(result.Files.Assets)
+	}
+}
+
+// Finish finishes the summary with the duration and top errors.
+func (s *runSummary) Finish() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.FinishedAt = time.Now()
+	s.Duration = s.FinishedAt.Sub(s.StartedAt).Round(time.Millisecond).String()
+
+	s.TopErrors = nil
+	for err, count := range s.errors {
+		s.TopErrors = append(s.TopErrors, &errorCount{Error: err, Count: count})
+	}
+
+	sort.Slice(s.TopErrors, func(i, j int) bool {
+		if s.TopErrors[i].Count != s.TopErrors[j].Count {
+			return s.TopErrors[i].Count > s.TopErrors[j].Count
+		}
+		return s.TopErrors[i].Error < s.TopErrors[j].Error
+	})
+
+	if len(s.TopErrors) > maxTopErrors {
+		s.TopErrors = s.TopErrors[:maxTopErrors]
+	}
+
+	sort.Strings(s.FailedURLs)
+}
+
+// Print prints the summary in human-readable text.
+func (s *runSummary) Print(w io.Writer) {
+	fmt.Fprintf(w, "\nSummary:\n")
+	fmt.Fprintf(w, "  Pages:    %d succeeded, %d failed\n", s.PagesSucceeded, s.PagesFailed)
+	fmt.Fprintf(w, "  Assets:   %d downloaded, %d skipped\n", s.AssetsDownloaded, s.AssetsSkipped)
+	fmt.Fprintf(w, "  Bytes:    %d\n", s.Bytes)
+	fmt.Fprintf(w, "  Duration: %s\n", s.Duration)
+
+	if len(s.TopErrors) > 0 {
+		fmt.Fprintf(w, "  Top errors:\n")
+		for _, e := range s.TopErrors {
+			fmt.Fprintf(w, "    %5d  %s\n", e.Count, e.Error)
+		}
+	}
+}
+
+// WriteReport writes the summary into the report file, in HTML format if the file
+// extension is `.html` or `.htm`, otherwise in JSON format.
+func (s *runSummary) WriteReport(filePath string) error {
+	f, err := os.Create(filePath)
+	if err != nil {
+		return errors.WithMessage(err, "failed to create report file")
+	}
+	defer f.Close()
+
+	switch strings.ToLower(filepath.Ext(filePath)) {
+	case ".html", ".htm":
+		err = reportTemplate.Execute(f, s)
+	default:
+		encoder := json.NewEncoder(f)
+		encoder.SetIndent("", "  ")
+		err = encoder.Encode(s)
+	}
+
+	if err != nil {
+		return errors.WithMessage(err, "failed to write report")
+	}
+
+	return f.Close()
+}
+
+// ExitCode returns the exit code of the run by the fail-on policy.
+func (s *runSummary) ExitCode(failOn string) int {
+	if s.PagesFailed == 0 || failOn == failOnNone {
+		return 0
+	}
+
+	if s.PagesSucceeded == 0 {
+		return exitCodeAllFailed
+	}
+
+	if failOn == failOnAny {
+		return exitCodePartialFailure
+	}
+
+	return 0
+}
@@ -0,0 +1,68 @@
+package cmd
+
+import (
+	"context"
+	"os"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/exporters/stdout/stdouttrace"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.21.0"
+)
+
+const (
+	traceExporterNone   = "none"
+	traceExporterStdout = "stdout"
+	traceExporterOTLP   = "otlp"
+)
+
+// setupTracing sets up the global tracer provider by the trace exporter flag,
+// and returns the function to flush and shut down the tracer provider.
+func setupTracing() func() {
+	exporter, err := newTraceExporter(traceExporter)
+	if err != nil {
+		logrus.WithError(err).Fatalln("Failed to create trace exporter")
+	}
+
+	if exporter == nil {
+		return func() {}
+	}
+
+	tp := sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithResource(resource.NewWithAttributes(
+			semconv.SchemaURL, semconv.ServiceName("web-fetcher"),
+		)),
+	)
+	otel.SetTracerProvider(tp)
+
+	return func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+
+		if err := tp.Shutdown(ctx); err != nil {
+			logrus.WithError(err).Error("Failed to shut down tracer provider")
+		}
+	}
+}
+
+// newTraceExporter creates the span exporter, the OTLP exporter is configured by
+// the standard env such as OTEL_EXPORTER_OTLP_ENDPOINT.
+func newTraceExporter(name string) (sdktrace.SpanExporter, error) {
+	switch name {
+	case "", traceExporterNone:
+		return nil, nil
+	case traceExporterStdout:
+		// Spans go to stderr, leaving stdout for the machine-readable output.
+		return stdouttrace.New(stdouttrace.WithWriter(os.Stderr))
+	case traceExporterOTLP:
+		return otlptracehttp.New(context.Background())
+	default:
+		return nil, errors.Errorf("unsupported trace exporter %v", name)
+	}
+}
@@ -0,0 +1,170 @@
+package cmd
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/robfig/cron/v3"
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+	"github.com/wanliqun/web-fetcher/fetcher"
+	"github.com/wanliqun/web-fetcher/types"
+)
+
+var (
+	// Used for watch flags.
+	watchInput    string
+	watchInterval time.Duration
+	watchCron     string
+
+	watchCmd = &cobra.Command{
+		Use:   "watch [--interval <duration> | --cron <expr>] [--input | -i <file>|-] [URL] [URL2] ...",
+		Short: "Keep re-fetching web pages on schedule.",
+		Args: func(cmd *cobra.Command, args []string) error {
+			if len(watchInput) == 0 {
+				return cobra.MinimumNArgs(1)(cmd, args)
+			}
+			return nil
+		},
+		Run: runWatch,
+	}
+)
+
+func init() {
+	watchCmd.Flags().DurationVar(
+		&watchInterval, "interval", time.Hour,
+		"Default interval to re-fetch web pages",
+	)
+
+	watchCmd.Flags().StringVar(
+		&watchCron, "cron", "",
+		"Default cron expression to re-fetch web pages, which takes precedence over the interval",
+	)
+
+	watchCmd.Flags().StringVarP(
+		&watchInput, "input", "i", "",
+		"Read URLs or JSON lines of fetch requests with `Interval` or `Cron` from file, or stdin with `-`",
+	)
+
+	rootCmd.AddCommand(watchCmd)
+}
+
+// watchEntry is a fetch request with schedule settings from the watch input.
+type watchEntry struct {
+	types.FetchRequest
+	// Interval to re-fetch the web page, such as `30m` or `6h`.
+	Interval string `json:",omitempty"`
+	// Cron expression to re-fetch the web page, such as `0 */6 * * *`.
+	Cron string `json:",omitempty"`
+}
+
+// parseSchedule parses the schedule from the cron expression or interval, the
+// default schedule from flags is used if both are empty.
+func parseSchedule(cronExpr, interval string) (fetcher.Schedule, error) {
+	if len(cronExpr) == 0 && len(interval) == 0 {
+		cronExpr = watchCron
+		interval = watchInterval.String()
+	}
+
+	if len(cronExpr) > 0 {
+		schedule, err := cron.ParseStandard(cronExpr)
+		if err != nil {
+			return nil, errors.WithMessage(err, "invalid cron expression")
+		}
+		return schedule, nil
+	}
+
+	d, err := time.ParseDuration(interval)
+	if err != nil {
+		return nil, errors.WithMessage(err, "invalid interval")
+	}
+
+	if d <= 0 {
+		return nil, errors.Errorf("non-positive interval %v", d)
+	}
+
+	return fetcher.IntervalSchedule(d), nil
+}
+
+func parseWatchLine(line string) (*fetcher.ScheduledRequest, error) {
+	var entry watchEntry
+	if strings.HasPrefix(line, "{") {
+		if err := json.Unmarshal([]byte(line), &entry); err != nil {
+			return nil, errors.WithMessage(err, "JSON unmarshal error")
+		}
+	} else {
+		entry.URL = line
+	}
+
+	schedule, err := parseSchedule(entry.Cron, entry.Interval)
+	if err != nil {
+		return nil, err
+	}
+
+	return &fetcher.ScheduledRequest{FetchRequest: &entry.FetchRequest, Schedule: schedule}, nil
+}
+
+func runWatch(cmd *cobra.Command, args []string) {
+	startMetricsServer()
+
+	shutdownTracing := setupTracing()
+	defer shutdownTracing()
+	defer saveCookies()
+
+	printer, err := newResultPrinter(output, os.Stdout)
+	if err != nil {
+		logrus.WithError(err).Fatalln("Invalid output format")
+	}
+
+	webFetcher := fetcher.NewFetcher(newFetcherOptions()...)
+	webFetcher.OnFetched(newFetchedCallback(printer))
+	closeWebhook := registerWebhook(webFetcher)
+	defer closeWebhook()
+
+	// URLs are canonicalized and deduped by the watcher.
+	var requests []*fetcher.ScheduledRequest
+	submit := func(req *fetcher.ScheduledRequest) error {
+		requests = append(requests, req)
+		return nil
+	}
+
+	for i := range args {
+		req, err := parseWatchLine(args[i])
+		if err == nil {
+			err = submit(req)
+		}
+
+		if err != nil {
+			logrus.WithError(err).Fatalln("Failed to submit URL")
+		}
+	}
+
+	if len(watchInput) > 0 {
+		if err := readInputLines(watchInput, parseWatchLine, submit); err != nil {
+			logrus.WithError(err).Fatalln("Failed to read input")
+		}
+	}
+
+	// Shut down gracefully upon interrupt or termination signals.
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	watcher := fetcher.NewWatcher(webFetcher, requests...)
+	watcher.OnCycleDone(func() {
+		if err := printer.Flush(); err != nil {
+			logrus.WithError(err).Error("Failed to print fetch results")
+		}
+	})
+
+	if err := watcher.Run(ctx); err != nil {
+		logrus.WithError(err).Fatalln("Failed to watch")
+	}
+
+	logrus.Info("Watch stopped")
+}
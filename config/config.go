@@ -0,0 +1,380 @@
+package config
+
+import (
+	"bytes"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/BurntSushi/toml"
+	"github.com/andybalholm/cascadia"
+	"github.com/pkg/errors"
+	"github.com/wanliqun/web-fetcher/fetcher"
+	"github.com/wanliqun/web-fetcher/parser"
+	"github.com/wanliqun/web-fetcher/store"
+	"gopkg.in/yaml.v3"
+)
+
+// Config is the configuration of the web fetcher. Settings are resolved in the
+// precedence of flags, env vars, the selected profile, the base settings of the
+// config file and finally the defaults.
+type Config struct {
+	Fetcher    FetcherSettings    `yaml:"fetcher" toml:"fetcher"`
+	Client     ClientSettings     `yaml:"client" toml:"client"`
+	Storage    StorageSettings    `yaml:"storage" toml:"storage"`
+	Politeness PolitenessSettings `yaml:"politeness" toml:"politeness"`
+	Auth       AuthSettings       `yaml:"auth" toml:"auth"`
+}
+
+// FetcherSettings configures what to fetch and keep for the web pages.
+type FetcherSettings struct {
+	// Mirror: Whether to download asset resources.
+	Mirror bool `yaml:"mirror" toml:"mirror"`
+	// Readable: Whether to extract readable main content.
+	Readable bool `yaml:"readable" toml:"readable"`
+	// Snapshot: Whether to keep versioned snapshots.
+	Snapshot bool `yaml:"snapshot" toml:"snapshot"`
+	// History: Whether to keep complete versions in history.
+	History bool `yaml:"history" toml:"history"`
+	// KeepLast: The number of last versions kept in history, 0 for unlimited.
+	KeepLast int `yaml:"keep_last" toml:"keep_last"`
+	// KeepDays: The number of days versions kept in history, 0 for unlimited.
+	KeepDays int `yaml:"keep_days" toml:"keep_days"`
+	// NormalizeHash: Whether to compute content hash over the normalized DOM.
+	NormalizeHash bool `yaml:"normalize_hash" toml:"normalize_hash"`
+	// Volatile: CSS selectors of the elements ignored for the normalized hash.
+	Volatile []string `yaml:"volatile" toml:"volatile"`
+	// Track: CSS selectors of the elements whose changes are tracked.
+	Track []string `yaml:"track" toml:"track"`
+	// Extract: The YAML file of custom extraction rules.
+	Extract string `yaml:"extract" toml:"extract"`
+	// Sanitize: The sanitization profile of the HTML documents, such as `none`,
+	// `basic` or `strict`.
+	Sanitize string `yaml:"sanitize" toml:"sanitize"`
+}
+
+// ClientSettings configures the HTTP client.
+type ClientSettings struct {
+	// Parallelism: The max number of concurrent HTTP requests, 0 for unlimited.
+	Parallelism int `yaml:"parallelism" toml:"parallelism"`
+	// Timeout: The time limit of each HTTP request such as `30s`.
+	Timeout time.Duration `yaml:"timeout" toml:"timeout"`
+	// UserAgent: The `User-Agent` header of HTTP requests.
+	UserAgent string `yaml:"user_agent" toml:"user_agent"`
+}
+
+// StorageSettings configures the file store.
+type StorageSettings struct {
+	// RootDir: The root directory of the file store, defaults to the current
+	// working directory.
+	RootDir string `yaml:"root_dir" toml:"root_dir"`
+}
+
+// PolitenessSettings configures the politeness to the web sites.
+type PolitenessSettings struct {
+	// Delay: The min delay between HTTP requests to the same host such as `1s`.
+	Delay time.Duration `yaml:"delay" toml:"delay"`
+}
+
+// AuthSettings configures the authentication of HTTP requests.
+type AuthSettings struct {
+	// CredentialsFile: The YAML file of the per-host credentials.
+	CredentialsFile string `yaml:"credentials_file" toml:"credentials_file"`
+	// CookiesFile: The Netscape cookies.txt file to load cookies from, and
+	// persist them into after the run.
+	CookiesFile string `yaml:"cookies_file" toml:"cookies_file"`
+}
+
+// Env vars to override the settings.
+const (
+	EnvRootDir     = "ROOT_STORE_DIR"
+	EnvParallelism = "FETCHER_PARALLELISM"
+	EnvTimeout     = "FETCHER_TIMEOUT"
+	EnvUserAgent   = "FETCHER_USER_AGENT"
+	EnvDelay       = "FETCHER_DELAY"
+	EnvCredentials = "FETCHER_CREDENTIALS"
+	EnvCookies     = "FETCHER_COOKIES"
+)
+
+// Default returns the default configuration.
+func Default() *Config {
+	return &Config{
+		Client: ClientSettings{Timeout: 15 * time.Second},
+	}
+}
+
+// yamlFile is the layout of YAML config files, with the base settings at the
+// top level and named profiles under `profiles`.
+type yamlFile struct {
+	Config   `yaml:",inline"`
+	Profiles map[string]yaml.Node `yaml:"profiles"`
+}
+
+// tomlFile is the layout of TOML config files, with the base settings at the
+// top level and named profiles under `[profiles.<name>]`.
+type tomlFile struct {
+	Config
+	Profiles map[string]toml.Primitive `toml:"profiles"`
+}
+
+// Load loads the config file, in YAML or TOML format by the file extension, with
+// the profile if any applied over the base settings and defaults. The default
+// configuration is returned if the file path is empty.
+func Load(filePath, profile string) (*Config, error) {
+	if len(filePath) == 0 {
+		if len(profile) > 0 {
+			return nil, errors.Errorf("profile %q requires a config file", profile)
+		}
+		return Default(), nil
+	}
+
+	data, err := os.ReadFile(filePath)
+	if err != nil {
+		return nil, errors.WithMessage(err, "failed to read config file")
+	}
+
+	var cfg *Config
+	switch ext := strings.ToLower(filepath.Ext(filePath)); ext {
+	case ".yaml", ".yml":
+		cfg, err = loadYAML(data, profile)
+	case ".toml":
+		cfg, err = loadTOML(data, profile)
+	default:
+		return nil, errors.Errorf("config file extension expected .yaml, .yml or .toml got %q", ext)
+	}
+
+	if err != nil {
+		return nil, errors.WithMessagef(err, "invalid config file %v", filePath)
+	}
+
+	return cfg, nil
+}
+
+func loadYAML(data []byte, profile string) (*Config, error) {
+	file := yamlFile{Config: *Default()}
+	if err := decodeYAML(data, &file); err != nil {
+		return nil, err
+	}
+
+	var selected *Config
+	for _, name := range profileNames(file.Profiles) {
+		node := file.Profiles[name]
+
+		// Profiles are decoded over the base settings, so that only the
+		// settings present within the profile are overridden.
+		cfg := file.Config
+		data, err := yaml.Marshal(&node)
+		if err != nil {
+			return nil, errors.WithMessagef(err, "profile %q", name)
+		}
+
+		if err := decodeYAML(data, &cfg); err != nil {
+			return nil, errors.WithMessagef(err, "profile %q", name)
+		}
+
+		if name == profile {
+			selected = &cfg
+		}
+	}
+
+	return selectProfile(&file.Config, selected, profile, profileNames(file.Profiles))
+}
+
+// decodeYAML decodes the YAML data strictly, unknown keys are rejected.
+func decodeYAML(data []byte, v any) error {
+	decoder := yaml.NewDecoder(bytes.NewReader(data))
+	decoder.KnownFields(true)
+
+	// An empty document is decoded as nothing.
+	if err := decoder.Decode(v); err != nil && err != io.EOF {
+		return err
+	}
+
+	return nil
+}
+
+func loadTOML(data []byte, profile string) (*Config, error) {
+	file := tomlFile{Config: *Default()}
+	md, err := toml.Decode(string(data), &file)
+	if err != nil {
+		return nil, err
+	}
+
+	var selected *Config
+	for _, name := range profileNames(file.Profiles) {
+		cfg := file.Config
+		if err := md.PrimitiveDecode(file.Profiles[name], &cfg); err != nil {
+			return nil, errors.WithMessagef(err, "profile %q", name)
+		}
+
+		if name == profile {
+			selected = &cfg
+		}
+	}
+
+	// Unknown keys are rejected.
+	if undecoded := md.Undecoded(); len(undecoded) > 0 {
+		return nil, errors.Errorf("unknown key %q", undecoded[0].String())
+	}
+
+	return selectProfile(&file.Config, selected, profile, profileNames(file.Profiles))
+}
+
+// selectProfile returns the selected profile, or the base settings if no profile
+// is specified.
+func selectProfile(base, selected *Config, profile string, names []string) (*Config, error) {
+	if len(profile) == 0 {
+		return base, nil
+	}
+
+	if selected == nil {
+		return nil, errors.Errorf(
+			"profile %q not found, available profiles: [%s]", profile, strings.Join(names, ", "),
+		)
+	}
+
+	return selected, nil
+}
+
+func profileNames[T any](profiles map[string]T) []string {
+	names := make([]string, 0, len(profiles))
+	for name := range profiles {
+		names = append(names, name)
+	}
+
+	sort.Strings(names)
+	return names
+}
+
+// ApplyEnv overrides the settings with the env vars looked up.
+func (c *Config) ApplyEnv(lookupEnv func(key string) (string, bool)) error {
+	if val, ok := lookupEnv(EnvRootDir); ok && len(val) > 0 {
+		c.Storage.RootDir = val
+	}
+
+	for key, s := range map[string]*string{
+		EnvUserAgent:   &c.Client.UserAgent,
+		EnvCredentials: &c.Auth.CredentialsFile,
+		EnvCookies:     &c.Auth.CookiesFile,
+	} {
+		if val, ok := lookupEnv(key); ok && len(val) > 0 {
+			*s = val
+		}
+	}
+
+	if val, ok := lookupEnv(EnvParallelism); ok && len(val) > 0 {
+		n, err := strconv.Atoi(val)
+		if err != nil {
+			return errors.Errorf("env %s expected an integer got %q", EnvParallelism, val)
+		}
+		c.Client.Parallelism = n
+	}
+
+	for key, d := range map[string]*time.Duration{
+		EnvTimeout: &c.Client.Timeout,
+		EnvDelay:   &c.Politeness.Delay,
+	} {
+		if val, ok := lookupEnv(key); ok && len(val) > 0 {
+			duration, err := time.ParseDuration(val)
+			if err != nil {
+				return errors.Errorf("env %s expected a duration such as 10s got %q", key, val)
+			}
+			*d = duration
+		}
+	}
+
+	return nil
+}
+
+// Validate validates the settings.
+func (c *Config) Validate() error {
+	for name, val := range map[string]int{
+		"fetcher.keep_last":  c.Fetcher.KeepLast,
+		"fetcher.keep_days":  c.Fetcher.KeepDays,
+		"client.parallelism": c.Client.Parallelism,
+	} {
+		if val < 0 {
+			return errors.Errorf("%s must not be negative got %d", name, val)
+		}
+	}
+
+	for name, val := range map[string]time.Duration{
+		"client.timeout":   c.Client.Timeout,
+		"politeness.delay": c.Politeness.Delay,
+	} {
+		if val < 0 {
+			return errors.Errorf("%s must not be negative got %v", name, val)
+		}
+	}
+
+	for name, selectors := range map[string][]string{
+		"fetcher.volatile": c.Fetcher.Volatile,
+		"fetcher.track":    c.Fetcher.Track,
+	} {
+		for _, selector := range selectors {
+			if _, err := cascadia.ParseGroup(selector); err != nil {
+				return errors.WithMessagef(err, "%s has invalid CSS selector %q", name, selector)
+			}
+		}
+	}
+
+	if _, err := parser.SanitizeProfileByName(c.Fetcher.Sanitize); err != nil {
+		return errors.WithMessage(err, "fetcher.sanitize is invalid")
+	}
+
+	if len(c.Fetcher.Extract) > 0 {
+		if _, err := os.Stat(c.Fetcher.Extract); err != nil {
+			return errors.WithMessage(err, "fetcher.extract is not accessible")
+		}
+	}
+
+	if len(c.Auth.CredentialsFile) > 0 {
+		if _, err := os.Stat(c.Auth.CredentialsFile); err != nil {
+			return errors.WithMessage(err, "auth.credentials_file is not accessible")
+		}
+	}
+
+	if len(c.Storage.RootDir) > 0 {
+		if info, err := os.Stat(c.Storage.RootDir); err == nil && !info.IsDir() {
+			return errors.Errorf("storage.root_dir %v is not a directory", c.Storage.RootDir)
+		}
+	}
+
+	return nil
+}
+
+// FetcherOptions creates the fetcher options from the settings.
+func (c *Config) FetcherOptions() []fetcher.FetcherOption {
+	options := []fetcher.FetcherOption{
+		fetcher.RootDir(c.Storage.RootDir),
+		fetcher.Parallelism(c.Client.Parallelism),
+		fetcher.Timeout(c.Client.Timeout),
+		fetcher.UserAgent(c.Client.UserAgent),
+		fetcher.HostDelay(c.Politeness.Delay),
+		fetcher.Mirror(c.Fetcher.Mirror),
+		fetcher.Readable(c.Fetcher.Readable),
+		fetcher.Snapshot(c.Fetcher.Snapshot),
+	}
+
+	if c.Fetcher.History {
+		options = append(options, fetcher.History(store.RetentionPolicy{
+			KeepLast: c.Fetcher.KeepLast,
+			KeepFor:  time.Duration(c.Fetcher.KeepDays) * 24 * time.Hour,
+		}))
+	}
+	if c.Fetcher.NormalizeHash || len(c.Fetcher.Volatile) > 0 {
+		options = append(options, fetcher.NormalizeHash(c.Fetcher.Volatile...))
+	}
+	if len(c.Fetcher.Track) > 0 {
+		options = append(options, fetcher.TrackSelectors(c.Fetcher.Track...))
+	}
+	if profile, _ := parser.SanitizeProfileByName(c.Fetcher.Sanitize); profile != nil {
+		options = append(options, fetcher.Sanitize(profile))
+	}
+
+	return options
+}
@@ -0,0 +1,126 @@
+package config_test
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/wanliqun/web-fetcher/config"
+)
+
+func writeConfigFile(t *testing.T, name, content string) string {
+	filePath := filepath.Join(t.TempDir(), name)
+	assert.NoError(t, os.WriteFile(filePath, []byte(content), 0644))
+	return filePath
+}
+
+func TestLoad(t *testing.T) {
+	files := map[string]string{
+		"config.yaml": `
+fetcher:
+  mirror: true
+  volatile: [".ad"]
+client:
+  parallelism: 4
+profiles:
+  news:
+    fetcher:
+      readable: true
+    client:
+      timeout: 30s
+    politeness:
+      delay: 1s
+`,
+		"config.toml": `
+[fetcher]
+mirror = true
+volatile = [".ad"]
+
+[client]
+parallelism = 4
+
+[profiles.news.fetcher]
+readable = true
+
+[profiles.news.client]
+timeout = "30s"
+
+[profiles.news.politeness]
+delay = "1s"
+`,
+	}
+
+	for name, content := range files {
+		filePath := writeConfigFile(t, name, content)
+
+		// Base settings over the defaults.
+		cfg, err := config.Load(filePath, "")
+		assert.NoError(t, err, name)
+		assert.True(t, cfg.Fetcher.Mirror, name)
+		assert.False(t, cfg.Fetcher.Readable, name)
+		assert.Equal(t, 4, cfg.Client.Parallelism, name)
+		assert.Equal(t, 15*time.Second, cfg.Client.Timeout, name)
+
+		// Profile over the base settings.
+		cfg, err = config.Load(filePath, "news")
+		assert.NoError(t, err, name)
+		assert.True(t, cfg.Fetcher.Mirror, name)
+		assert.True(t, cfg.Fetcher.Readable, name)
+		assert.Equal(t, []string{".ad"}, cfg.Fetcher.Volatile, name)
+		assert.Equal(t, 4, cfg.Client.Parallelism, name)
+		assert.Equal(t, 30*time.Second, cfg.Client.Timeout, name)
+		assert.Equal(t, time.Second, cfg.Politeness.Delay, name)
+
+		_, err = config.Load(filePath, "unknown")
+		assert.ErrorContains(t, err, `profile "unknown" not found`, name)
+	}
+}
+
+func TestLoadInvalid(t *testing.T) {
+	_, err := config.Load(writeConfigFile(t, "config.yaml", "client:\n  paralelism: 4\n"), "")
+	assert.ErrorContains(t, err, "paralelism")
+
+	_, err = config.Load(writeConfigFile(t, "config.toml", "[profiles.a.client]\nparalelism = 4\n"), "")
+	assert.ErrorContains(t, err, "paralelism")
+
+	_, err = config.Load(writeConfigFile(t, "config.json", "{}"), "")
+	assert.Error(t, err)
+
+	_, err = config.Load("", "news")
+	assert.Error(t, err)
+}
+
+func TestApplyEnvAndValidate(t *testing.T) {
+	env := map[string]string{
+		config.EnvRootDir:     "/tmp/store",
+		config.EnvParallelism: "8",
+		config.EnvDelay:       "500ms",
+	}
+	lookupEnv := func(key string) (string, bool) {
+		val, ok := env[key]
+		return val, ok
+	}
+
+	cfg := config.Default()
+	assert.NoError(t, cfg.ApplyEnv(lookupEnv))
+	assert.Equal(t, "/tmp/store", cfg.Storage.RootDir)
+	assert.Equal(t, 8, cfg.Client.Parallelism)
+	assert.Equal(t, 500*time.Millisecond, cfg.Politeness.Delay)
+	assert.NoError(t, cfg.Validate())
+
+	env[config.EnvTimeout] = "soon"
+	assert.Error(t, cfg.ApplyEnv(lookupEnv))
+
+	cfg.Client.Parallelism = -1
+	assert.ErrorContains(t, cfg.Validate(), "client.parallelism")
+
+	cfg.Client.Parallelism = 0
+	cfg.Fetcher.Track = []string{"div["}
+	assert.ErrorContains(t, cfg.Validate(), "fetcher.track")
+
+	cfg.Fetcher.Track = nil
+	cfg.Fetcher.Sanitize = "paranoid"
+	assert.ErrorContains(t, cfg.Validate(), "fetcher.sanitize")
+}
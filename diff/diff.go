@@ -0,0 +1,260 @@
+package diff
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Op is the operation of an edit.
+type Op int
+
+const (
+	OpEqual Op = iota
+	OpDelete
+	OpInsert
+)
+
+// Edit represents a line edit transforming the old lines into the new lines.
+type Edit struct {
+	Op   Op
+	Line string
+	// Line numbers (starting from 1) in the old and new lines, 0 if not applicable.
+	OldLineNo, NewLineNo int
+}
+
+// Lines computes the shortest edit script between the old and new lines with
+// the linear space variant of the Myers' diff algorithm, which bisects the edit
+// graph by the middle snake recursively.
+func Lines(a, b []string) []Edit {
+	d := &differ{a: a, b: b}
+	d.diff(0, len(a), 0, len(b))
+	return d.edits
+}
+
+// differ accumulates the edits between the old and new lines in order.
+type differ struct {
+	a, b  []string
+	edits []Edit
+}
+
+// diff appends the edits transforming a[aLo:aHi] into b[bLo:bHi].
+func (d *differ) diff(aLo, aHi, bLo, bHi int) {
+	// Strip the common prefix and suffix, which are equal anyway.
+	for aLo < aHi && bLo < bHi && d.a[aLo] == d.b[bLo] {
+		d.equal(aLo, bLo)
+		aLo, bLo = aLo+1, bLo+1
+	}
+
+	suffix := 0
+	for aLo < aHi-suffix && bLo < bHi-suffix && d.a[aHi-suffix-1] == d.b[bHi-suffix-1] {
+		suffix++
+	}
+	aHi, bHi = aHi-suffix, bHi-suffix
+
+	switch {
+	case aLo == aHi:
+		for y := bLo; y < bHi; y++ {
+			d.edits = append(d.edits, Edit{Op: OpInsert, Line: d.b[y], NewLineNo: y + 1})
+		}
+	case bLo == bHi:
+		for x := aLo; x < aHi; x++ {
+			d.edits = append(d.edits, Edit{Op: OpDelete, Line: d.a[x], OldLineNo: x + 1})
+		}
+	default:
+		if x, y, ok := d.bisect(aLo, aHi, bLo, bHi); ok {
+			d.diff(aLo, x, bLo, y)
+			d.diff(x, aHi, y, bHi)
+			break
+		}
+
+		// Nothing in common, replace all the lines.
+		d.diff(aLo, aHi, bLo, bLo)
+		d.diff(aHi, aHi, bLo, bHi)
+	}
+
+	for i := 0; i < suffix; i++ {
+		d.equal(aHi+i, bHi+i)
+	}
+}
+
+// bisect finds the point where the forward and reverse paths of the shortest
+// edit script between a[aLo:aHi] and b[bLo:bHi] overlap, which splits the edit
+// graph into two smaller ones, which is false if nothing in common. Only the
+// furthest reaching x on each diagonal of the current edit distance is kept, so
+// that the space is linear.
+func (d *differ) bisect(aLo, aHi, bLo, bHi int) (int, int, bool) {
+	a, b := d.a[aLo:aHi], d.b[bLo:bHi]
+	n, m := len(a), len(b)
+
+	maxD := (n + m + 1) / 2
+	offset := maxD
+	v1, v2 := make([]int, 2*maxD+2), make([]int, 2*maxD+2)
+	for i := range v1 {
+		v1[i], v2[i] = -1, -1
+	}
+	v1[offset+1], v2[offset+1] = 0, 0
+
+	delta := n - m
+	// The paths overlap in the forward pass if the delta is odd, otherwise in
+	// the reverse pass.
+	front := delta%2 != 0
+
+	// Diagonals to skip once the paths go off the edit graph.
+	var k1Start, k1End, k2Start, k2End int
+
+	for dist := 0; dist < maxD; dist++ {
+		for k1 := -dist + k1Start; k1 <= dist-k1End; k1 += 2 {
+			var x1 int
+			if k1 == -dist || (k1 != dist && v1[offset+k1-1] < v1[offset+k1+1]) {
+				x1 = v1[offset+k1+1] // move down
+			} else {
+				x1 = v1[offset+k1-1] + 1 // move right
+			}
+
+			y1 := x1 - k1
+			for x1 < n && y1 < m && a[x1] == b[y1] {
+				x1, y1 = x1+1, y1+1
+			}
+			v1[offset+k1] = x1
+
+			switch {
+			case x1 > n:
+				k1End += 2
+			case y1 > m:
+				k1Start += 2
+			case front:
+				if k2 := offset + delta - k1; k2 >= 0 && k2 < len(v2) && v2[k2] != -1 {
+					if x1 >= n-v2[k2] {
+						return aLo + x1, bLo + y1, true
+					}
+				}
+			}
+		}
+
+		for k2 := -dist + k2Start; k2 <= dist-k2End; k2 += 2 {
+			var x2 int
+			if k2 == -dist || (k2 != dist && v2[offset+k2-1] < v2[offset+k2+1]) {
+				x2 = v2[offset+k2+1]
+			} else {
+				x2 = v2[offset+k2-1] + 1
+			}
+
+			y2 := x2 - k2
+			for x2 < n && y2 < m && a[n-x2-1] == b[m-y2-1] {
+				x2, y2 = x2+1, y2+1
+			}
+			v2[offset+k2] = x2
+
+			switch {
+			case x2 > n:
+				k2End += 2
+			case y2 > m:
+				k2Start += 2
+			case !front:
+				if k1 := offset + delta - k2; k1 >= 0 && k1 < len(v1) && v1[k1] != -1 {
+					x1 := v1[k1]
+					if y1 := x1 - (k1 - offset); x1 >= n-x2 {
+						return aLo + x1, bLo + y1, true
+					}
+				}
+			}
+		}
+	}
+
+	return 0, 0, false
+}
+
+// equal appends the equal line at a[x] and b[y].
+func (d *differ) equal(x, y int) {
+	d.edits = append(d.edits, Edit{Op: OpEqual, Line: d.a[x], OldLineNo: x + 1, NewLineNo: y + 1})
+}
+
+// HasChanges checks if there is any deletion or insertion within the edits.
+func HasChanges(edits []Edit) bool {
+	for _, e := range edits {
+		if e.Op != OpEqual {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Unified formats the edits in the unified diff format with the given number
+// of context lines around each change.
+func Unified(oldName, newName string, edits []Edit, context int) string {
+	if !HasChanges(edits) {
+		return ""
+	}
+
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "--- %s\n+++ %s\n", oldName, newName)
+
+	for start := 0; start < len(edits); {
+		// Find the next change.
+		for start < len(edits) && edits[start].Op == OpEqual {
+			start++
+		}
+		if start >= len(edits) {
+			break
+		}
+
+		// Extend the hunk until there are more than `2*context` equal lines.
+		end, equals := start, 0
+		for i := start; i < len(edits) && equals <= 2*context; i++ {
+			if edits[i].Op == OpEqual {
+				equals++
+			} else {
+				end, equals = i, 0
+			}
+		}
+
+		hunkStart := start - context
+		if hunkStart < 0 {
+			hunkStart = 0
+		}
+		hunkEnd := end + context + 1
+		if hunkEnd > len(edits) {
+			hunkEnd = len(edits)
+		}
+
+		writeHunk(&sb, edits[hunkStart:hunkEnd])
+		start = hunkEnd
+	}
+
+	return sb.String()
+}
+
+func writeHunk(sb *strings.Builder, hunk []Edit) {
+	var oldStart, newStart, oldLines, newLines int
+	for _, e := range hunk {
+		if e.Op != OpInsert {
+			if oldStart == 0 {
+				oldStart = e.OldLineNo
+			}
+			oldLines++
+		}
+
+		if e.Op != OpDelete {
+			if newStart == 0 {
+				newStart = e.NewLineNo
+			}
+			newLines++
+		}
+	}
+
+	fmt.Fprintf(sb, "@@ -%d,%d +%d,%d @@\n", oldStart, oldLines, newStart, newLines)
+	for _, e := range hunk {
+		switch e.Op {
+		case OpEqual:
+			sb.WriteString(" ")
+		case OpDelete:
+			sb.WriteString("-")
+		case OpInsert:
+			sb.WriteString("+")
+		}
+
+		sb.WriteString(e.Line)
+		sb.WriteString("\n")
+	}
+}
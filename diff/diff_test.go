@@ -0,0 +1,105 @@
+package diff_test
+
+import (
+	"math/rand"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/wanliqun/web-fetcher/diff"
+)
+
+func TestLines(t *testing.T) {
+	a := strings.Split("a b c a b b a", " ")
+	b := strings.Split("c b a b a c", " ")
+
+	edits := diff.Lines(a, b)
+	assert.True(t, diff.HasChanges(edits))
+
+	// Applying the edits should result in the new lines.
+	var oldLines, newLines []string
+	var numChanges int
+	for _, e := range edits {
+		if e.Op != diff.OpInsert {
+			oldLines = append(oldLines, e.Line)
+		}
+		if e.Op != diff.OpDelete {
+			newLines = append(newLines, e.Line)
+		}
+		if e.Op != diff.OpEqual {
+			numChanges++
+		}
+	}
+
+	assert.Equal(t, a, oldLines)
+	assert.Equal(t, b, newLines)
+	assert.Equal(t, 5, numChanges, "Expected shortest edit script of 5 changes")
+
+	assert.False(t, diff.HasChanges(diff.Lines(a, a)))
+	assert.Empty(t, diff.Unified("a", "b", diff.Lines(a, a), 3))
+}
+
+func TestLinesRandom(t *testing.T) {
+	rnd := rand.New(rand.NewSource(1))
+	randLines := func() []string {
+		lines := make([]string, rnd.Intn(30))
+		for i := range lines {
+			lines[i] = string(rune('a' + rnd.Intn(4)))
+		}
+		return lines
+	}
+
+	for i := 0; i < 500; i++ {
+		a, b := randLines(), randLines()
+
+		var oldLines, newLines []string
+		var numChanges int
+		for _, e := range diff.Lines(a, b) {
+			if e.Op != diff.OpInsert {
+				assert.Equal(t, a[e.OldLineNo-1], e.Line)
+				oldLines = append(oldLines, e.Line)
+			}
+			if e.Op != diff.OpDelete {
+				assert.Equal(t, b[e.NewLineNo-1], e.Line)
+				newLines = append(newLines, e.Line)
+			}
+			if e.Op != diff.OpEqual {
+				numChanges++
+			}
+		}
+
+		assert.Equal(t, strings.Join(a, ""), strings.Join(oldLines, ""))
+		assert.Equal(t, strings.Join(b, ""), strings.Join(newLines, ""))
+		assert.Equal(t, len(a)+len(b)-2*lcs(a, b), numChanges, "Expected shortest edit script")
+	}
+}
+
+// lcs computes the length of the longest common subsequence by dynamic programming.
+func lcs(a, b []string) int {
+	dp := make([][]int, len(a)+1)
+	for i := range dp {
+		dp[i] = make([]int, len(b)+1)
+	}
+
+	for i := len(a) - 1; i >= 0; i-- {
+		for j := len(b) - 1; j >= 0; j-- {
+			if a[i] == b[j] {
+				dp[i][j] = dp[i+1][j+1] + 1
+			} else {
+				dp[i][j] = max(dp[i+1][j], dp[i][j+1])
+			}
+		}
+	}
+
+	return dp[0][0]
+}
+
+func TestUnified(t *testing.T) {
+	a := []string{"1", "2", "3", "4", "5", "6", "7", "8", "9", "10"}
+	b := []string{"1", "2", "three", "4", "5", "6", "7", "8", "9", "10", "11"}
+
+	expected := "--- old\n+++ new\n" +
+		"@@ -1,6 +1,6 @@\n 1\n 2\n-3\n+three\n 4\n 5\n 6\n" +
+		"@@ -8,3 +8,4 @@\n 8\n 9\n 10\n+11\n"
+	assert.Equal(t, expected, diff.Unified("old", "new", diff.Lines(a, b), 3))
+}
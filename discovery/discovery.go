@@ -0,0 +1,188 @@
+package discovery
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"io"
+	"net/http"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// Max size of a sitemap or feed document, which is 50MB uncompressed by the
+// sitemaps protocol.
+const maxDocumentSize = 50 << 20
+
+// Client does HTTP requests, such as the fetcher's throttle client.
+type Client interface {
+	Do(ctx context.Context, req *http.Request) (*http.Response, error)
+}
+
+// Entry is a web page URL discovered from sitemaps or feeds.
+type Entry struct {
+	// URL: The web page URL.
+	URL string
+	// LastMod: The last modification time of the web page if known.
+	LastMod *time.Time
+}
+
+// Filter filters the discovered entries.
+type Filter struct {
+	// Since: Only the entries modified since the time are kept if set, entries
+	// without the last modification time are always kept.
+	Since time.Time
+	// Pattern: Only the entries whose URL matches the pattern are kept if set.
+	Pattern *regexp.Regexp
+}
+
+// Match checks if the entry matches the filter.
+func (f *Filter) Match(e *Entry) bool {
+	if f == nil {
+		return true
+	}
+
+	if !f.modifiedSince(e.LastMod) {
+		return false
+	}
+
+	if f.Pattern != nil && !f.Pattern.MatchString(e.URL) {
+		return false
+	}
+
+	return true
+}
+
+// modifiedSince checks if the last modification time is since the filter time,
+// unknown last modification time is always considered modified.
+func (f *Filter) modifiedSince(lastMod *time.Time) bool {
+	if f == nil || f.Since.IsZero() || lastMod == nil {
+		return true
+	}
+
+	return !lastMod.Before(f.Since)
+}
+
+// Discoverer discovers web page URLs from sitemaps and feeds.
+type Discoverer struct {
+	client Client
+	filter *Filter
+}
+
+// NewDiscoverer creates a discoverer with the HTTP client, and the discovered
+// entries are filtered by the filter if not nil.
+func NewDiscoverer(client Client, filter *Filter) *Discoverer {
+	return &Discoverer{client: client, filter: filter}
+}
+
+// filterEntries keeps the entries matching the filter, with the duplicates removed.
+func (d *Discoverer) filterEntries(entries []*Entry) []*Entry {
+	seen := make(map[string]struct{})
+
+	var filtered []*Entry
+	for _, e := range entries {
+		if _, ok := seen[e.URL]; ok || !d.filter.Match(e) {
+			continue
+		}
+
+		seen[e.URL] = struct{}{}
+		filtered = append(filtered, e)
+	}
+
+	return filtered
+}
+
+// get downloads the document of the URL, which is decompressed if gzipped.
+func (d *Discoverer) get(ctx context.Context, rawURL string) ([]byte, error) {
+	req, err := http.NewRequest(http.MethodGet, rawURL, nil)
+	if err != nil {
+		return nil, errors.WithMessage(err, "failed to create HTTP request")
+	}
+
+	resp, err := d.client.Do(ctx, req)
+	if err != nil {
+		return nil, errors.WithMessage(err, "failed to do HTTP request")
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return nil, errors.Errorf("bad HTTP status code: %d", resp.StatusCode)
+	}
+
+	data, err := io.ReadAll(io.LimitReader(resp.Body, maxDocumentSize+1))
+	if err != nil {
+		return nil, errors.WithMessage(err, "failed to read response body")
+	}
+
+	// Gzipped documents are detected by the magic number, as they may be served
+	// with any content type or transparently decompressed already.
+	if bytes.HasPrefix(data, []byte{0x1f, 0x8b}) {
+		zr, err := gzip.NewReader(bytes.NewReader(data))
+		if err != nil {
+			return nil, errors.WithMessage(err, "invalid gzip document")
+		}
+
+		data, err = io.ReadAll(io.LimitReader(zr, maxDocumentSize+1))
+		if err != nil {
+			return nil, errors.WithMessage(err, "invalid gzip document")
+		}
+	}
+
+	if len(data) > maxDocumentSize {
+		return nil, errors.Errorf("document exceeds the max size of %d bytes", maxDocumentSize)
+	}
+
+	return data, nil
+}
+
+// dateLayouts are the date layouts used by sitemaps (W3C datetime) and feeds
+// (RFC 822 for RSS and RFC 3339 for Atom).
+var dateLayouts = []string{
+	time.RFC3339,
+	"2006-01-02T15:04Z07:00",
+	"2006-01-02T15:04:05",
+	"2006-01-02",
+	"2006-01",
+	"2006",
+	time.RFC1123Z,
+	time.RFC1123,
+	"Mon, 2 Jan 2006 15:04:05 -0700",
+	"Mon, 2 Jan 2006 15:04:05 MST",
+	time.RFC822Z,
+	time.RFC822,
+}
+
+// parseDate parses the date in any of the known layouts, nil is returned if
+// empty or unknown.
+func parseDate(s string) *time.Time {
+	s = strings.TrimSpace(s)
+	if len(s) == 0 {
+		return nil
+	}
+
+	for _, layout := range dateLayouts {
+		if t, err := time.Parse(layout, s); err == nil {
+			return &t
+		}
+	}
+
+	return nil
+}
+
+// ParseSince parses the time since which the entries are kept, in the format
+// of a date such as `2024-01-31`, an RFC 3339 timestamp, or a duration ago such
+// as `72h`.
+func ParseSince(s string, now time.Time) (time.Time, error) {
+	if d, err := time.ParseDuration(s); err == nil {
+		return now.Add(-d), nil
+	}
+
+	if t := parseDate(s); t != nil {
+		return *t, nil
+	}
+
+	return time.Time{}, errors.Errorf("expected a date, an RFC 3339 timestamp or a duration got %q", s)
+}
@@ -0,0 +1,122 @@
+package discovery_test
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/wanliqun/web-fetcher/discovery"
+)
+
+type httpClient struct{}
+
+func (httpClient) Do(ctx context.Context, req *http.Request) (*http.Response, error) {
+	return http.DefaultClient.Do(req.WithContext(ctx))
+}
+
+func newTestServer(t *testing.T) *httptest.Server {
+	var server *httptest.Server
+	server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/robots.txt":
+			fmt.Fprintln(w, "User-agent: *\nDisallow: /private\nSitemap: /sitemap_index.xml")
+		case "/sitemap_index.xml":
+			fmt.Fprintf(w, `<?xml version="1.0" encoding="UTF-8"?>
+<sitemapindex xmlns="http://www.sitemaps.org/schemas/sitemap/0.9">
+  <sitemap><loc>%[1]s/posts.xml.gz</loc><lastmod>2024-03-01</lastmod></sitemap>
+  <sitemap><loc>%[1]s/archive.xml</loc><lastmod>2020-01-01</lastmod></sitemap>
+</sitemapindex>`, server.URL)
+		case "/posts.xml.gz":
+			var buf bytes.Buffer
+			zw := gzip.NewWriter(&buf)
+			fmt.Fprintf(zw, `<urlset xmlns="http://www.sitemaps.org/schemas/sitemap/0.9">
+  <url><loc>%[1]s/posts/new</loc><lastmod>2024-02-15T10:00:00+00:00</lastmod></url>
+  <url><loc>%[1]s/posts/old</loc><lastmod>2023-06-01</lastmod></url>
+  <url><loc>%[1]s/about</loc></url>
+</urlset>`, server.URL)
+			zw.Close()
+			w.Write(buf.Bytes())
+		case "/archive.xml":
+			fmt.Fprintf(w, `<urlset><url><loc>%s/posts/archived</loc></url></urlset>`, server.URL)
+		case "/rss.xml":
+			fmt.Fprint(w, `<rss version="2.0"><channel>
+  <item><link>/posts/new</link><pubDate>Thu, 15 Feb 2024 10:00:00 +0000</pubDate></item>
+  <item><link>/posts/old</link><pubDate>Thu, 01 Jun 2023 10:00:00 +0000</pubDate></item>
+</channel></rss>`)
+		case "/atom.xml":
+			fmt.Fprint(w, `<feed xmlns="http://www.w3.org/2005/Atom">
+  <entry><link rel="self" href="/feed/1"/><link href="/posts/new"/><updated>2024-02-15T10:00:00Z</updated></entry>
+  <entry><link rel="alternate" href="/posts/old"/><updated>2023-06-01T10:00:00Z</updated></entry>
+</feed>`)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	t.Cleanup(server.Close)
+
+	return server
+}
+
+func TestSitemap(t *testing.T) {
+	server := newTestServer(t)
+	ctx := context.Background()
+
+	// Sitemaps are discovered from robots.txt of the site root.
+	entries, err := discovery.NewDiscoverer(httpClient{}, nil).Sitemap(ctx, server.URL+"/")
+	assert.NoError(t, err)
+	assert.Len(t, entries, 4)
+
+	filter := &discovery.Filter{
+		Since:   time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC),
+		Pattern: regexp.MustCompile(`/posts/`),
+	}
+	entries, err = discovery.NewDiscoverer(httpClient{}, filter).Sitemap(ctx, server.URL+"/sitemap_index.xml")
+	assert.NoError(t, err)
+	if assert.Len(t, entries, 1) {
+		assert.Equal(t, server.URL+"/posts/new", entries[0].URL)
+		assert.Equal(t, 2024, entries[0].LastMod.Year())
+	}
+
+	_, err = discovery.NewDiscoverer(httpClient{}, nil).Sitemap(ctx, server.URL+"/missing.xml")
+	assert.ErrorContains(t, err, "404")
+}
+
+func TestFeed(t *testing.T) {
+	server := newTestServer(t)
+	ctx := context.Background()
+
+	filter := &discovery.Filter{Since: time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)}
+	for _, feed := range []string{"/rss.xml", "/atom.xml"} {
+		entries, err := discovery.NewDiscoverer(httpClient{}, nil).Feed(ctx, server.URL+feed)
+		assert.NoError(t, err)
+		assert.Len(t, entries, 2)
+
+		entries, err = discovery.NewDiscoverer(httpClient{}, filter).Feed(ctx, server.URL+feed)
+		assert.NoError(t, err)
+		if assert.Len(t, entries, 1) {
+			assert.Equal(t, server.URL+"/posts/new", entries[0].URL)
+		}
+	}
+}
+
+func TestParseSince(t *testing.T) {
+	now := time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC)
+
+	since, err := discovery.ParseSince("48h", now)
+	assert.NoError(t, err)
+	assert.Equal(t, now.Add(-48*time.Hour), since)
+
+	since, err = discovery.ParseSince("2024-01-31", now)
+	assert.NoError(t, err)
+	assert.Equal(t, time.Date(2024, 1, 31, 0, 0, 0, 0, time.UTC), since)
+
+	_, err = discovery.ParseSince("yesterday", now)
+	assert.Error(t, err)
+}
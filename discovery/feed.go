@@ -0,0 +1,102 @@
+package discovery
+
+import (
+	"context"
+	"encoding/xml"
+	"net/url"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// feedDoc is either an RSS 2.0 `rss`, an RSS 1.0 `RDF` or an Atom `feed` document.
+type feedDoc struct {
+	XMLName xml.Name
+	// RSS 2.0 items are within the channel.
+	Channel struct {
+		Items []feedItem `xml:"item"`
+	} `xml:"channel"`
+	// RSS 1.0 items are siblings of the channel.
+	Items   []feedItem  `xml:"item"`
+	Entries []atomEntry `xml:"entry"`
+}
+
+type feedItem struct {
+	Link    string `xml:"link"`
+	GUID    string `xml:"guid"`
+	PubDate string `xml:"pubDate"`
+	// Date: The Dublin Core `dc:date` of RSS 1.0.
+	Date string `xml:"date"`
+}
+
+type atomEntry struct {
+	Links []struct {
+		Href string `xml:"href,attr"`
+		Rel  string `xml:"rel,attr"`
+	} `xml:"link"`
+	Updated   string `xml:"updated"`
+	Published string `xml:"published"`
+}
+
+// Feed discovers the web page URLs from the RSS or Atom feed.
+func (d *Discoverer) Feed(ctx context.Context, feedURL string) ([]*Entry, error) {
+	feedURLObj, err := url.Parse(feedURL)
+	if err != nil {
+		return nil, errors.WithMessage(err, "invalid feed URL")
+	}
+
+	data, err := d.get(ctx, feedURL)
+	if err != nil {
+		return nil, errors.WithMessagef(err, "failed to discover feed %v", feedURL)
+	}
+
+	var doc feedDoc
+	if err := xml.Unmarshal(data, &doc); err != nil {
+		return nil, errors.WithMessage(err, "invalid feed XML")
+	}
+
+	var entries []*Entry
+	addEntry := func(link, date string) {
+		linkObj, err := url.Parse(strings.TrimSpace(link))
+		if err != nil || len(strings.TrimSpace(link)) == 0 {
+			return
+		}
+
+		// Links may be relative to the feed URL in the wild.
+		entries = append(entries, &Entry{
+			URL: feedURLObj.ResolveReference(linkObj).String(), LastMod: parseDate(date),
+		})
+	}
+
+	switch doc.XMLName.Local {
+	case "rss", "RDF":
+		for _, item := range append(doc.Channel.Items, doc.Items...) {
+			link, date := item.Link, item.PubDate
+			if len(strings.TrimSpace(link)) == 0 {
+				link = item.GUID
+			}
+			if len(strings.TrimSpace(date)) == 0 {
+				date = item.Date
+			}
+			addEntry(link, date)
+		}
+	case "feed":
+		for _, entry := range doc.Entries {
+			date := entry.Updated
+			if len(strings.TrimSpace(date)) == 0 {
+				date = entry.Published
+			}
+
+			for _, link := range entry.Links {
+				if len(link.Rel) == 0 || link.Rel == "alternate" {
+					addEntry(link.Href, date)
+					break
+				}
+			}
+		}
+	default:
+		return nil, errors.Errorf("feed root element expected rss, RDF or feed got %v", doc.XMLName.Local)
+	}
+
+	return d.filterEntries(entries), nil
+}
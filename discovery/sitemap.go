@@ -0,0 +1,153 @@
+package discovery
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/xml"
+	"net/url"
+	"strings"
+
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+)
+
+// Max depth of nested sitemap indexes to follow.
+const maxSitemapDepth = 3
+
+// sitemapDoc is either a sitemap `urlset` or a sitemap index `sitemapindex`.
+type sitemapDoc struct {
+	XMLName  xml.Name
+	URLs     []sitemapLoc `xml:"url"`
+	Sitemaps []sitemapLoc `xml:"sitemap"`
+}
+
+type sitemapLoc struct {
+	Loc     string `xml:"loc"`
+	LastMod string `xml:"lastmod"`
+}
+
+// Sitemap discovers the web page URLs from the sitemap, following the nested
+// sitemaps of sitemap indexes. If the URL is a site root such as
+// `https://example.com/` or its robots.txt, the sitemaps are discovered from the
+// robots.txt, falling back to `/sitemap.xml`.
+func (d *Discoverer) Sitemap(ctx context.Context, rawURL string) ([]*Entry, error) {
+	urlObj, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, errors.WithMessage(err, "invalid sitemap URL")
+	}
+
+	sitemapURLs := []string{rawURL}
+	if path := urlObj.Path; path == "" || path == "/" || path == "/robots.txt" {
+		sitemapURLs, err = d.RobotsSitemaps(ctx, rawURL)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	visited := make(map[string]struct{})
+
+	var entries []*Entry
+	for _, sitemapURL := range sitemapURLs {
+		found, err := d.sitemap(ctx, sitemapURL, 0, visited)
+		if err != nil {
+			return nil, errors.WithMessagef(err, "failed to discover sitemap %v", sitemapURL)
+		}
+		entries = append(entries, found...)
+	}
+
+	return d.filterEntries(entries), nil
+}
+
+func (d *Discoverer) sitemap(
+	ctx context.Context, sitemapURL string, depth int, visited map[string]struct{}) ([]*Entry, error) {
+
+	if _, ok := visited[sitemapURL]; ok {
+		return nil, nil
+	}
+	visited[sitemapURL] = struct{}{}
+
+	data, err := d.get(ctx, sitemapURL)
+	if err != nil {
+		return nil, err
+	}
+
+	var doc sitemapDoc
+	if err := xml.Unmarshal(data, &doc); err != nil {
+		return nil, errors.WithMessage(err, "invalid sitemap XML")
+	}
+
+	var entries []*Entry
+	switch doc.XMLName.Local {
+	case "urlset":
+		for _, u := range doc.URLs {
+			if loc := strings.TrimSpace(u.Loc); len(loc) > 0 {
+				entries = append(entries, &Entry{URL: loc, LastMod: parseDate(u.LastMod)})
+			}
+		}
+	case "sitemapindex":
+		if depth >= maxSitemapDepth {
+			return nil, errors.Errorf("sitemap index nested deeper than %d", maxSitemapDepth)
+		}
+
+		for _, s := range doc.Sitemaps {
+			loc := strings.TrimSpace(s.Loc)
+
+			// Skip the sitemaps not modified since, as neither are their URLs.
+			if len(loc) == 0 || !d.filter.modifiedSince(parseDate(s.LastMod)) {
+				continue
+			}
+
+			found, err := d.sitemap(ctx, loc, depth+1, visited)
+			if err != nil {
+				logrus.WithField("URL", loc).WithError(err).Warn("Failed to discover nested sitemap.")
+				continue
+			}
+			entries = append(entries, found...)
+		}
+	default:
+		return nil, errors.Errorf("sitemap root element expected urlset or sitemapindex got %v", doc.XMLName.Local)
+	}
+
+	return entries, nil
+}
+
+// RobotsSitemaps discovers the sitemap URLs from the robots.txt of the site,
+// `/sitemap.xml` is returned if none.
+func (d *Discoverer) RobotsSitemaps(ctx context.Context, siteURL string) ([]string, error) {
+	urlObj, err := url.Parse(siteURL)
+	if err != nil {
+		return nil, errors.WithMessage(err, "invalid site URL")
+	}
+
+	robotsURL := &url.URL{Scheme: urlObj.Scheme, Host: urlObj.Host, Path: "/robots.txt"}
+	defaultSitemapURL := &url.URL{Scheme: urlObj.Scheme, Host: urlObj.Host, Path: "/sitemap.xml"}
+
+	data, err := d.get(ctx, robotsURL.String())
+	if err != nil {
+		logrus.WithField("URL", robotsURL.String()).WithError(err).Debug("Failed to get robots.txt.")
+		return []string{defaultSitemapURL.String()}, nil
+	}
+
+	var sitemapURLs []string
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for scanner.Scan() {
+		key, val, ok := strings.Cut(scanner.Text(), ":")
+		if !ok || !strings.EqualFold(strings.TrimSpace(key), "sitemap") {
+			continue
+		}
+
+		// Sitemap URLs may be relative in the wild.
+		locObj, err := url.Parse(strings.TrimSpace(val))
+		if err != nil {
+			continue
+		}
+		sitemapURLs = append(sitemapURLs, robotsURL.ResolveReference(locObj).String())
+	}
+
+	if len(sitemapURLs) == 0 {
+		return []string{defaultSitemapURL.String()}, nil
+	}
+
+	return sitemapURLs, nil
+}
@@ -0,0 +1,231 @@
+package extract
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/PuerkitoBio/goquery"
+	"github.com/andybalholm/cascadia"
+	"github.com/antchfx/htmlquery"
+	"github.com/antchfx/xpath"
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+	"golang.org/x/net/html"
+	"gopkg.in/yaml.v3"
+)
+
+// Rule extracts a field from the HTML document by either a CSS selector or an
+// XPath expression.
+type Rule struct {
+	// CSS: The CSS selector of the elements, such as `.product .price`.
+	CSS string `yaml:"css"`
+	// XPath: The XPath expression of the elements or attributes, such as
+	// `//meta[@name="author"]/@content`.
+	XPath string `yaml:"xpath"`
+	// Attr: The attribute of the elements to extract, or the text content if empty.
+	Attr string `yaml:"attr"`
+	// Multiple: Whether to extract the values of all matches as a list, or the
+	// value of the first match only.
+	Multiple bool `yaml:"multiple"`
+
+	once     sync.Once
+	err      error
+	selector cascadia.Selector
+}
+
+// Rules are the extraction rules keyed by the field name.
+type Rules map[string]*Rule
+
+// rulesFile is the layout of the YAML rules file.
+type rulesFile struct {
+	Fields Rules `yaml:"fields"`
+}
+
+// LoadRules loads the extraction rules from the YAML file, such as:
+//
+//	fields:
+//	  title:
+//	    css: h1
+//	  price:
+//	    css: .price
+//	    attr: data-value
+//	  authors:
+//	    xpath: //a[@rel="author"]
+//	    multiple: true
+func LoadRules(filePath string) (Rules, error) {
+	data, err := os.ReadFile(filePath)
+	if err != nil {
+		return nil, errors.WithMessage(err, "failed to read rules file")
+	}
+
+	decoder := yaml.NewDecoder(bytes.NewReader(data))
+	decoder.KnownFields(true)
+
+	var file rulesFile
+	if err := decoder.Decode(&file); err != nil {
+		return nil, errors.WithMessagef(err, "invalid rules file %v", filePath)
+	}
+
+	if err := file.Fields.Compile(); err != nil {
+		return nil, errors.WithMessagef(err, "invalid rules file %v", filePath)
+	}
+
+	return file.Fields, nil
+}
+
+// Compile compiles the CSS selectors and XPath expressions of the rules, the
+// first invalid one is returned as an error.
+func (rs Rules) Compile() error {
+	for _, name := range rs.names() {
+		if err := rs[name].compile(); err != nil {
+			return errors.WithMessagef(err, "field %q", name)
+		}
+	}
+
+	return nil
+}
+
+// Extract extracts the fields from the HTML document. The value of each field
+// is a string, or a list of strings for multiple values, and the fields without
+// any match or with invalid rules are omitted.
+func (rs Rules) Extract(doc *goquery.Document) map[string]any {
+	if len(rs) == 0 || len(doc.Nodes) == 0 {
+		return nil
+	}
+
+	fields := make(map[string]any)
+	for name, rule := range rs {
+		if err := rule.compile(); err != nil {
+			logrus.WithField("field", name).WithError(err).Debug("Invalid extraction rule skipped.")
+			continue
+		}
+
+		values := rule.extract(doc)
+		switch {
+		case len(values) == 0:
+		case rule.Multiple:
+			fields[name] = values
+		default:
+			fields[name] = values[0]
+		}
+	}
+
+	if len(fields) == 0 {
+		return nil
+	}
+
+	return fields
+}
+
+func (rs Rules) names() []string {
+	names := make([]string, 0, len(rs))
+	for name := range rs {
+		names = append(names, name)
+	}
+
+	sort.Strings(names)
+	return names
+}
+
+// compile compiles the rule once, which is safe for concurrent use.
+func (r *Rule) compile() error {
+	if r == nil {
+		return errors.New("empty rule")
+	}
+
+	r.once.Do(func() {
+		switch {
+		case len(r.CSS) > 0 && len(r.XPath) > 0:
+			r.err = errors.New("either css or xpath expected, not both")
+		case len(r.CSS) > 0:
+			r.selector, r.err = cascadia.Compile(r.CSS)
+			r.err = errors.WithMessagef(r.err, "invalid CSS selector %q", r.CSS)
+		case len(r.XPath) > 0:
+			_, r.err = xpath.Compile(r.XPath)
+			r.err = errors.WithMessagef(r.err, "invalid XPath expression %q", r.XPath)
+		default:
+			r.err = errors.New("either css or xpath expected")
+		}
+	})
+
+	return r.err
+}
+
+// extract extracts the non-empty values of the matches.
+func (r *Rule) extract(doc *goquery.Document) []string {
+	if r.selector != nil {
+		var values []string
+		doc.FindMatcher(r.selector).Each(func(i int, s *goquery.Selection) {
+			values = r.appendValue(values, s.Nodes[0])
+		})
+		return values
+	}
+
+	// The compiled expression keeps the query state during evaluation, so it is
+	// compiled per evaluation to be safe for concurrent use.
+	expr, err := xpath.Compile(r.XPath)
+	if err != nil {
+		return nil
+	}
+
+	result := expr.Evaluate(htmlquery.CreateXPathNavigator(doc.Nodes[0]))
+
+	iter, ok := result.(*xpath.NodeIterator)
+	if !ok {
+		// Functions such as `string()` and `count()` evaluate to scalars.
+		if value := normalizeSpace(fmt.Sprint(result)); len(value) > 0 {
+			return []string{value}
+		}
+		return nil
+	}
+
+	var values []string
+	for iter.MoveNext() {
+		nav, ok := iter.Current().(*htmlquery.NodeNavigator)
+		if !ok {
+			continue
+		}
+
+		// Attribute nodes selected such as `@href` are valued by themselves.
+		if nav.NodeType() == xpath.AttributeNode {
+			if value := normalizeSpace(nav.Value()); len(value) > 0 {
+				values = append(values, value)
+			}
+			continue
+		}
+
+		values = r.appendValue(values, nav.Current())
+	}
+
+	return values
+}
+
+// appendValue appends the attribute or text value of the node if not empty.
+func (r *Rule) appendValue(values []string, node *html.Node) []string {
+	var value string
+	if len(r.Attr) > 0 {
+		for _, attr := range node.Attr {
+			if strings.EqualFold(attr.Key, r.Attr) {
+				value = strings.TrimSpace(attr.Val)
+				break
+			}
+		}
+	} else {
+		value = normalizeSpace(goquery.NewDocumentFromNode(node).Text())
+	}
+
+	if len(value) == 0 {
+		return values
+	}
+
+	return append(values, value)
+}
+
+// normalizeSpace collapses the whitespace of the text.
+func normalizeSpace(s string) string {
+	return strings.Join(strings.Fields(s), " ")
+}
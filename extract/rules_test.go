@@ -0,0 +1,95 @@
+package extract_test
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/PuerkitoBio/goquery"
+	"github.com/stretchr/testify/assert"
+	"github.com/wanliqun/web-fetcher/extract"
+)
+
+const page = `<html><head>
+<meta name="author" content="Jane">
+<title>Shop</title>
+</head><body>
+<h1>  Blue
+	Widget </h1>
+<span class="price" data-value="9.99">$9.99</span>
+<ul><li class="tag">a</li><li class="tag"> </li><li class="tag">b</li></ul>
+</body></html>`
+
+func TestExtract(t *testing.T) {
+	doc, err := goquery.NewDocumentFromReader(strings.NewReader(page))
+	assert.NoError(t, err)
+
+	rules := extract.Rules{
+		"title":   {CSS: "h1"},
+		"price":   {CSS: ".price", Attr: "data-value"},
+		"tags":    {CSS: ".tag", Multiple: true},
+		"author":  {XPath: `//meta[@name="author"]/@content`},
+		"heading": {XPath: "//h1"},
+		"numTags": {XPath: `count(//li[@class="tag"])`},
+		"missing": {CSS: ".none"},
+		"invalid": {CSS: "[", XPath: "//a"},
+	}
+	assert.Error(t, rules.Compile())
+
+	assert.Equal(t, map[string]any{
+		"title":   "Blue Widget",
+		"price":   "9.99",
+		"tags":    []string{"a", "b"},
+		"author":  "Jane",
+		"heading": "Blue Widget",
+		"numTags": "3",
+	}, rules.Extract(doc))
+}
+
+func TestExtractConcurrently(t *testing.T) {
+	rules := extract.Rules{
+		"author":  {XPath: `//meta[@name="author"]/@content`},
+		"tags":    {XPath: `//li[@class="tag"]`, Multiple: true},
+		"numTags": {XPath: `count(//li[@class="tag"])`},
+	}
+	assert.NoError(t, rules.Compile())
+
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			doc, err := goquery.NewDocumentFromReader(strings.NewReader(page))
+			assert.NoError(t, err)
+
+			for j := 0; j < 20; j++ {
+				assert.Equal(t, map[string]any{
+					"author": "Jane", "tags": []string{"a", "b"}, "numTags": "3",
+				}, rules.Extract(doc))
+			}
+		}()
+	}
+	wg.Wait()
+}
+
+func TestLoadRules(t *testing.T) {
+	filePath := filepath.Join(t.TempDir(), "rules.yaml")
+
+	os.WriteFile(filePath, []byte("fields:\n  price:\n    css: .price\n    attr: data-value\n"), 0644)
+	rules, err := extract.LoadRules(filePath)
+	if assert.NoError(t, err) {
+		assert.Equal(t, "data-value", rules["price"].Attr)
+	}
+
+	// Unknown keys are rejected.
+	os.WriteFile(filePath, []byte("fields:\n  price:\n    selector: .price\n"), 0644)
+	_, err = extract.LoadRules(filePath)
+	assert.Error(t, err)
+
+	os.WriteFile(filePath, []byte("fields:\n  price:\n    xpath: \"//span[\"\n"), 0644)
+	_, err = extract.LoadRules(filePath)
+	assert.ErrorContains(t, err, "invalid XPath expression")
+}
@@ -0,0 +1,147 @@
+package fetcher
+
+import (
+	"net/url"
+	"sort"
+	"strings"
+
+	"github.com/PuerkitoBio/purell"
+	"github.com/pkg/errors"
+	"github.com/wanliqun/web-fetcher/parser"
+)
+
+// TrackingParams are the well-known tracking query params, which don't change
+// the content of web pages.
+var TrackingParams = []string{
+	"utm_*", "gclid", "gclsrc", "dclid", "fbclid", "msclkid", "yclid", "igshid",
+	"mc_cid", "mc_eid", "_ga", "_gl", "_hsenc", "_hsmi", "mkt_tok",
+}
+
+// CanonicalPolicy determines how web page URLs are canonicalized, so that the
+// same web page is fetched and stored only once.
+type CanonicalPolicy struct {
+	// StripParams are the query params to be stripped such as tracking params,
+	// where a trailing `*` matches any suffix such as `utm_*`.
+	StripParams []string
+	// SortQuery sorts the query params by name.
+	SortQuery bool
+	// KeepFragment keeps the URL fragment, which is removed otherwise as it
+	// never reaches the web server.
+	KeepFragment bool
+	// CanonicalLink honors the `<link rel="canonical">` of web pages to detect
+	// duplicates, only if it links to the same host as the page.
+	CanonicalLink bool
+}
+
+// DefaultCanonicalPolicy returns the default canonicalization policy, which
+// strips tracking params, sorts query params and removes fragments.
+func DefaultCanonicalPolicy() CanonicalPolicy {
+	return CanonicalPolicy{StripParams: TrackingParams, SortQuery: true}
+}
+
+// Canonicalize canonicalizes the URL by the policy, on top of the safe
+// normalizations such as lowercasing the host and removing the default port.
+func (p *CanonicalPolicy) Canonicalize(rawURL string) (string, error) {
+	urlObj, err := url.Parse(rawURL)
+	if err != nil {
+		return "", errors.WithMessage(err, "invalid web URL")
+	}
+
+	return p.canonicalizeURL(urlObj), nil
+}
+
+func (p *CanonicalPolicy) canonicalizeURL(urlObj *url.URL) string {
+	urlObj = cloneURL(urlObj)
+
+	flags := purell.FlagsSafe
+	if !p.KeepFragment {
+		flags |= purell.FlagRemoveFragment
+	}
+	purell.NormalizeURL(urlObj, flags)
+
+	if len(urlObj.RawQuery) == 0 {
+		return urlObj.String()
+	}
+
+	// Query params are filtered and sorted as they are, rather than decoded and
+	// encoded again, which may change their meanings to the web server.
+	var params []string
+	for _, param := range strings.Split(urlObj.RawQuery, "&") {
+		if len(param) == 0 {
+			continue
+		}
+
+		name, _, _ := strings.Cut(param, "=")
+		if unescaped, err := url.QueryUnescape(name); err == nil {
+			name = unescaped
+		}
+
+		if !p.stripParam(name) {
+			params = append(params, param)
+		}
+	}
+
+	if p.SortQuery {
+		sort.SliceStable(params, func(i, j int) bool {
+			nameI, _, _ := strings.Cut(params[i], "=")
+			nameJ, _, _ := strings.Cut(params[j], "=")
+			return nameI < nameJ
+		})
+	}
+
+	urlObj.RawQuery = strings.Join(params, "&")
+	urlObj.ForceQuery = false
+
+	return urlObj.String()
+}
+
+// stripParam checks if the query param should be stripped.
+func (p *CanonicalPolicy) stripParam(name string) bool {
+	name = strings.ToLower(name)
+
+	for _, pattern := range p.StripParams {
+		pattern = strings.ToLower(pattern)
+
+		if prefix, ok := strings.CutSuffix(pattern, "*"); ok {
+			if strings.HasPrefix(name, prefix) {
+				return true
+			}
+		} else if name == pattern {
+			return true
+		}
+	}
+
+	return false
+}
+
+// canonicalLink returns the canonicalized URL of the `<link rel="canonical">`
+// within the web page if it links to the same host as the page.
+func (p *CanonicalPolicy) canonicalLink(pageUrlObj *url.URL, domParser *parser.Parser) (string, bool) {
+	href, ok := domParser.Document.Find(`link[rel="canonical"]`).First().Attr("href")
+	if !ok {
+		return "", false
+	}
+
+	hrefUrlObj, err := url.Parse(strings.TrimSpace(href))
+	if err != nil {
+		return "", false
+	}
+
+	linkUrlObj := pageUrlObj.ResolveReference(hrefUrlObj)
+	if !strings.EqualFold(linkUrlObj.Host, pageUrlObj.Host) {
+		return "", false
+	}
+
+	return p.canonicalizeURL(linkUrlObj), true
+}
+
+// cloneURL returns a copy of the URL.
+func cloneURL(urlObj *url.URL) *url.URL {
+	clone := *urlObj
+	if urlObj.User != nil {
+		user := *urlObj.User
+		clone.User = &user
+	}
+
+	return &clone
+}
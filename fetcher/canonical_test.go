@@ -0,0 +1,81 @@
+package fetcher_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/wanliqun/web-fetcher/fetcher"
+	"github.com/wanliqun/web-fetcher/types"
+)
+
+func TestCanonicalize(t *testing.T) {
+	policy := fetcher.DefaultCanonicalPolicy()
+
+	testCases := []struct {
+		url, expected string
+	}{
+		{"HTTP://Example.COM:80/a?b=2&a=1#top", "http://example.com/a?a=1&b=2"},
+		{"https://example.com/a?utm_source=x&id=1&UTM_Medium=y&fbclid=z", "https://example.com/a?id=1"},
+		{"https://example.com/a?utm_source=x", "https://example.com/a"},
+		{"https://example.com/a?q=a+b&q=c%26d", "https://example.com/a?q=a+b&q=c%26d"},
+	}
+
+	for _, tc := range testCases {
+		canonURL, err := policy.Canonicalize(tc.url)
+		assert.NoError(t, err)
+		assert.Equal(t, tc.expected, canonURL, tc.url)
+	}
+
+	policy.KeepFragment = true
+	canonURL, err := policy.Canonicalize("https://example.com/a#top")
+	assert.NoError(t, err)
+	assert.Equal(t, "https://example.com/a#top", canonURL)
+}
+
+func TestFetchDedupe(t *testing.T) {
+	var numRequests int
+	site := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		numRequests++
+
+		switch r.URL.Path {
+		case "/old":
+			http.Redirect(w, r, "/page", http.StatusMovedPermanently)
+		case "/print":
+			w.Header().Set("Content-Type", "text/html")
+			w.Write([]byte(`<html><head><link rel="canonical" href="/page"></head></html>`))
+		default:
+			w.Header().Set("Content-Type", "text/html")
+			w.Write([]byte(`<html><body>page</body></html>`))
+		}
+	}))
+	defer site.Close()
+
+	policy := fetcher.DefaultCanonicalPolicy()
+	policy.CanonicalLink = true
+
+	var results []*types.FetchResult
+	f := fetcher.NewFetcher(fetcher.RootDir(t.TempDir()), fetcher.Canonical(policy))
+	f.OnFetched(func(r *types.FetchResult) { results = append(results, r) })
+
+	// Requests of the same canonical URL are fetched only once.
+	assert.NoError(t, f.Fetch(site.URL+"/page?utm_source=x#top"))
+	assert.NoError(t, f.Fetch(site.URL+"/page"))
+	assert.Equal(t, 1, numRequests)
+
+	// Duplicates by redirection or canonical link are skipped.
+	assert.NoError(t, f.Fetch(site.URL+"/old"))
+	assert.NoError(t, f.Fetch(site.URL+"/print"))
+
+	if assert.Len(t, results, 3) {
+		assert.Equal(t, site.URL+"/page", results[0].URL)
+		assert.NotNil(t, results[0].Metadata)
+
+		for _, result := range results[1:] {
+			assert.NoError(t, result.Err)
+			assert.Equal(t, site.URL+"/page", result.DuplicateOf)
+			assert.Nil(t, result.Metadata)
+		}
+	}
+}
@@ -3,7 +3,11 @@ package fetcher
 import (
 	"context"
 	"net/http"
+	"sync"
 	"time"
+
+	"github.com/pkg/errors"
+	"github.com/wanliqun/web-fetcher/auth"
 )
 
 // ThrottleClient is a throttled HTTP client that limits the number of concurrent requests to
@@ -12,9 +16,20 @@ type ThrottleClient struct {
 	// Parallelism is the number of max allowed concurrent requests.
 	// Default 0 with unlimited concurrencies.
 	Parallelism int
+	// UserAgent is the `User-Agent` header sent along with the requests if not set.
+	UserAgent string
+	// HostDelay is the min delay between the requests to the same host.
+	// Default 0 without any delay.
+	HostDelay time.Duration
+	// Authenticator authorizes the requests by the per-host credentials if set.
+	Authenticator *auth.Authenticator
 
 	client *http.Client
 	ch     chan struct{}
+
+	mu sync.Mutex
+	// The time the next request to each host is allowed at.
+	nextRequestAt map[string]time.Time
 }
 
 func NewThrottleClient(parallelism int) *ThrottleClient {
@@ -23,6 +38,7 @@ func NewThrottleClient(parallelism int) *ThrottleClient {
 		client: &http.Client{
 			Timeout: 15 * time.Second,
 		},
+		nextRequestAt: make(map[string]time.Time),
 	}
 
 	if parallelism > 0 {
@@ -33,17 +49,83 @@ func NewThrottleClient(parallelism int) *ThrottleClient {
 }
 
 func (c *ThrottleClient) Do(ctx context.Context, req *http.Request) (*http.Response, error) {
+	if err := c.waitHost(ctx, req.URL.Host); err != nil {
+		return nil, err
+	}
+
+	if len(c.UserAgent) > 0 && len(req.Header.Get("User-Agent")) == 0 {
+		req.Header.Set("User-Agent", c.UserAgent)
+	}
+
+	if c.Authenticator != nil {
+		if err := c.Authenticator.Authorize(ctx, c.client, req); err != nil {
+			return nil, errors.WithMessage(err, "failed to authorize")
+		}
+	}
+
 	if c.Parallelism > 0 {
+		waitStart := time.Now()
+
 		select {
 		case <-ctx.Done():
 			return nil, ctx.Err()
 		case c.ch <- struct{}{}:
 		}
 
+		throttleWaitDuration.Observe(time.Since(waitStart).Seconds())
+
 		defer func() {
 			<-c.ch
 		}()
 	}
 
-	return c.client.Do(req)
+	ctx, span := startSpan(ctx, "http.request", attrURL.String(req.URL.String()))
+	req = req.WithContext(withClientTrace(withTimingsTrace(ctx)))
+
+	httpInFlightRequests.Inc()
+	defer httpInFlightRequests.Dec()
+
+	start := time.Now()
+	resp, err := c.client.Do(req)
+	observeResponse(req, resp, err, time.Since(start).Seconds())
+
+	if err == nil {
+		span.SetAttributes(attrStatusCode.Int(resp.StatusCode))
+	}
+	endSpan(span, err)
+
+	return resp, err
+}
+
+// waitHost waits until the request to the host is allowed by the host delay.
+func (c *ThrottleClient) waitHost(ctx context.Context, host string) error {
+	if c.HostDelay <= 0 {
+		return nil
+	}
+
+	// Reserve the time slot for the request in advance, so that concurrent
+	// requests to the same host are spaced out by the delay.
+	c.mu.Lock()
+	now := time.Now()
+	requestAt := c.nextRequestAt[host]
+	if requestAt.Before(now) {
+		requestAt = now
+	}
+	c.nextRequestAt[host] = requestAt.Add(c.HostDelay)
+	c.mu.Unlock()
+
+	wait := time.Until(requestAt)
+	if wait <= 0 {
+		return nil
+	}
+
+	timer := time.NewTimer(wait)
+	defer timer.Stop()
+
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
 }
@@ -3,19 +3,24 @@ package fetcher
 import (
 	"bytes"
 	"context"
+	"crypto/sha256"
+	"encoding/hex"
 	"io"
 	"net/http"
 	"net/url"
-	"os"
 	"strings"
 	"sync"
 	"time"
 
 	"github.com/pkg/errors"
 	"github.com/sirupsen/logrus"
+	"github.com/wanliqun/web-fetcher/auth"
+	"github.com/wanliqun/web-fetcher/extract"
 	"github.com/wanliqun/web-fetcher/parser"
 	"github.com/wanliqun/web-fetcher/store"
 	"github.com/wanliqun/web-fetcher/types"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/trace"
 )
 
 // FetcherConfig modifies fetcher behaviors.
@@ -25,8 +30,51 @@ type FetcherConfig struct {
 	// Mirror downloads asset resources (such as images, CSS, and JavaScript)
 	// within the HTML page to a local folder.
 	Mirror bool
-	// Further configurations such as HTTP configurations eg., user agent, proxy,
-	// and timeout may be considered in future enhancements.
+	// Readable extracts the main content of the HTML page with the boilerplate
+	// stripped, and saves it as Markdown and plain text files.
+	Readable bool
+	// Snapshot keeps the content of each fetch as a versioned snapshot, which is
+	// the HTML document for HTML pages.
+	Snapshot bool
+	// History keeps complete versions of each fetch, including the document,
+	// metadata, readable content and assets, within the history.
+	History bool
+	// Retention determines which versions to be kept in the history.
+	Retention store.RetentionPolicy
+	// NormalizeHash computes the content hash over the normalized DOM, which
+	// ignores whitespace, comments and volatile elements, rather than the raw
+	// HTML content.
+	NormalizeHash bool
+	// VolatileSelectors are CSS selectors of the elements to be ignored for the
+	// normalized content hash, such as timestamps and ads.
+	VolatileSelectors []string
+	// TrackSelectors are CSS selectors of the elements whose content hashes
+	// are tracked individually to detect selector-scoped changes.
+	TrackSelectors []string
+	// RootDir is the root directory of the file store, which defaults to the
+	// current working directory.
+	RootDir string
+	// Parallelism is the number of max allowed concurrent HTTP requests, 0 for
+	// unlimited.
+	Parallelism int
+	// Timeout is the time limit of each HTTP request, 0 for the default.
+	Timeout time.Duration
+	// UserAgent is the `User-Agent` header sent along with HTTP requests unless
+	// overridden by the request headers.
+	UserAgent string
+	// HostDelay is the min delay between HTTP requests to the same host, as a
+	// politeness to the web sites.
+	HostDelay time.Duration
+	// Canonical determines how web page URLs are canonicalized, so that each web
+	// page is fetched only once per run and stored under a stable name.
+	Canonical CanonicalPolicy
+	// ExtractRules are the custom extraction rules of the fields to be extracted
+	// from the HTML page into the metadata.
+	ExtractRules extract.Rules
+	// Sanitize sanitizes the HTML document by the profile before saved, such as
+	// stripping scripts and trackers for safe offline viewing, which applies to
+	// the snapshots and history versions as well.
+	Sanitize *parser.SanitizeProfile
 }
 
 // FetcherOption builder option on a fetcher.
@@ -39,20 +87,47 @@ type Fetcher struct {
 	client    *ThrottleClient
 	callbacks []FetchedCallback
 	wg        *sync.WaitGroup
+	// Seen canonical URLs of the requested or redirected web pages.
+	seen sync.Map
+	// Journal to durably track the states of fetch requests if set.
+	journal *store.Journal
+	// Tracer to trace the fetch pipeline stages.
+	tracer trace.Tracer
+	// Cookie jar of the HTTP client if set.
+	jar http.CookieJar
+	// Authenticator to authorize the HTTP requests if set.
+	authenticator *auth.Authenticator
+	// Content handlers keyed by the media type.
+	handlers map[string]ContentHandler
+	// Middlewares hooked into the fetch pipeline.
+	middlewares []*Middleware
+	// Context of the fetcher, whose cancellation stops the fetching.
+	ctx context.Context
 }
 
 // NewFetcher creates a fetcher instance with builder options.
 func NewFetcher(options ...FetcherOption) *Fetcher {
 	f := &Fetcher{
-		FetcherConfig: &FetcherConfig{},
-		client:        NewThrottleClient(0),
+		FetcherConfig: &FetcherConfig{Canonical: DefaultCanonicalPolicy()},
 		wg:            &sync.WaitGroup{},
+		tracer:        otel.GetTracerProvider().Tracer(tracerName),
+		ctx:           context.Background(),
 	}
+	f.handlers = f.defaultHandlers()
 
 	for _, option := range options {
 		option(f)
 	}
 
+	if f.client == nil {
+		f.client = NewThrottleClient(f.Parallelism)
+		if f.Timeout > 0 {
+			f.client.client.Timeout = f.Timeout
+		}
+		f.client.UserAgent, f.client.HostDelay = f.UserAgent, f.HostDelay
+		f.client.Authenticator, f.client.client.Jar = f.authenticator, f.jar
+	}
+
 	return f
 }
 
@@ -78,16 +153,195 @@ func Mirror(a ...bool) FetcherOption {
 	}
 }
 
+// Readable turns on readable main content extraction.
+func Readable(a ...bool) FetcherOption {
+	return func(f *Fetcher) {
+		if len(a) > 0 {
+			f.Readable = a[0]
+		} else {
+			f.Readable = true
+		}
+	}
+}
+
+// Snapshot turns on versioned snapshots.
+func Snapshot(a ...bool) FetcherOption {
+	return func(f *Fetcher) {
+		if len(a) > 0 {
+			f.Snapshot = a[0]
+		} else {
+			f.Snapshot = true
+		}
+	}
+}
+
+// History turns on the history mode with the retention policy.
+func History(retention store.RetentionPolicy) FetcherOption {
+	return func(f *Fetcher) {
+		f.History = true
+		f.Retention = retention
+	}
+}
+
+// NormalizeHash turns on the content hash over the normalized DOM, with the
+// elements matched by the volatile selectors ignored.
+func NormalizeHash(volatileSelectors ...string) FetcherOption {
+	return func(f *Fetcher) {
+		f.NormalizeHash = true
+		f.VolatileSelectors = volatileSelectors
+	}
+}
+
+// TrackSelectors tracks content changes of the elements matched by the selectors.
+func TrackSelectors(selectors ...string) FetcherOption {
+	return func(f *Fetcher) {
+		f.TrackSelectors = selectors
+	}
+}
+
+// Journal durably tracks the states of fetch requests with the journal, pages
+// already done within the journal will be skipped.
+func Journal(j *store.Journal) FetcherOption {
+	return func(f *Fetcher) {
+		f.journal = j
+	}
+}
+
+// RootDir stores the fetched web pages within the root directory.
+func RootDir(dir string) FetcherOption {
+	return func(f *Fetcher) {
+		f.RootDir = dir
+	}
+}
+
+// Parallelism limits the number of concurrent HTTP requests.
+func Parallelism(n int) FetcherOption {
+	return func(f *Fetcher) {
+		f.Parallelism = n
+	}
+}
+
+// Timeout limits the time of each HTTP request.
+func Timeout(d time.Duration) FetcherOption {
+	return func(f *Fetcher) {
+		f.Timeout = d
+	}
+}
+
+// UserAgent sends the user agent along with HTTP requests.
+func UserAgent(ua string) FetcherOption {
+	return func(f *Fetcher) {
+		f.UserAgent = ua
+	}
+}
+
+// HostDelay delays HTTP requests to the same host by the min interval.
+func HostDelay(d time.Duration) FetcherOption {
+	return func(f *Fetcher) {
+		f.HostDelay = d
+	}
+}
+
+// CookieJar keeps the cookies of HTTP requests within the jar, such as the
+// session cookies captured by form login.
+func CookieJar(jar http.CookieJar) FetcherOption {
+	return func(f *Fetcher) {
+		f.jar = jar
+	}
+}
+
+// Auth authorizes HTTP requests with the per-host credentials.
+func Auth(a *auth.Authenticator) FetcherOption {
+	return func(f *Fetcher) {
+		f.authenticator = a
+	}
+}
+
+// Canonical canonicalizes web page URLs by the policy rather than the default one.
+func Canonical(policy CanonicalPolicy) FetcherOption {
+	return func(f *Fetcher) {
+		f.Canonical = policy
+	}
+}
+
+// ExtractRules extracts the custom fields from the HTML page by the rules.
+func ExtractRules(rules extract.Rules) FetcherOption {
+	return func(f *Fetcher) {
+		f.ExtractRules = rules
+	}
+}
+
+// Sanitize sanitizes the HTML documents by the profile before saved.
+func Sanitize(profile *parser.SanitizeProfile) FetcherOption {
+	return func(f *Fetcher) {
+		f.Sanitize = profile
+	}
+}
+
+// TracerProvider traces the fetch pipeline stages with the tracer provider rather
+// than the global one.
+func TracerProvider(tp trace.TracerProvider) FetcherOption {
+	return func(f *Fetcher) {
+		f.tracer = tp.Tracer(tracerName)
+	}
+}
+
+// HTTPClient sends the HTTP requests by the throttle client rather than a new
+// one, so that the concurrency and host delay limits are shared among fetchers,
+// such as those of the jobs of a server. The client settings of the fetcher such
+// as the parallelism are ignored then.
+func HTTPClient(c *ThrottleClient) FetcherOption {
+	return func(f *Fetcher) {
+		f.client = c
+	}
+}
+
+// Context binds the fetcher to the context, once done the HTTP requests in flight
+// are aborted and no more web pages will be fetched.
+func Context(ctx context.Context) FetcherOption {
+	return func(f *Fetcher) {
+		f.ctx = ctx
+	}
+}
+
 // Fetch starts scraping by HTTP requesting to the specified URL.
 // Fetching result will be notified by callback functions if registered.
 func (f *Fetcher) Fetch(url string) error {
+	return f.FetchRequest(&types.FetchRequest{URL: url})
+}
+
+// FetchRequest is like Fetch, but with per-request overrides such as HTTP headers.
+// The request URL is canonicalized in place, and the request is skipped if the
+// canonical URL has been seen before. The context error is returned once the
+// context of the fetcher is done.
+func (f *Fetcher) FetchRequest(req *types.FetchRequest) error {
+	if err := f.ctx.Err(); err != nil {
+		return errors.WithMessage(err, "fetcher stopped")
+	}
+
+	if !f.see(req) {
+		logrus.WithField("URL", req.URL).Debug("Web page skipped due to already seen.")
+		return nil
+	}
+
+	if f.journal != nil {
+		if state, _ := f.journal.State(req.URL); state == store.JournalStateDone {
+			logrus.WithField("URL", req.URL).Debug("Web page skipped due to already done.")
+			return nil
+		}
+
+		f.recordJournal(&store.JournalEntry{
+			URL: req.URL, State: store.JournalStatePending, Request: req,
+		})
+	}
+
 	f.wg.Add(1)
 	if f.Async {
-		go f.scrape(url)
+		go f.scrape(req)
 		return nil
 	}
 
-	return f.scrape(url)
+	return f.scrape(req)
 }
 
 // Wait blocks until all scraping jobs are done.
@@ -95,28 +349,85 @@ func (f *Fetcher) Wait() {
 	f.wg.Wait()
 }
 
-func (f *Fetcher) scrape(strURL string) error {
-	result := &types.FetchResult{URL: strURL}
+func (f *Fetcher) scrape(fetchReq *types.FetchRequest) error {
+	strURL := fetchReq.URL
+
+	result := &types.FetchResult{
+		URL: strURL, Request: fetchReq, StartedAt: time.Now(), Timings: &types.Timings{},
+	}
+	// Type of the fetch error for metrics if any.
+	var errType string
+
+	ctx, span := f.tracer.Start(f.ctx, "scrape", trace.WithAttributes(attrURL.String(strURL)))
+	ctx = withTimings(ctx, result.Timings)
+
+	f.recordJournal(&store.JournalEntry{URL: strURL, State: store.JournalStateInProgress})
 
 	defer func() {
+		result.FinishedAt = time.Now()
+
+		pageDuration.Observe(result.FinishedAt.Sub(result.StartedAt).Seconds())
+
+		switch {
+		case result.Err != nil:
+			pagesTotal.WithLabelValues(resultError).Inc()
+			pageErrorsTotal.WithLabelValues(errType).Inc()
+
+			f.recordJournal(&store.JournalEntry{
+				URL: strURL, State: store.JournalStateFailed, Error: result.Err.Error(),
+			})
+		case len(result.DuplicateOf) > 0:
+			pagesTotal.WithLabelValues(resultDuplicate).Inc()
+			f.recordJournal(&store.JournalEntry{URL: strURL, State: store.JournalStateDone})
+		case len(result.SkippedBy) > 0:
+			pagesTotal.WithLabelValues(resultSkipped).Inc()
+			f.recordJournal(&store.JournalEntry{URL: strURL, State: store.JournalStateDone})
+		default:
+			pagesTotal.WithLabelValues(resultSuccess).Inc()
+			f.recordJournal(&store.JournalEntry{URL: strURL, State: store.JournalStateDone})
+		}
+
+		endSpan(span, result.Err)
+
 		f.handleOnFetched(result)
 		f.wg.Done()
 	}()
 
 	urlObj, err := url.Parse(strURL)
 	if err != nil {
+		errType = errTypeInvalidURL
 		result.Err = errors.WithMessage(err, "invalid web URL")
 		return result.Err
 	}
 
 	req, err := http.NewRequest(http.MethodGet, urlObj.String(), nil)
 	if err != nil {
+		errType = errTypeRequest
 		result.Err = errors.WithMessage(err, "failed to create HTTP request")
 		return result.Err
 	}
 
-	result.Response, err = f.client.Do(context.Background(), req)
+	for key, val := range fetchReq.Headers {
+		req.Header.Set(key, val)
+	}
+
+	err = f.runHooks("before request", result, func(mw *Middleware) error {
+		if mw.BeforeRequest == nil {
+			return nil
+		}
+		return mw.BeforeRequest(ctx, req, result)
+	})
+	if errors.Is(err, ErrSkip) {
+		return nil
+	} else if err != nil {
+		errType = errTypeMiddleware
+		result.Err = err
+		return result.Err
+	}
+
+	result.Response, err = f.client.Do(ctx, req)
 	if err != nil {
+		errType = errTypeRequest
 		result.Err = errors.WithMessage(err, "failed to do HTTP request")
 		return result.Err
 	}
@@ -125,58 +436,281 @@ func (f *Fetcher) scrape(strURL string) error {
 	// Check for successful status codes (2xx range).
 	// Redirection status code 301 and 302 may be supported for future enhancement.
 	if statusCode := result.Response.StatusCode; statusCode < 200 || statusCode > 299 {
+		errType = errTypeHTTPStatus
 		result.Err = errors.Errorf("bad HTTP status code: %d", statusCode)
 		return result.Err
 	}
 
-	// Create file store.
-	urlBaseName := constructURLBaseName(result.Response.Request.URL)
+	err = f.runHooks("after response", result, func(mw *Middleware) error {
+		if mw.AfterResponse == nil {
+			return nil
+		}
+		return mw.AfterResponse(ctx, result.Response, result)
+	})
+	if errors.Is(err, ErrSkip) {
+		return nil
+	} else if err != nil {
+		errType = errTypeMiddleware
+		result.Err = err
+		return result.Err
+	}
+
+	// Skip the web page redirected to if already seen.
+	docURL := f.Canonical.canonicalizeURL(result.Response.Request.URL)
+	if docURL != strURL {
+		if _, loaded := f.seen.LoadOrStore(docURL, struct{}{}); loaded {
+			logrus.WithField("URL", strURL).WithField("duplicateOf", docURL).
+				Debug("Web page skipped due to redirected to a seen one.")
+			result.DuplicateOf = docURL
+			return nil
+		}
+	}
 
-	fileStore, err := store.NewFileStore(os.Getenv("ROOT_STORE_DIR"), urlBaseName)
+	// Create file store named after the canonical URL.
+	docUrlObj, err := url.Parse(docURL)
 	if err != nil {
+		docUrlObj = result.Response.Request.URL
+	}
+
+	fileStore, err := newFileStore(f.RootDir, docUrlObj)
+	if err != nil {
+		errType = errTypeStore
 		result.Err = errors.WithMessage(err, "failed to new file store")
 		return result.Err
 	}
+	span.SetAttributes(attrDocName.String(fileStore.DocName()))
+
+	// Lock the document so that concurrent fetches of the same web page don't
+	// interleave their writes.
+	unlock := fileStore.Lock()
+	defer unlock()
 
 	// Process response body.
-	result.Metadata, err = f.process(fileStore, result.Response)
-	if err != nil {
-		result.Err = errors.WithMessage(err, "failed to process HTML response")
+	err = f.process(ctx, fileStore, result)
+	if errors.Is(err, ErrSkip) {
+		return nil
+	} else if err != nil {
+		errType = errTypeProcess
+		result.Err = errors.WithMessage(err, "failed to process response")
+		return result.Err
+	}
+
+	// Skipped duplicates are not stored.
+	if len(result.DuplicateOf) > 0 {
+		return nil
+	}
+
+	// Custom content handlers may store nothing to be indexed.
+	if docPath := storedDocPath(result.Files); len(docPath) > 0 {
+		if err := fileStore.IndexURL(docURL, docPath); err != nil {
+			logrus.WithField("URL", docURL).WithError(err).Warn("Failed to index URL.")
+		}
+	}
+
+	err = f.runHooks("after save", result, func(mw *Middleware) error {
+		if mw.AfterSave == nil {
+			return nil
+		}
+		return mw.AfterSave(ctx, result)
+	})
+	if err != nil && !errors.Is(err, ErrSkip) {
+		errType = errTypeMiddleware
+		result.Err = err
 		return result.Err
 	}
 
 	return nil
 }
 
-func (f *Fetcher) process(fs *store.FileStore, resp *http.Response) (*types.Metadata, error) {
-	// Parse `Content-Type` from header.
-	contentType := resp.Header.Get("Content-Type")
-	if !strings.Contains(strings.ToLower(contentType), "html") {
-		return nil, errors.Errorf(
-			"response content type expected HTML got %s", contentType,
-		)
+// storedDocPath returns the path of the document file stored, such as the HTML
+// document or the content file of non-HTML resources, empty if none.
+func storedDocPath(files *types.StoredFiles) string {
+	switch {
+	case files == nil:
+		return ""
+	case len(files.HTML) > 0:
+		return files.HTML
+	default:
+		return files.Content
+	}
+}
+
+// newFileStore creates the file store for the web page URL within the root directory.
+func newFileStore(rootDir string, urlObj *url.URL) (*store.FileStore, error) {
+	return store.NewFileStore(rootDir, constructURLBaseName(urlObj))
+}
+
+// LoadMetadata loads the stored metadata of the web page URL, nil is returned
+// if the web page has not been fetched before.
+func (f *Fetcher) LoadMetadata(strURL string) (*types.Metadata, error) {
+	fileStore, err := f.OpenFileStore(strURL)
+	if err != nil {
+		return nil, err
+	}
+
+	return fileStore.LoadMetadata()
+}
+
+// Client returns the HTTP client of the fetcher, which is shared by the other
+// requests to the fetched sites so that politeness and authentication apply.
+func (f *Fetcher) Client() *ThrottleClient {
+	return f.client
+}
+
+// OpenFileStore opens the file store of the web page URL.
+func (f *Fetcher) OpenFileStore(strURL string) (*store.FileStore, error) {
+	return openFileStore(f.RootDir, &f.Canonical, strURL)
+}
+
+// OpenFileStore opens the file store of the web page URL within the root directory,
+// with the URL canonicalized by the default policy.
+func OpenFileStore(rootDir, strURL string) (*store.FileStore, error) {
+	policy := DefaultCanonicalPolicy()
+	return openFileStore(rootDir, &policy, strURL)
+}
+
+func openFileStore(rootDir string, policy *CanonicalPolicy, strURL string) (*store.FileStore, error) {
+	canonURL, err := policy.Canonicalize(strURL)
+	if err != nil {
+		return nil, err
+	}
+
+	urlObj, err := url.Parse(canonURL)
+	if err != nil {
+		return nil, errors.WithMessage(err, "invalid web URL")
+	}
+
+	fileStore, err := newFileStore(rootDir, urlObj)
+	if err != nil {
+		return nil, errors.WithMessage(err, "failed to new file store")
+	}
+
+	return fileStore, nil
+}
+
+// process downloads the response body, and handles the content by the content
+// handler of the media type detected.
+func (f *Fetcher) process(ctx context.Context, fs *store.FileStore, result *types.FetchResult) error {
+	resp := result.Response
+
+	// Download the response body.
+	var rawContent []byte
+	err := trackTime(&result.Timings.Download, func() (err error) {
+		rawContent, err = io.ReadAll(resp.Body)
+		return err
+	})
+	result.NumBytes += int64(len(rawContent))
+	if err != nil {
+		return errors.WithMessage(err, "failed to read response body")
 	}
 
-	buf := bytes.NewBuffer(nil)
-	teeReader := io.TeeReader(resp.Body, buf)
+	content := &Content{
+		MediaType: detectMediaType(resp.Header.Get("Content-Type"), rawContent),
+		Data:      rawContent,
+		Store:     fs,
+		Result:    result,
+	}
+
+	return f.contentHandler(content.MediaType)(ctx, content)
+}
+
+// handleHTML parses the HTML content, and fills the metadata and stored files
+// into the fetch result.
+func (f *Fetcher) handleHTML(ctx context.Context, c *Content) error {
+	fs, result, rawContent := c.Store, c.Result, c.Data
+	resp, timings := result.Response, result.Timings
 
 	// Prepare HTML DOM parser.
-	domParser, err := parser.NewParser(teeReader)
+	var domParser *parser.Parser
+	_, parseSpan := startSpan(ctx, "parse", attrURL.String(result.URL))
+	err := trackTime(&timings.Parse, func() (err error) {
+		domParser, err = parser.NewParser(bytes.NewReader(rawContent))
+		return err
+	})
+	endSpan(parseSpan, err)
 	if err != nil {
-		return nil, errors.WithMessage(err, "failed to new DOM parser")
+		return errors.WithMessage(err, "failed to new DOM parser")
+	}
+
+	// Skip the web page whose canonical link has been seen, or mark it as seen
+	// so that it won't be fetched again.
+	if f.Canonical.CanonicalLink {
+		linkURL, ok := f.Canonical.canonicalLink(resp.Request.URL, domParser)
+		if ok && linkURL != f.Canonical.canonicalizeURL(resp.Request.URL) {
+			if _, loaded := f.seen.LoadOrStore(linkURL, struct{}{}); loaded {
+				logrus.WithField("URL", result.URL).WithField("duplicateOf", linkURL).
+					Debug("Web page skipped due to canonical link seen.")
+				result.DuplicateOf = linkURL
+				return nil
+			}
+		}
+	}
+
+	err = f.runHooks("after parse", result, func(mw *Middleware) error {
+		if mw.AfterParse == nil {
+			return nil
+		}
+		return mw.AfterParse(ctx, domParser, result)
+	})
+	if err != nil {
+		return err
+	}
+
+	files := &types.StoredFiles{
+		HTML:     fs.HtmlDocPath(),
+		Metadata: fs.MetadataFilePath(),
+	}
+	if f.Readable {
+		files.Markdown, files.Text = fs.MarkdownFilePath(), fs.TextFilePath()
 	}
 
 	// Process metadata.
-	metadata, err := f.processMetadata(fs, domParser)
+	metadata, err := f.processMetadata(ctx, fs, domParser, resp.Request.URL, rawContent)
 	if err != nil {
-		return nil, errors.WithMessage(err, "failed to process metadata")
+		return errors.WithMessage(err, "failed to process metadata")
+	}
+	metadata.ContentType = c.MediaType
+	// Fields are extracted before the asset URLs are rewritten for mirroring.
+	metadata.Fields = f.ExtractRules.Extract(domParser.Document)
+
+	if len(metadata.Version) > 0 {
+		files.Snapshot = fs.SnapshotFilePath(metadata.Version)
+	}
+
+	// Assets are downloaded into the version directory in history mode, so that
+	// they won't be overwritten by later fetches.
+	assetStore := fs
+	if f.History {
+		assetStore = fs.VersionStore(metadata.Version)
+	}
+
+	// Sanitize the document before mirroring, so that the stripped assets are
+	// never downloaded.
+	baseUrlObj := determineBaseURL(resp.Request.URL, domParser)
+	domParser.Sanitize(f.Sanitize, resp.Request.URL, baseUrlObj)
+
+	// Nothing is stored or downloaded until the middlewares agree to save.
+	err = f.runHooks("before save", result, func(mw *Middleware) error {
+		if mw.BeforeSave == nil {
+			return nil
+		}
+		return mw.BeforeSave(ctx, domParser, result)
+	})
+	if err != nil {
+		return err
+	}
+
+	if err := f.saveDerivedContent(ctx, fs, domParser, metadata); err != nil {
+		return err
 	}
 
 	// Process mirror downloading.
-	if f.Mirror {
+	if f.mirror(result.Request) {
 		var assets []*types.EmbeddedAsset
-		baseUrlObj := determineBaseURL(resp.Request.URL, domParser)
+		assetsByURL := make(map[string]*types.EmbeddedAsset)
 
+		// Assets are collected at first, and replaced once downloaded so that the
+		// file extensions can be inferred from the content types.
 		domParser.ReplaceAssets(func(assetURL string) (string, bool) {
 			// Filter invalid asset URL
 			assetUrlObj, err := url.Parse(assetURL)
@@ -193,59 +727,236 @@ func (f *Fetcher) process(fs *store.FileStore, resp *http.Response) (*types.Meta
 					"assetURLHost": assetAbsUrlObj.Host,
 					"pageURLHost":  resp.Request.URL.Host,
 				}).Debug("Asset skipped due to not of the same domain host.")
+				result.NumSkippedAssets++
 				return "", false
 			}
 
-			as := &types.EmbeddedAsset{AbsURL: assetAbsUrlObj}
-			assets = append(assets, as)
+			if _, ok := assetsByURL[assetAbsUrlObj.String()]; !ok {
+				as := &types.EmbeddedAsset{AbsURL: assetAbsUrlObj}
+				assetsByURL[assetAbsUrlObj.String()] = as
+				assets = append(assets, as)
+			}
+			return "", false
+		})
+
+		err := trackTime(&timings.Assets, func() error {
+			return f.processAssets(ctx, assets, assetStore, &result.NumBytes)
+		})
+		if err != nil {
+			return errors.WithMessage(err, "failed to process assets")
+		}
+
+		domParser.ReplaceAssets(func(assetURL string) (string, bool) {
+			assetUrlObj, err := url.Parse(assetURL)
+			if err != nil {
+				return "", false
+			}
+
+			as, ok := assetsByURL[baseUrlObj.ResolveReference(assetUrlObj).String()]
+			if !ok {
+				return "", false
+			}
 
 			asFileURL := url.URL{
 				Scheme: "file",
-				Path:   fs.AssetFilePath(as),
+				Path:   assetStore.AssetFilePath(as),
 			}
 			return asFileURL.String(), true
 		})
 
-		if err := f.processAssets(assets, fs); err != nil {
-			return nil, errors.WithMessage(err, "failed to process assets")
+		for _, as := range assets {
+			files.Assets = append(files.Assets, assetStore.AssetFilePath(as))
 		}
 	}
 
 	// Save HTML doc file.
-	if err := fs.SaveDoc(domParser.Document); err != nil {
-		return nil, errors.WithMessage(err, "failed to save HTML document")
+	err = traceStoreWrite(ctx, fs, "doc", func() error {
+		return fs.SaveDoc(domParser.Document)
+	})
+	if err != nil {
+		return errors.WithMessage(err, "failed to save HTML document")
 	}
 
-	return metadata, nil
-}
+	// Save metadata file.
+	if err := f.saveMetadata(ctx, fs, metadata, result); err != nil {
+		return err
+	}
 
-func (f *Fetcher) processAssets(assets []*types.EmbeddedAsset, fs *store.FileStore) error {
-	for _, as := range assets {
-		// Download the asset
-		req, err := http.NewRequest(http.MethodGet, as.AbsURL.String(), nil)
+	// Archive the version into history.
+	if f.History {
+		err := traceStoreWrite(ctx, fs, "version", func() error {
+			return f.archiveVersion(fs, metadata)
+		})
 		if err != nil {
-			return errors.WithMessage(err, "failed to create HTTP request")
+			return errors.WithMessage(err, "failed to archive version")
 		}
+	}
+
+	result.Metadata, result.Files = metadata, files
+
+	// Follow links for the remaining depth.
+	if result.Request.Depth > 0 {
+		f.followLinks(result.Request, resp.Request.URL, baseUrlObj, domParser)
+	}
+
+	return nil
+}
+
+// see canonicalizes the request URL in place and marks it as seen, which is false
+// if it has been seen before.
+func (f *Fetcher) see(req *types.FetchRequest) bool {
+	// Invalid URLs are left as they are to be reported by the fetch result.
+	if canonURL, err := f.Canonical.Canonicalize(req.URL); err == nil {
+		req.URL = canonURL
+	}
+
+	_, loaded := f.seen.LoadOrStore(req.URL, struct{}{})
+	return !loaded
+}
+
+// resetSeen forgets the seen URLs so that web pages can be fetched again.
+func (f *Fetcher) resetSeen() {
+	f.seen.Range(func(key, value any) bool {
+		f.seen.Delete(key)
+		return true
+	})
+}
+
+// recordJournal records the state transition to the journal if set.
+func (f *Fetcher) recordJournal(entry *store.JournalEntry) {
+	if f.journal == nil {
+		return
+	}
+
+	if err := f.journal.Record(entry); err != nil {
+		logrus.WithField("URL", entry.URL).WithError(err).Error("Failed to record journal.")
+	}
+}
+
+// mirror checks if mirror downloading is turned on for the request.
+func (f *Fetcher) mirror(req *types.FetchRequest) bool {
+	if req.Mirror != nil {
+		return *req.Mirror
+	}
+
+	return f.Mirror
+}
 
-		resp, err := f.client.Do(context.Background(), req)
+// followLinks fetches the unvisited links within the same domain host as the page,
+// with the same overrides of the request except for a decreased depth.
+func (f *Fetcher) followLinks(
+	req *types.FetchRequest, pageUrlObj, baseUrlObj *url.URL, domParser *parser.Parser) {
+
+	for _, link := range domParser.ExtractLinks() {
+		linkUrlObj, err := url.Parse(link)
 		if err != nil {
-			return errors.WithMessage(err, "failed to do HTTP request")
+			continue
+		}
+
+		linkAbsUrlObj := baseUrlObj.ResolveReference(linkUrlObj)
+
+		if linkAbsUrlObj.Scheme != "http" && linkAbsUrlObj.Scheme != "https" {
+			continue
+		}
+
+		if !strings.EqualFold(linkAbsUrlObj.Host, pageUrlObj.Host) {
+			continue
+		}
+
+		strLinkURL := f.Canonical.canonicalizeURL(linkAbsUrlObj)
+		if _, ok := f.seen.Load(strLinkURL); ok {
+			continue
 		}
-		defer resp.Body.Close()
 
-		logrus.WithField("URL", as.AbsURL.String()).Debug("Asset downloaded.")
+		logrus.WithField("URL", strLinkURL).Debug("Link followed.")
+
+		f.FetchRequest(&types.FetchRequest{
+			URL:     strLinkURL,
+			Headers: req.Headers,
+			Mirror:  req.Mirror,
+			Depth:   req.Depth - 1,
+			Tags:    req.Tags,
+		})
+	}
+}
+
+// processAssets downloads and saves the assets, with the downloaded bytes added
+// to numBytes.
+func (f *Fetcher) processAssets(ctx context.Context,
+	assets []*types.EmbeddedAsset, fs *store.FileStore, numBytes *int64) error {
 
-		as.DataReader = resp.Body
-		if err := fs.SaveAsset(as); err != nil {
-			return errors.WithMessage(err, "failed to save asset")
+	for _, as := range assets {
+		if err := f.processAsset(ctx, as, fs, numBytes); err != nil {
+			return err
 		}
 	}
 
 	return nil
 }
 
-func (f *Fetcher) processMetadata(
-	fs *store.FileStore, parser *parser.Parser) (*types.Metadata, error) {
+// processAsset downloads and saves the asset.
+func (f *Fetcher) processAsset(ctx context.Context,
+	as *types.EmbeddedAsset, fs *store.FileStore, numBytes *int64) (err error) {
+
+	ctx, span := startSpan(ctx, "asset.download",
+		attrURL.String(as.AbsURL.String()), attrDocName.String(fs.DocName()),
+	)
+	defer func() { endSpan(span, err) }()
+
+	// Download the asset
+	req, err := http.NewRequest(http.MethodGet, as.AbsURL.String(), nil)
+	if err != nil {
+		return errors.WithMessage(err, "failed to create HTTP request")
+	}
+
+	// Asset requests are accounted within the asset phase rather than the HTTP
+	// timings of the page.
+	resp, err := f.client.Do(withTimings(ctx, nil), req)
+	if err != nil {
+		assetsTotal.WithLabelValues(resultError).Inc()
+		return errors.WithMessage(err, "failed to do HTTP request")
+	}
+	defer resp.Body.Close()
+
+	logrus.WithField("URL", as.AbsURL.String()).Debug("Asset downloaded.")
+
+	as.ContentType = resp.Header.Get("Content-Type")
+
+	as.DataReader = &countingReader{Reader: resp.Body, n: numBytes}
+	err = traceStoreWrite(ctx, fs, "asset", func() error {
+		return fs.SaveAsset(as)
+	})
+	if err != nil {
+		assetsTotal.WithLabelValues(resultError).Inc()
+		return errors.WithMessage(err, "failed to save asset")
+	}
+
+	assetsTotal.WithLabelValues(resultSuccess).Inc()
+
+	return nil
+}
+
+// archiveVersion archives the version into history, and prunes the versions
+// by the retention policy.
+func (f *Fetcher) archiveVersion(fs *store.FileStore, metadata *types.Metadata) error {
+	if err := fs.ArchiveVersion(metadata); err != nil {
+		return err
+	}
+
+	pruned, err := fs.PruneVersions(f.Retention)
+	if err != nil {
+		return errors.WithMessage(err, "failed to prune versions")
+	}
+
+	if len(pruned) > 0 {
+		logrus.WithField("versions", pruned).Debug("Versions pruned by retention policy.")
+	}
+
+	return nil
+}
+
+func (f *Fetcher) processMetadata(ctx context.Context, fs *store.FileStore,
+	parser *parser.Parser, pageUrlObj *url.URL, rawContent []byte) (*types.Metadata, error) {
 
 	// Extract and merge metadata.
 	oldMetadata, err := fs.LoadMetadata()
@@ -255,19 +966,86 @@ func (f *Fetcher) processMetadata(
 
 	// Merge old metadata.
 	metadata := parser.ExtractMetadata()
+	metadata.URL = pageUrlObj.String()
 	metadata.FetchedAt = time.Now()
 	if oldMetadata != nil {
 		metadata.LastFetchedAt = &oldMetadata.FetchedAt
 	}
 
-	// Save metadata file.
-	if err := fs.SaveMetadata(metadata); err != nil {
-		return nil, errors.WithMessage(err, "failed to save metadata file")
+	// Compute content hash to detect changes.
+	if f.NormalizeHash {
+		metadata.ContentHash = parser.ContentHash(f.VolatileSelectors...)
+	} else {
+		hash := sha256.Sum256(rawContent)
+		metadata.ContentHash = hex.EncodeToString(hash[:])
+	}
+
+	if oldMetadata != nil && len(oldMetadata.ContentHash) > 0 {
+		metadata.ContentChanged = metadata.ContentHash != oldMetadata.ContentHash
+	}
+
+	for _, selector := range f.TrackSelectors {
+		if metadata.SelectorHashes == nil {
+			metadata.SelectorHashes = make(map[string]string)
+		}
+
+		hash := parser.SelectorHash(selector, f.VolatileSelectors...)
+		metadata.SelectorHashes[selector] = hash
+
+		if oldMetadata == nil {
+			continue
+		}
+
+		if oldHash, ok := oldMetadata.SelectorHashes[selector]; ok && oldHash != hash {
+			metadata.ChangedSelectors = append(metadata.ChangedSelectors, selector)
+		}
+	}
+
+	// Versioned snapshot is always kept in history mode.
+	if f.Snapshot || f.History {
+		metadata.Version = fs.NewVersion(metadata.FetchedAt)
 	}
 
 	return metadata, nil
 }
 
+// saveDerivedContent saves the versioned snapshot and the readable content of
+// the HTML page if turned on.
+func (f *Fetcher) saveDerivedContent(ctx context.Context, fs *store.FileStore,
+	parser *parser.Parser, metadata *types.Metadata) error {
+
+	if len(metadata.Version) > 0 {
+		// Snapshots are of the document transformed by the middlewares and sanitized
+		// if turned on, the same as the HTML document.
+		html, err := parser.Document.Html()
+		if err != nil {
+			return errors.WithMessage(err, "invalid HTML document")
+		}
+
+		err = traceStoreWrite(ctx, fs, "snapshot", func() error {
+			return fs.SaveSnapshot(metadata.Version, []byte(html))
+		})
+		if err != nil {
+			return errors.WithMessage(err, "failed to save snapshot")
+		}
+	}
+
+	// Readable content is extracted once transformed by the middlewares.
+	if f.Readable {
+		content := parser.ExtractReadableContent()
+		err := traceStoreWrite(ctx, fs, "readable", func() error {
+			return fs.SaveReadableContent(content)
+		})
+		if err != nil {
+			return errors.WithMessage(err, "failed to save readable content")
+		}
+
+		metadata.NumWords = len(strings.Fields(content.Text))
+	}
+
+	return nil
+}
+
 // FetchedCallback is a type alias for the callback function executed upon
 // task completion.
 type FetchedCallback func(result *types.FetchResult)
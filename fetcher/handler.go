@@ -0,0 +1,286 @@
+package fetcher
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/xml"
+	"io"
+	"maps"
+	"mime"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+	"github.com/wanliqun/web-fetcher/store"
+	"github.com/wanliqun/web-fetcher/types"
+)
+
+// Media types of the default content handlers.
+const (
+	mediaTypeHTML    = "text/html"
+	mediaTypeXHTML   = "application/xhtml+xml"
+	mediaTypeJSON    = "application/json"
+	mediaTypeXML     = "application/xml"
+	mediaTypeTextXML = "text/xml"
+	mediaTypeText    = "text/plain"
+	mediaTypeBinary  = "application/octet-stream"
+	mediaTypeAny     = "*/*"
+)
+
+// Content is the downloaded content of a fetched web resource.
+type Content struct {
+	// MediaType: The media type detected, such as `application/pdf`.
+	MediaType string
+	// Data: The raw content.
+	Data []byte
+	// Store: The file store of the web resource.
+	Store *store.FileStore
+	// Result: The fetch result to fill the metadata and stored files into.
+	Result *types.FetchResult
+}
+
+// ContentHandler handles the downloaded content of a media type, which stores
+// the content and fills the metadata and stored files into the fetch result.
+type ContentHandler func(ctx context.Context, c *Content) error
+
+// Handler handles the content of the media type with the handler, rather than
+// the default one. The media type can be exact such as `application/pdf`, a
+// structured syntax suffix such as `+json`, a wildcard subtype such as `image/*`,
+// or `*/*` for any media type.
+func Handler(mediaType string, h ContentHandler) FetcherOption {
+	return func(f *Fetcher) {
+		f.handlers[strings.ToLower(mediaType)] = h
+	}
+}
+
+// RawJSON stores JSON content as it is rather than pretty-printed.
+func RawJSON() FetcherOption {
+	return func(f *Fetcher) {
+		f.handlers[mediaTypeJSON] = f.handleRaw
+		f.handlers["+json"] = f.handleRaw
+	}
+}
+
+// defaultHandlers returns the default content handlers of the fetcher.
+func (f *Fetcher) defaultHandlers() map[string]ContentHandler {
+	return map[string]ContentHandler{
+		mediaTypeHTML:    f.handleHTML,
+		mediaTypeXHTML:   f.handleHTML,
+		mediaTypeJSON:    f.handleJSON,
+		"+json":          f.handleJSON,
+		mediaTypeXML:     f.handleXML,
+		mediaTypeTextXML: f.handleXML,
+		"+xml":           f.handleXML,
+		mediaTypeAny:     f.handleRaw,
+	}
+}
+
+// contentHandler looks up the content handler of the media type, by the exact
+// media type, the structured syntax suffix, the wildcard subtype and then any.
+func (f *Fetcher) contentHandler(mediaType string) ContentHandler {
+	if h, ok := f.handlers[mediaType]; ok {
+		return h
+	}
+
+	if i := strings.LastIndex(mediaType, "+"); i > 0 {
+		if h, ok := f.handlers[mediaType[i:]]; ok {
+			return h
+		}
+	}
+
+	if typ, _, ok := strings.Cut(mediaType, "/"); ok {
+		if h, ok := f.handlers[typ+"/*"]; ok {
+			return h
+		}
+	}
+
+	return f.handlers[mediaTypeAny]
+}
+
+// detectMediaType detects the media type of the content by the `Content-Type`
+// header, which is sniffed from the content if missing, generic or contradicting
+// the content, such as binary content served as text.
+func detectMediaType(contentType string, data []byte) string {
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		mediaType = ""
+	}
+	mediaType = strings.ToLower(mediaType)
+
+	sniffed := sniffMediaType(data)
+	switch {
+	// Generic types are refined by the sniffed one.
+	case len(mediaType) == 0, mediaType == mediaTypeBinary, mediaType == mediaTypeText:
+		return sniffed
+	case isTextual(mediaType) && !isTextual(sniffed) && sniffed != mediaTypeBinary:
+		return sniffed
+	// HTML pages such as error pages may be served with other textual types.
+	case isTextual(mediaType) && sniffed == mediaTypeHTML:
+		return sniffed
+	}
+
+	return mediaType
+}
+
+// sniffMediaType sniffs the media type of the content, with JSON recognized.
+func sniffMediaType(data []byte) string {
+	mediaType, _, _ := mime.ParseMediaType(http.DetectContentType(data))
+
+	trimmed := bytes.TrimSpace(data)
+	if mediaType == mediaTypeText && len(trimmed) > 0 &&
+		(trimmed[0] == '{' || trimmed[0] == '[') && json.Valid(trimmed) {
+		return mediaTypeJSON
+	}
+
+	return mediaType
+}
+
+// isTextual checks if the media type is textual.
+func isTextual(mediaType string) bool {
+	return strings.HasPrefix(mediaType, "text/") ||
+		strings.HasSuffix(mediaType, "+json") || strings.HasSuffix(mediaType, "+xml") ||
+		mediaType == mediaTypeJSON || mediaType == mediaTypeXML || mediaType == "application/javascript"
+}
+
+// handleRaw is the default content handler, which saves the content as it is
+// with the file extension of the media type.
+func (f *Fetcher) handleRaw(ctx context.Context, c *Content) error {
+	return f.saveContent(ctx, c, c.Data)
+}
+
+// handleJSON saves the JSON content pretty-printed, or as it is if invalid since
+// it is the actual content served anyway.
+func (f *Fetcher) handleJSON(ctx context.Context, c *Content) error {
+	var buf bytes.Buffer
+	if err := json.Indent(&buf, c.Data, "", "  "); err != nil {
+		logrus.WithField("URL", c.Result.URL).WithError(err).Warn("Invalid JSON content saved as it is.")
+		return f.saveContent(ctx, c, c.Data)
+	}
+
+	return f.saveContent(ctx, c, append(buf.Bytes(), '\n'))
+}
+
+// handleXML validates the well-formedness of the XML content and saves it as it is.
+func (f *Fetcher) handleXML(ctx context.Context, c *Content) error {
+	decoder := xml.NewDecoder(bytes.NewReader(c.Data))
+	decoder.Strict = true
+	// Documents of any encoding declared are checked for well-formedness only.
+	decoder.CharsetReader = func(charset string, input io.Reader) (io.Reader, error) {
+		return input, nil
+	}
+
+	for {
+		if _, err := decoder.Token(); err == io.EOF {
+			break
+		} else if err != nil {
+			return errors.WithMessage(err, "invalid XML content")
+		}
+	}
+
+	return f.saveContent(ctx, c, c.Data)
+}
+
+// saveContent saves the content of a non-HTML resource along with the metadata,
+// which is versioned and archived into history as HTML pages if turned on.
+func (f *Fetcher) saveContent(ctx context.Context, c *Content, data []byte) error {
+	fs, result := c.Store, c.Result
+
+	ext := store.ContentExtension(c.MediaType)
+
+	err := traceStoreWrite(ctx, fs, "content", func() error {
+		return fs.SaveContent(ext, data)
+	})
+	if err != nil {
+		return errors.WithMessage(err, "failed to save content")
+	}
+
+	metadata, err := newContentMetadata(fs, result.Response.Request.URL, c)
+	if err != nil {
+		return errors.WithMessage(err, "failed to process metadata")
+	}
+
+	files := &types.StoredFiles{
+		Content:  fs.ContentFilePath(ext),
+		Metadata: fs.MetadataFilePath(),
+	}
+
+	// Versioned snapshot is always kept in history mode.
+	if f.Snapshot || f.History {
+		metadata.Version = fs.NewVersion(metadata.FetchedAt)
+
+		err := traceStoreWrite(ctx, fs, "snapshot", func() error {
+			return fs.SaveContentSnapshot(metadata.Version, ext, data)
+		})
+		if err != nil {
+			return errors.WithMessage(err, "failed to save snapshot")
+		}
+		files.Snapshot = fs.ContentSnapshotFilePath(metadata.Version, ext)
+	}
+
+	if err := f.saveMetadata(ctx, fs, metadata, result); err != nil {
+		return err
+	}
+
+	if f.History {
+		err := traceStoreWrite(ctx, fs, "version", func() error {
+			return f.archiveVersion(fs, metadata)
+		})
+		if err != nil {
+			return errors.WithMessage(err, "failed to archive version")
+		}
+	}
+
+	result.Metadata, result.Files = metadata, files
+	return nil
+}
+
+// newContentMetadata creates the metadata of a non-HTML resource, merged with
+// the old metadata if any.
+func newContentMetadata(fs *store.FileStore, urlObj *url.URL, c *Content) (*types.Metadata, error) {
+	oldMetadata, err := fs.LoadMetadata()
+	if err != nil {
+		return nil, errors.WithMessage(err, "failed to load metadata")
+	}
+
+	hash := sha256.Sum256(c.Data)
+	metadata := &types.Metadata{
+		URL:         urlObj.String(),
+		ContentType: c.MediaType,
+		ContentHash: hex.EncodeToString(hash[:]),
+		FetchedAt:   time.Now(),
+	}
+
+	if oldMetadata != nil {
+		metadata.LastFetchedAt = &oldMetadata.FetchedAt
+		if len(oldMetadata.ContentHash) > 0 {
+			metadata.ContentChanged = metadata.ContentHash != oldMetadata.ContentHash
+		}
+	}
+
+	return metadata, nil
+}
+
+// saveMetadata saves the metadata file along with the timings so far, which
+// excludes the metadata file writing itself, and the annotations so far.
+func (f *Fetcher) saveMetadata(ctx context.Context,
+	fs *store.FileStore, metadata *types.Metadata, result *types.FetchResult) error {
+
+	persistedTimings := *result.Timings
+	metadata.Timings = &persistedTimings
+	metadata.Annotations = maps.Clone(result.Annotations)
+
+	err := traceStoreWrite(ctx, fs, "metadata", func() error {
+		return fs.SaveMetadata(metadata)
+	})
+	if err != nil {
+		return errors.WithMessage(err, "failed to save metadata file")
+	}
+
+	return nil
+}
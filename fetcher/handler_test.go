@@ -0,0 +1,216 @@
+package fetcher_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/wanliqun/web-fetcher/extract"
+	"github.com/wanliqun/web-fetcher/fetcher"
+	"github.com/wanliqun/web-fetcher/parser"
+	"github.com/wanliqun/web-fetcher/store"
+	"github.com/wanliqun/web-fetcher/types"
+)
+
+func TestContentHandlers(t *testing.T) {
+	png := []byte("\x89PNG\x0D\x0A\x1A\x0A\x00\x00\x00\x0DIHDR")
+
+	site := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/doc.pdf":
+			// Wrong content type is sniffed.
+			w.Header().Set("Content-Type", "text/html")
+			w.Write([]byte("%PDF-1.7\n%\xe2\xe3\xcf\xd3\n"))
+		case "/data":
+			w.Header().Set("Content-Type", "text/plain")
+			w.Write([]byte(`{"a":[1,2]}`))
+		case "/logo":
+			w.Header()["Content-Type"] = nil
+			w.Write(png)
+		case "/feed.xml":
+			w.Header().Set("Content-Type", "application/rss+xml")
+			w.Write([]byte(`<rss><channel><title>t</title></channel>`))
+		}
+	}))
+	defer site.Close()
+
+	results := make(map[string]*types.FetchResult)
+	f := fetcher.NewFetcher(fetcher.RootDir(t.TempDir()))
+	f.OnFetched(func(r *types.FetchResult) { results[r.URL] = r })
+
+	for _, path := range []string{"/doc.pdf", "/data", "/logo", "/feed.xml"} {
+		f.Fetch(site.URL + path)
+	}
+
+	result := results[site.URL+"/doc.pdf"]
+	if assert.NoError(t, result.Err) {
+		assert.Equal(t, "application/pdf", result.Metadata.ContentType)
+		assert.Equal(t, ".pdf", filepath.Ext(result.Files.Content))
+		assert.Empty(t, result.Files.HTML)
+	}
+
+	result = results[site.URL+"/data"]
+	if assert.NoError(t, result.Err) {
+		assert.Equal(t, "application/json", result.Metadata.ContentType)
+
+		content, err := os.ReadFile(result.Files.Content)
+		assert.NoError(t, err)
+		assert.Equal(t, "{\n  \"a\": [\n    1,\n    2\n  ]\n}\n", string(content))
+	}
+
+	result = results[site.URL+"/logo"]
+	if assert.NoError(t, result.Err) {
+		assert.Equal(t, ".png", filepath.Ext(result.Files.Content))
+
+		content, err := os.ReadFile(result.Files.Content)
+		assert.NoError(t, err)
+		assert.Equal(t, png, content)
+	}
+
+	// Malformed XML is rejected.
+	assert.ErrorContains(t, results[site.URL+"/feed.xml"].Err, "invalid XML content")
+}
+
+func TestCustomContentHandler(t *testing.T) {
+	site := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "image/png")
+		w.Write([]byte("\x89PNG\x0D\x0A\x1A\x0A"))
+	}))
+	defer site.Close()
+
+	var handled *fetcher.Content
+	f := fetcher.NewFetcher(
+		fetcher.RootDir(t.TempDir()),
+		fetcher.Handler("image/*", func(ctx context.Context, c *fetcher.Content) error {
+			handled = c
+			return nil
+		}),
+	)
+	assert.NoError(t, f.Fetch(site.URL+"/logo.png"))
+
+	if assert.NotNil(t, handled) {
+		assert.Equal(t, "image/png", handled.MediaType)
+	}
+}
+
+func TestExtractFields(t *testing.T) {
+	site := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html")
+		w.Write([]byte(`<html><body><h1>Title</h1><img src="/a.png"><img src="/b.png"></body></html>`))
+	}))
+	defer site.Close()
+
+	var result *types.FetchResult
+	f := fetcher.NewFetcher(
+		fetcher.RootDir(t.TempDir()),
+		fetcher.ExtractRules(extract.Rules{
+			"title":  {CSS: "h1"},
+			"images": {XPath: "//img/@src", Multiple: true},
+		}),
+	)
+	f.OnFetched(func(r *types.FetchResult) { result = r })
+	f.Fetch(site.URL)
+
+	if assert.NoError(t, result.Err) {
+		assert.Equal(t, map[string]any{
+			"title":  "Title",
+			"images": []string{"/a.png", "/b.png"},
+		}, result.Metadata.Fields)
+	}
+}
+
+func TestIndexNonHTMLContent(t *testing.T) {
+	site := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/pdf")
+		w.Write([]byte("%PDF-1.7\n"))
+	}))
+	defer site.Close()
+
+	rootDir := t.TempDir()
+	f := fetcher.NewFetcher(fetcher.RootDir(rootDir))
+	assert.NoError(t, f.Fetch(site.URL+"/doc.pdf"))
+
+	index, err := store.LoadIndex(rootDir)
+	assert.NoError(t, err)
+
+	content, err := os.ReadFile(filepath.Join(rootDir, index[site.URL+"/doc.pdf"]))
+	assert.NoError(t, err)
+	assert.Equal(t, "%PDF-1.7\n", string(content))
+
+	// The stored document is looked up by the content type within the metadata.
+	fs, err := f.OpenFileStore(site.URL + "/doc.pdf")
+	assert.NoError(t, err)
+
+	metadata, err := fs.LoadMetadata()
+	assert.NoError(t, err)
+	assert.Equal(t, filepath.Join(rootDir, index[site.URL+"/doc.pdf"]), fs.DocFilePath(metadata.ContentType))
+}
+
+func TestNonHTMLContentHistory(t *testing.T) {
+	bodies := []string{`{"price": 1}`, `{"price": 2`}
+	var numRequests int
+	site := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(bodies[numRequests]))
+		numRequests++
+	}))
+	defer site.Close()
+
+	rootDir := t.TempDir()
+	var results []*types.FetchResult
+	for range bodies {
+		f := fetcher.NewFetcher(fetcher.RootDir(rootDir), fetcher.History(store.RetentionPolicy{}))
+		f.OnFetched(func(r *types.FetchResult) { results = append(results, r) })
+		assert.NoError(t, f.Fetch(site.URL+"/api"))
+	}
+
+	// Invalid JSON content is stored as it is rather than failed.
+	for i, result := range results {
+		if assert.NoError(t, result.Err) {
+			assert.NotEmpty(t, result.Metadata.Version)
+
+			content, err := os.ReadFile(result.Files.Snapshot)
+			assert.NoError(t, err)
+			assert.Contains(t, string(content), `"price": `+string(rune('1'+i)))
+		}
+	}
+
+	fs, err := fetcher.OpenFileStore(rootDir, site.URL+"/api")
+	assert.NoError(t, err)
+
+	versions, err := fs.ListVersions()
+	assert.NoError(t, err)
+	assert.Len(t, versions, 2)
+
+	// The content file is restored along with the version.
+	assert.NoError(t, fs.RestoreVersion(versions[0].Version))
+	content, err := os.ReadFile(results[0].Files.Content)
+	assert.NoError(t, err)
+	assert.Equal(t, "{\n  \"price\": 1\n}\n", string(content))
+}
+
+func TestSanitizeSnapshot(t *testing.T) {
+	site := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html")
+		w.Write([]byte(`<html><body><script>track()</script><p>text</p></body></html>`))
+	}))
+	defer site.Close()
+
+	profile, _ := parser.SanitizeProfileByName(parser.SanitizeBasic)
+
+	var result *types.FetchResult
+	f := fetcher.NewFetcher(fetcher.RootDir(t.TempDir()), fetcher.Snapshot(), fetcher.Sanitize(profile))
+	f.OnFetched(func(r *types.FetchResult) { result = r })
+	assert.NoError(t, f.Fetch(site.URL))
+
+	for _, filePath := range []string{result.Files.HTML, result.Files.Snapshot} {
+		content, err := os.ReadFile(filePath)
+		assert.NoError(t, err)
+		assert.Contains(t, string(content), "<p>text</p>")
+		assert.NotContains(t, string(content), "track()")
+	}
+}
@@ -0,0 +1,132 @@
+package fetcher
+
+import (
+	"io"
+	"net/http"
+	"strconv"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+// Prometheus metrics of the fetcher, registered to the default registry.
+var (
+	httpRequestsTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "webfetcher",
+		Name:      "http_requests_total",
+		Help:      "Total number of HTTP requests by host and status code.",
+	}, []string{"host", "code"})
+
+	httpRequestDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: "webfetcher",
+		Name:      "http_request_duration_seconds",
+		Help:      "Latency of HTTP requests until the response headers by status code.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"code"})
+
+	httpInFlightRequests = promauto.NewGauge(prometheus.GaugeOpts{
+		Namespace: "webfetcher",
+		Name:      "http_in_flight_requests",
+		Help:      "Number of HTTP requests in flight.",
+	})
+
+	throttleWaitDuration = promauto.NewHistogram(prometheus.HistogramOpts{
+		Namespace: "webfetcher",
+		Name:      "throttle_wait_seconds",
+		Help:      "Time waited for the concurrency semaphore of the throttle client.",
+		Buckets:   prometheus.ExponentialBuckets(0.001, 4, 8),
+	})
+
+	downloadedBytesTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "webfetcher",
+		Name:      "downloaded_bytes_total",
+		Help:      "Total number of response body bytes downloaded by host.",
+	}, []string{"host"})
+
+	pagesTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "webfetcher",
+		Name:      "pages_total",
+		Help:      "Total number of web pages fetched by result.",
+	}, []string{"result"})
+
+	pageErrorsTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "webfetcher",
+		Name:      "page_errors_total",
+		Help:      "Total number of web page fetch errors by type.",
+	}, []string{"type"})
+
+	pageDuration = promauto.NewHistogram(prometheus.HistogramOpts{
+		Namespace: "webfetcher",
+		Name:      "page_duration_seconds",
+		Help:      "Latency of fetching web pages, including processing and storing.",
+		Buckets:   prometheus.ExponentialBuckets(0.05, 2, 10),
+	})
+
+	assetsTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "webfetcher",
+		Name:      "assets_total",
+		Help:      "Total number of assets downloaded by result.",
+	}, []string{"result"})
+)
+
+// Result label values.
+const (
+	resultSuccess = "success"
+	resultError   = "error"
+	// Web pages skipped as duplicates of the seen ones.
+	resultDuplicate = "duplicate"
+	// Web pages skipped as vetoed by the middlewares.
+	resultSkipped = "skipped"
+)
+
+// Fetch error types for the `type` label.
+const (
+	errTypeInvalidURL = "invalid_url"
+	errTypeRequest    = "request"
+	errTypeHTTPStatus = "http_status"
+	errTypeStore      = "store"
+	errTypeProcess    = "process"
+	errTypeMiddleware = "middleware"
+)
+
+// observeResponse observes the HTTP response or error of the request, and
+// counts the body bytes as they are read.
+func observeResponse(req *http.Request, resp *http.Response, err error, seconds float64) {
+	host := req.URL.Host
+
+	code := "error"
+	if err == nil {
+		code = strconv.Itoa(resp.StatusCode)
+		resp.Body = &countingReadCloser{
+			ReadCloser: resp.Body,
+			counter:    downloadedBytesTotal.WithLabelValues(host),
+		}
+	}
+
+	httpRequestsTotal.WithLabelValues(host, code).Inc()
+	httpRequestDuration.WithLabelValues(code).Observe(seconds)
+}
+
+// countingReadCloser counts the bytes read from the underlying reader.
+type countingReadCloser struct {
+	io.ReadCloser
+	counter prometheus.Counter
+}
+
+func (r *countingReadCloser) Read(p []byte) (int, error) {
+	n, err := r.ReadCloser.Read(p)
+	r.counter.Add(float64(n))
+	return n, err
+}
+
+// countingReader counts the bytes read from the underlying reader.
+type countingReader struct {
+	io.Reader
+	n *int64
+}
+
+func (r *countingReader) Read(p []byte) (int, error) {
+	n, err := r.Reader.Read(p)
+	*r.n += int64(n)
+	return n, err
+}
@@ -0,0 +1,68 @@
+package fetcher_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/stretchr/testify/assert"
+	"github.com/wanliqun/web-fetcher/fetcher"
+)
+
+func TestMetrics(t *testing.T) {
+	site := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/missing":
+			http.NotFound(w, r)
+		case "/a.png":
+			w.Write([]byte("png"))
+		default:
+			w.Header().Set("Content-Type", "text/html")
+			w.Write([]byte(`<html><body><img src="/a.png"></body></html>`))
+		}
+	}))
+	defer site.Close()
+
+	// Metrics are registered globally, so only the increments are checked.
+	before := gatherCounters(t)
+
+	f := fetcher.NewFetcher(fetcher.Mirror(), fetcher.RootDir(t.TempDir()))
+	f.Fetch(site.URL + "/page")
+	f.Fetch(site.URL + "/missing")
+
+	values := gatherCounters(t)
+	for name, val := range before {
+		values[name] -= val
+	}
+
+	host := site.Listener.Addr().String()
+	assert.Equal(t, float64(2), values["webfetcher_http_requests_total,code=200,host="+host])
+	assert.Equal(t, float64(1), values["webfetcher_http_requests_total,code=404,host="+host])
+	assert.Equal(t, float64(1), values["webfetcher_pages_total,result=success"])
+	assert.Equal(t, float64(1), values["webfetcher_page_errors_total,type=http_status"])
+	assert.Equal(t, float64(1), values["webfetcher_assets_total,result=success"])
+	assert.Greater(t, values["webfetcher_downloaded_bytes_total,host="+host], float64(0))
+}
+
+// gatherCounters gathers the values of all counters keyed by the name and labels.
+func gatherCounters(t *testing.T) map[string]float64 {
+	families, err := prometheus.DefaultGatherer.Gather()
+	assert.NoError(t, err)
+
+	values := make(map[string]float64)
+	for _, family := range families {
+		for _, m := range family.Metric {
+			name := family.GetName()
+			for _, label := range m.Label {
+				name += "," + label.GetName() + "=" + label.GetValue()
+			}
+
+			if c := m.GetCounter(); c != nil {
+				values[name] = c.GetValue()
+			}
+		}
+	}
+
+	return values
+}
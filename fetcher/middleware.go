@@ -0,0 +1,79 @@
+package fetcher
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+	"github.com/wanliqun/web-fetcher/parser"
+	"github.com/wanliqun/web-fetcher/types"
+)
+
+// ErrSkip is returned by middleware hooks to veto the web page, which is skipped
+// rather than failed.
+var ErrSkip = errors.New("skipped by middleware")
+
+// Middleware hooks into the stages of the fetch pipeline, such as to mutate the
+// HTTP request, transform the DOM or annotate the fetch result. Each hook can
+// veto the web page by returning ErrSkip, or fail the fetch by returning any
+// other error. Hooks left nil are not called.
+//
+// AfterParse and BeforeSave are called for HTML pages only, non-HTML content can
+// be vetoed by AfterResponse with the `Content-Type` header.
+type Middleware struct {
+	// Name: The name of the middleware, which is reported if the web page is
+	// skipped by it.
+	Name string
+	// BeforeRequest is called before the HTTP request is sent, which can mutate
+	// the request such as the headers.
+	BeforeRequest func(ctx context.Context, req *http.Request, result *types.FetchResult) error
+	// AfterResponse is called once the response of a successful status code is
+	// received, before the response body is downloaded.
+	AfterResponse func(ctx context.Context, resp *http.Response, result *types.FetchResult) error
+	// AfterParse is called once the HTML page is parsed, before the metadata is
+	// processed, which can read or modify the DOM.
+	AfterParse func(ctx context.Context, doc *parser.Parser, result *types.FetchResult) error
+	// BeforeSave is called before anything of the HTML page is stored or any
+	// asset is downloaded, with the document sanitized if turned on, which can
+	// read or modify the DOM.
+	BeforeSave func(ctx context.Context, doc *parser.Parser, result *types.FetchResult) error
+	// AfterSave is called once the web page is stored along with the metadata.
+	// Vetoing it marks the fetch result as skipped, with the stored files kept.
+	AfterSave func(ctx context.Context, result *types.FetchResult) error
+}
+
+// Use hooks the middlewares into the fetch pipeline, whose hooks are called in
+// the order of the middlewares.
+func Use(middlewares ...*Middleware) FetcherOption {
+	return func(f *Fetcher) {
+		f.middlewares = append(f.middlewares, middlewares...)
+	}
+}
+
+// runHooks calls the hook of the stage on each middleware in order, until the
+// web page is vetoed or any hook fails.
+func (f *Fetcher) runHooks(stage string,
+	result *types.FetchResult, call func(mw *Middleware) error) error {
+
+	for _, mw := range f.middlewares {
+		err := call(mw)
+		if err == nil {
+			continue
+		}
+
+		if errors.Is(err, ErrSkip) {
+			logrus.WithFields(logrus.Fields{
+				"URL":        result.URL,
+				"middleware": mw.Name,
+				"stage":      stage,
+			}).Debug("Web page skipped by middleware.")
+			result.SkippedBy = mw.Name
+			return ErrSkip
+		}
+
+		return errors.WithMessagef(err, "middleware %v failed %v", mw.Name, stage)
+	}
+
+	return nil
+}
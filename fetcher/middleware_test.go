@@ -0,0 +1,173 @@
+package fetcher_test
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/wanliqun/web-fetcher/fetcher"
+	"github.com/wanliqun/web-fetcher/parser"
+	"github.com/wanliqun/web-fetcher/store"
+	"github.com/wanliqun/web-fetcher/types"
+)
+
+func TestMiddleware(t *testing.T) {
+	site := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/private" {
+			w.WriteHeader(http.StatusOK)
+			return
+		}
+
+		w.Header().Set("Content-Type", "text/html")
+		w.Write([]byte(`<html><body><p>` + r.Header.Get("X-Token") + `</p><div class="ad">ad</div></body></html>`))
+	}))
+	defer site.Close()
+
+	var stages []string
+	stripAds := &fetcher.Middleware{
+		Name: "strip-ads",
+		BeforeRequest: func(ctx context.Context, req *http.Request, result *types.FetchResult) error {
+			stages = append(stages, "before request")
+			req.Header.Set("X-Token", "token")
+			return nil
+		},
+		AfterResponse: func(ctx context.Context, resp *http.Response, result *types.FetchResult) error {
+			stages = append(stages, "after response")
+			if resp.Request.URL.Path == "/private" {
+				return fetcher.ErrSkip
+			}
+			return nil
+		},
+		AfterParse: func(ctx context.Context, doc *parser.Parser, result *types.FetchResult) error {
+			stages = append(stages, "after parse")
+			doc.Document.Find(".ad").Remove()
+			return nil
+		},
+		BeforeSave: func(ctx context.Context, doc *parser.Parser, result *types.FetchResult) error {
+			stages = append(stages, "before save")
+			result.Annotate("ads", "stripped")
+			return nil
+		},
+		AfterSave: func(ctx context.Context, result *types.FetchResult) error {
+			stages = append(stages, "after save")
+			return nil
+		},
+	}
+
+	results := make(map[string]*types.FetchResult)
+	f := fetcher.NewFetcher(fetcher.RootDir(t.TempDir()), fetcher.Use(stripAds))
+	f.OnFetched(func(r *types.FetchResult) { results[r.URL] = r })
+
+	assert.NoError(t, f.Fetch(site.URL+"/page"))
+	assert.Equal(t, []string{
+		"before request", "after response", "after parse", "before save", "after save",
+	}, stages)
+
+	result := results[site.URL+"/page"]
+	if assert.NoError(t, result.Err) {
+		assert.Equal(t, map[string]string{"ads": "stripped"}, result.Metadata.Annotations)
+
+		content, err := os.ReadFile(result.Files.HTML)
+		assert.NoError(t, err)
+		assert.Contains(t, string(content), "<p>token</p>")
+		assert.NotContains(t, string(content), "ad</div>")
+	}
+
+	// Vetoed web pages are skipped rather than failed.
+	assert.NoError(t, f.Fetch(site.URL+"/private"))
+	result = results[site.URL+"/private"]
+	assert.NoError(t, result.Err)
+	assert.Equal(t, "strip-ads", result.SkippedBy)
+	assert.Nil(t, result.Files)
+}
+
+func TestMiddlewareFailure(t *testing.T) {
+	site := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html")
+		w.Write([]byte(`<html><body>email@example.com</body></html>`))
+	}))
+	defer site.Close()
+
+	redact := &fetcher.Middleware{
+		Name: "redact",
+		BeforeSave: func(ctx context.Context, doc *parser.Parser, result *types.FetchResult) error {
+			return errors.New("PII found")
+		},
+	}
+
+	f := fetcher.NewFetcher(fetcher.RootDir(t.TempDir()), fetcher.Use(redact))
+	assert.ErrorContains(t, f.Fetch(site.URL), "middleware redact failed before save: PII found")
+}
+
+func TestMiddlewareVetoBeforeSave(t *testing.T) {
+	var paths []string
+	site := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		paths = append(paths, r.URL.Path)
+		w.Header().Set("Content-Type", "text/html")
+		w.Write([]byte(`<html><body><p>secret</p><img src="/logo.png"></body></html>`))
+	}))
+	defer site.Close()
+
+	rootDir := t.TempDir()
+	f := fetcher.NewFetcher(
+		fetcher.RootDir(rootDir), fetcher.Mirror(), fetcher.Snapshot(), fetcher.Readable(),
+		fetcher.Use(&fetcher.Middleware{
+			Name: "veto",
+			BeforeSave: func(ctx context.Context, doc *parser.Parser, result *types.FetchResult) error {
+				return fetcher.ErrSkip
+			},
+		}),
+	)
+
+	var result *types.FetchResult
+	f.OnFetched(func(r *types.FetchResult) { result = r })
+	assert.NoError(t, f.Fetch(site.URL+"/page"))
+	assert.Equal(t, "veto", result.SkippedBy)
+
+	// Neither the page is stored nor the assets are downloaded.
+	entries, err := os.ReadDir(rootDir)
+	assert.NoError(t, err)
+	assert.Empty(t, entries)
+	assert.Equal(t, []string{"/page"}, paths)
+}
+
+func TestMiddlewareRedactSnapshot(t *testing.T) {
+	site := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html")
+		w.Write([]byte(`<html><body><p>hello</p><p class="pii">email@example.com</p></body></html>`))
+	}))
+	defer site.Close()
+
+	rootDir := t.TempDir()
+	f := fetcher.NewFetcher(
+		fetcher.RootDir(rootDir), fetcher.Snapshot(), fetcher.History(store.RetentionPolicy{}),
+		fetcher.Use(&fetcher.Middleware{
+			Name: "redact",
+			AfterParse: func(ctx context.Context, doc *parser.Parser, result *types.FetchResult) error {
+				doc.Document.Find(".pii").Remove()
+				return nil
+			},
+		}),
+	)
+
+	var result *types.FetchResult
+	f.OnFetched(func(r *types.FetchResult) { result = r })
+	assert.NoError(t, f.Fetch(site.URL))
+	assert.NoError(t, result.Err)
+
+	fs, err := f.OpenFileStore(site.URL)
+	assert.NoError(t, err)
+
+	// Neither the snapshot nor the history keeps the redacted content.
+	vfs := fs.VersionStore(result.Metadata.Version)
+	for _, filePath := range []string{result.Files.HTML, result.Files.Snapshot, vfs.HtmlDocPath()} {
+		content, err := os.ReadFile(filePath)
+		assert.NoError(t, err)
+		assert.Contains(t, string(content), "hello")
+		assert.NotContains(t, string(content), "email@example.com")
+	}
+}
@@ -0,0 +1,68 @@
+package fetcher
+
+import (
+	"context"
+	"crypto/tls"
+	"net/http/httptrace"
+	"time"
+
+	"github.com/wanliqun/web-fetcher/types"
+)
+
+type timingsContextKey struct{}
+
+// withTimings returns the context to gather the timing breakdown into, or not
+// to gather any if nil.
+func withTimings(ctx context.Context, timings *types.Timings) context.Context {
+	return context.WithValue(ctx, timingsContextKey{}, timings)
+}
+
+// timingsFromContext returns the timing breakdown to gather into if any.
+func timingsFromContext(ctx context.Context) *types.Timings {
+	timings, _ := ctx.Value(timingsContextKey{}).(*types.Timings)
+	return timings
+}
+
+// withTimingsTrace adds the `httptrace` hooks to gather the DNS, connect, TLS
+// handshake and time to first byte timings of the HTTP request, which starts
+// at the moment of calling.
+func withTimingsTrace(ctx context.Context) context.Context {
+	timings := timingsFromContext(ctx)
+	if timings == nil {
+		return ctx
+	}
+
+	start := time.Now()
+	var dnsStart, connectStart, tlsStart time.Time
+	return httptrace.WithClientTrace(ctx, &httptrace.ClientTrace{
+		DNSStart: func(httptrace.DNSStartInfo) {
+			dnsStart = time.Now()
+		},
+		DNSDone: func(httptrace.DNSDoneInfo) {
+			timings.DNS = time.Since(dnsStart)
+		},
+		ConnectStart: func(network, addr string) {
+			connectStart = time.Now()
+		},
+		ConnectDone: func(network, addr string, err error) {
+			timings.Connect = time.Since(connectStart)
+		},
+		TLSHandshakeStart: func() {
+			tlsStart = time.Now()
+		},
+		TLSHandshakeDone: func(tls.ConnectionState, error) {
+			timings.TLS = time.Since(tlsStart)
+		},
+		GotFirstResponseByte: func() {
+			timings.TTFB = time.Since(start)
+		},
+	})
+}
+
+// trackTime adds the elapsed time of the function to the duration.
+func trackTime(d *time.Duration, fn func() error) error {
+	start := time.Now()
+	defer func() { *d += time.Since(start) }()
+
+	return fn()
+}
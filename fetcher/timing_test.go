@@ -0,0 +1,49 @@
+package fetcher_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/wanliqun/web-fetcher/fetcher"
+	"github.com/wanliqun/web-fetcher/types"
+)
+
+func TestTimings(t *testing.T) {
+	site := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/a.png" {
+			w.Write([]byte("png"))
+			return
+		}
+
+		w.Header().Set("Content-Type", "text/html")
+		w.Write([]byte(`<html><body><img src="/a.png"></body></html>`))
+	}))
+	defer site.Close()
+
+	var result *types.FetchResult
+	f := fetcher.NewFetcher(fetcher.Mirror(), fetcher.RootDir(t.TempDir()))
+	f.OnFetched(func(r *types.FetchResult) { result = r })
+	assert.NoError(t, f.Fetch(site.URL+"/page"))
+
+	timings := result.Timings
+	assert.Positive(t, timings.Connect)
+	assert.Positive(t, timings.TTFB)
+	assert.Positive(t, timings.Parse)
+	assert.Positive(t, timings.Assets)
+	assert.Positive(t, timings.Store)
+
+	// The timings are persisted within the metadata.
+	metadata, err := f.LoadMetadata(site.URL + "/page")
+	assert.NoError(t, err)
+	assert.NotNil(t, metadata.Timings)
+	assert.Equal(t, timings.TTFB, metadata.Timings.TTFB)
+
+	// The timings are encoded in milliseconds.
+	data, err := json.Marshal(&types.Timings{TTFB: 1500 * time.Microsecond})
+	assert.NoError(t, err)
+	assert.JSONEq(t, `{"TTFBMs": 1.5}`, string(data))
+}
@@ -0,0 +1,94 @@
+package fetcher
+
+import (
+	"context"
+	"crypto/tls"
+	"net/http/httptrace"
+
+	"github.com/wanliqun/web-fetcher/store"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Name of the tracer to instrument the fetch pipeline.
+const tracerName = "github.com/wanliqun/web-fetcher/fetcher"
+
+// Span attribute keys.
+const (
+	attrURL        = attribute.Key("url")
+	attrDocName    = attribute.Key("doc_name")
+	attrStatusCode = attribute.Key("http.status_code")
+)
+
+// startSpan starts a span with the tracer of the span within the context, which
+// is a no-op if the context is not traced.
+func startSpan(ctx context.Context, name string, attrs ...attribute.KeyValue) (context.Context, trace.Span) {
+	tracer := trace.SpanFromContext(ctx).TracerProvider().Tracer(tracerName)
+	return tracer.Start(ctx, name, trace.WithAttributes(attrs...))
+}
+
+// endSpan records the error if any and ends the span.
+func endSpan(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+
+	span.End()
+}
+
+// withClientTrace adds the `httptrace` timing events of the HTTP request, such
+// as DNS lookup, connecting, TLS handshake and the first response byte, to the
+// span within the context.
+func withClientTrace(ctx context.Context) context.Context {
+	span := trace.SpanFromContext(ctx)
+	if !span.IsRecording() {
+		return ctx
+	}
+
+	return httptrace.WithClientTrace(ctx, &httptrace.ClientTrace{
+		DNSStart: func(info httptrace.DNSStartInfo) {
+			span.AddEvent("dns.start", trace.WithAttributes(attribute.String("host", info.Host)))
+		},
+		DNSDone: func(httptrace.DNSDoneInfo) {
+			span.AddEvent("dns.done")
+		},
+		ConnectStart: func(network, addr string) {
+			span.AddEvent("connect.start", trace.WithAttributes(attribute.String("addr", addr)))
+		},
+		ConnectDone: func(network, addr string, err error) {
+			span.AddEvent("connect.done", trace.WithAttributes(attribute.String("addr", addr)))
+		},
+		GotConn: func(info httptrace.GotConnInfo) {
+			span.AddEvent("conn.got", trace.WithAttributes(attribute.Bool("reused", info.Reused)))
+		},
+		TLSHandshakeStart: func() {
+			span.AddEvent("tls.start")
+		},
+		TLSHandshakeDone: func(tls.ConnectionState, error) {
+			span.AddEvent("tls.done")
+		},
+		WroteRequest: func(httptrace.WroteRequestInfo) {
+			span.AddEvent("request.wrote")
+		},
+		GotFirstResponseByte: func() {
+			span.AddEvent("response.first_byte")
+		},
+	})
+}
+
+// traceStoreWrite traces the write to the file store.
+func traceStoreWrite(ctx context.Context, fs *store.FileStore, name string, write func() error) error {
+	_, span := startSpan(ctx, "store."+name, attrDocName.String(fs.DocName()))
+
+	var err error
+	if timings := timingsFromContext(ctx); timings != nil {
+		err = trackTime(&timings.Store, write)
+	} else {
+		err = write()
+	}
+	endSpan(span, err)
+
+	return err
+}
@@ -0,0 +1,62 @@
+package fetcher_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/wanliqun/web-fetcher/fetcher"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+func TestTracing(t *testing.T) {
+	site := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/a.png" {
+			w.Write([]byte("png"))
+			return
+		}
+
+		w.Header().Set("Content-Type", "text/html")
+		w.Write([]byte(`<html><body><img src="/a.png"></body></html>`))
+	}))
+	defer site.Close()
+
+	exporter := tracetest.NewInMemoryExporter()
+	tp := sdktrace.NewTracerProvider(sdktrace.WithSyncer(exporter))
+
+	f := fetcher.NewFetcher(
+		fetcher.Mirror(), fetcher.RootDir(t.TempDir()), fetcher.TracerProvider(tp),
+	)
+	assert.NoError(t, f.Fetch(site.URL+"/page"))
+
+	spans := make(map[string]tracetest.SpanStub)
+	for _, span := range exporter.GetSpans() {
+		spans[span.Name] = span
+	}
+
+	for _, name := range []string{
+		"scrape", "http.request", "parse", "asset.download",
+		"store.asset", "store.metadata", "store.doc",
+	} {
+		assert.Contains(t, spans, name)
+	}
+
+	scrape := spans["scrape"]
+	assert.Equal(t, scrape.SpanContext.TraceID(), spans["asset.download"].SpanContext.TraceID())
+	assert.Equal(t, scrape.SpanContext.SpanID(), spans["parse"].Parent.SpanID())
+
+	attrs := make(map[string]string)
+	for _, attr := range scrape.Attributes {
+		attrs[string(attr.Key)] = attr.Value.Emit()
+	}
+	assert.Equal(t, site.URL+"/page", attrs["url"])
+	assert.NotEmpty(t, attrs["doc_name"])
+
+	var events []string
+	for _, event := range spans["http.request"].Events {
+		events = append(events, event.Name)
+	}
+	assert.Contains(t, events, "response.first_byte")
+}
@@ -4,9 +4,11 @@ import (
 	"net/url"
 
 	"github.com/wanliqun/web-fetcher/parser"
+	"github.com/wanliqun/web-fetcher/store"
 )
 
-// constructURLBaseName creates the base file name from a URL.
+// constructURLBaseName creates the collision-safe base file name from a URL, which
+// is the readable host, path and query followed by the short hash of the URL.
 func constructURLBaseName(docURL *url.URL) string {
 	docName := docURL.Host
 	if len(docURL.Path) > 1 { // Skip path `/` only
@@ -19,7 +21,7 @@ func constructURLBaseName(docURL *url.URL) string {
 		docName += "+" + docURL.RawQuery
 	}
 
-	return docName
+	return store.DocName(docName, docURL.String())
 }
 
 // determineBaseURL determines a final base URL from the HTML document and embedding webpage URL.
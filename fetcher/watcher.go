@@ -0,0 +1,141 @@
+package fetcher
+
+import (
+	"context"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"github.com/wanliqun/web-fetcher/types"
+)
+
+// Schedule determines the next fetching time of a web page.
+type Schedule interface {
+	// Next returns the next fetching time later than the given time.
+	Next(time.Time) time.Time
+}
+
+// IntervalSchedule schedules fetching every fixed interval.
+type IntervalSchedule time.Duration
+
+// Next implements the Schedule interface.
+func (s IntervalSchedule) Next(t time.Time) time.Time {
+	return t.Add(time.Duration(s))
+}
+
+// ScheduledRequest is a fetch request to be fetched on schedule.
+type ScheduledRequest struct {
+	*types.FetchRequest
+	Schedule Schedule
+
+	// Next time to fetch the web page.
+	next time.Time
+}
+
+// Watcher re-fetches web pages on schedule with the fetcher.
+type Watcher struct {
+	fetcher   *Fetcher
+	requests  []*ScheduledRequest
+	callbacks []func()
+}
+
+// NewWatcher creates a watcher for the scheduled requests. The request URLs are
+// canonicalized in place, and requests of the same canonical URL are deduped by
+// the seen-set of the fetcher, with the first one kept.
+func NewWatcher(fetcher *Fetcher, requests ...*ScheduledRequest) *Watcher {
+	w := &Watcher{fetcher: fetcher}
+	for _, req := range requests {
+		if fetcher.see(req.FetchRequest) {
+			w.requests = append(w.requests, req)
+		}
+	}
+	fetcher.resetSeen()
+
+	return w
+}
+
+// OnCycleDone registers a callback function to be invoked after each cycle
+// finishes. NB this function is not thread safe.
+func (w *Watcher) OnCycleDone(cb func()) {
+	w.callbacks = append(w.callbacks, cb)
+}
+
+// Run keeps re-fetching the web pages on schedule until the context is done,
+// and then waits for the in-flight fetches of the current cycle to finish.
+// The web pages are due on startup unless they have been fetched recently
+// according to the stored metadata.
+func (w *Watcher) Run(ctx context.Context) error {
+	if len(w.requests) == 0 {
+		return nil
+	}
+
+	logrus.WithField("numPages", len(w.requests)).Info("Watch started.")
+
+	now := time.Now()
+	for _, req := range w.requests {
+		req.next = now
+
+		metadata, err := w.fetcher.LoadMetadata(req.URL)
+		if err != nil {
+			logrus.WithField("URL", req.URL).WithError(err).Warn("Failed to load stored metadata.")
+			continue
+		}
+
+		if metadata != nil {
+			req.next = req.Schedule.Next(metadata.FetchedAt)
+		}
+	}
+
+	for cycle := 1; ; cycle++ {
+		timer := time.NewTimer(time.Until(w.nextDue()))
+
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return nil
+		case <-timer.C:
+		}
+
+		w.runCycle(cycle)
+	}
+}
+
+// nextDue returns the earliest next fetching time of all requests.
+func (w *Watcher) nextDue() (next time.Time) {
+	for _, req := range w.requests {
+		if next.IsZero() || req.next.Before(next) {
+			next = req.next
+		}
+	}
+
+	return next
+}
+
+// runCycle fetches all the due web pages and waits for them to finish.
+func (w *Watcher) runCycle(cycle int) {
+	start := time.Now()
+	w.fetcher.resetSeen()
+
+	var numDue int
+	for _, req := range w.requests {
+		if req.next.After(start) {
+			continue
+		}
+
+		numDue++
+		req.next = req.Schedule.Next(start)
+		w.fetcher.FetchRequest(req.FetchRequest)
+	}
+
+	w.fetcher.Wait()
+
+	logrus.WithFields(logrus.Fields{
+		"cycle":    cycle,
+		"numPages": numDue,
+		"duration": time.Since(start),
+		"nextDue":  w.nextDue(),
+	}).Info("Watch cycle completed.")
+
+	for _, cb := range w.callbacks {
+		cb()
+	}
+}
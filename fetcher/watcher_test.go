@@ -0,0 +1,95 @@
+package fetcher_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/wanliqun/web-fetcher/fetcher"
+	"github.com/wanliqun/web-fetcher/types"
+)
+
+func TestWatcher(t *testing.T) {
+	var numRequests atomic.Int32
+	contents := []string{"v1", "v2", "v2"}
+
+	site := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		i := int(numRequests.Add(1)) - 1
+		w.Header().Set("Content-Type", "text/html")
+		w.Write([]byte(`<html><body><p>` + contents[i%len(contents)] + `</p></body></html>`))
+	}))
+	defer site.Close()
+
+	f := fetcher.NewFetcher(fetcher.RootDir(t.TempDir()), fetcher.Async())
+
+	var changes []bool
+	f.OnFetched(func(r *types.FetchResult) {
+		if assert.NoError(t, r.Err) {
+			changes = append(changes, r.Metadata.ContentChanged)
+		}
+	})
+
+	// Requests of the same canonical URL are fetched once per cycle.
+	schedule := fetcher.IntervalSchedule(10 * time.Millisecond)
+	watcher := fetcher.NewWatcher(f,
+		&fetcher.ScheduledRequest{FetchRequest: &types.FetchRequest{URL: site.URL + "/page"}, Schedule: schedule},
+		&fetcher.ScheduledRequest{FetchRequest: &types.FetchRequest{URL: site.URL + "/page#top"}, Schedule: schedule},
+	)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	var numCycles int
+	watcher.OnCycleDone(func() {
+		if numCycles++; numCycles == len(contents) {
+			cancel()
+		}
+	})
+
+	assert.NoError(t, watcher.Run(ctx))
+	assert.EqualValues(t, len(contents), numRequests.Load())
+
+	// Changes are detected against the last fetch.
+	assert.Equal(t, []bool{false, true, false}, changes)
+}
+
+func TestWatcherStop(t *testing.T) {
+	var numRequests atomic.Int32
+	site := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		numRequests.Add(1)
+		w.Header().Set("Content-Type", "text/html")
+		w.Write([]byte(`<html><body>page</body></html>`))
+	}))
+	defer site.Close()
+
+	rootDir := t.TempDir()
+	assert.NoError(t, fetcher.NewFetcher(fetcher.RootDir(rootDir)).Fetch(site.URL))
+
+	// Web pages fetched recently are not due on startup.
+	watcher := fetcher.NewWatcher(fetcher.NewFetcher(fetcher.RootDir(rootDir), fetcher.Async()),
+		&fetcher.ScheduledRequest{
+			FetchRequest: &types.FetchRequest{URL: site.URL}, Schedule: fetcher.IntervalSchedule(time.Hour),
+		},
+	)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error)
+	go func() { done <- watcher.Run(ctx) }()
+
+	// The watch stops while waiting for the next due.
+	time.Sleep(50 * time.Millisecond)
+	cancel()
+
+	select {
+	case err := <-done:
+		assert.NoError(t, err)
+	case <-time.After(time.Second):
+		assert.Fail(t, "Watcher not stopped")
+	}
+
+	assert.EqualValues(t, 1, numRequests.Load())
+}
@@ -0,0 +1,122 @@
+package parser
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/PuerkitoBio/goquery"
+	"golang.org/x/net/html"
+)
+
+// DOMLines flattens the document into lines of element paths, each of which is
+// along with the sorted attributes or the own text of the element. Whitespace,
+// comments and elements matched by the volatile selectors are ignored, so that
+// the lines are stable against insignificant changes.
+func (p *Parser) DOMLines(volatileSelectors ...string) []string {
+	doc := p.withoutVolatile(volatileSelectors)
+
+	var lines []string
+	var walk func(n *html.Node, path string)
+	walk = func(n *html.Node, path string) {
+		counter := make(map[string]int)
+
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			switch c.Type {
+			case html.ElementNode:
+				counter[c.Data]++
+				childPath := fmt.Sprintf("%s/%s[%d]", path, c.Data, counter[c.Data])
+
+				line := childPath
+				if attrs := normalizeAttrs(c.Attr); len(attrs) > 0 {
+					line += " " + attrs
+				}
+				lines = append(lines, line)
+
+				walk(c, childPath)
+			case html.TextNode:
+				if text := normalizeSpace(c.Data); len(text) > 0 {
+					lines = append(lines, fmt.Sprintf("%s/text(): %q", path, text))
+				}
+			}
+		}
+	}
+
+	for _, n := range doc.Nodes {
+		walk(n, "")
+	}
+
+	return lines
+}
+
+// TextLines returns the lines of visible text within the document body, with
+// the elements matched by the volatile selectors ignored.
+func (p *Parser) TextLines(volatileSelectors ...string) (lines []string) {
+	body := p.withoutVolatile(volatileSelectors).Find("body")
+	body.Find("script, style, noscript, template").Remove()
+
+	for _, n := range body.Nodes {
+		for _, line := range strings.Split(newContentRenderer(false).render(n), "\n") {
+			if line = strings.TrimSpace(line); len(line) > 0 {
+				lines = append(lines, line)
+			}
+		}
+	}
+
+	return lines
+}
+
+// ContentHash computes the SHA-256 hash of the normalized DOM in hex format.
+func (p *Parser) ContentHash(volatileSelectors ...string) string {
+	hash := sha256.New()
+	for _, line := range p.DOMLines(volatileSelectors...) {
+		hash.Write([]byte(line))
+		hash.Write([]byte{'\n'})
+	}
+
+	return hex.EncodeToString(hash.Sum(nil))
+}
+
+// SelectorHash computes the SHA-256 hash of the normalized DOM of the elements
+// matched by the selector in hex format.
+func (p *Parser) SelectorHash(selector string, volatileSelectors ...string) string {
+	hash := sha256.New()
+	p.Document.Find(selector).Each(func(i int, s *goquery.Selection) {
+		hash.Write([]byte(normalizeAttrs(s.Get(0).Attr)))
+		hash.Write([]byte{'\n'})
+
+		scoped := &Parser{Document: goquery.NewDocumentFromNode(s.Get(0))}
+		for _, line := range scoped.DOMLines(volatileSelectors...) {
+			hash.Write([]byte(line))
+			hash.Write([]byte{'\n'})
+		}
+	})
+
+	return hex.EncodeToString(hash.Sum(nil))
+}
+
+// withoutVolatile returns a copy of the document with volatile elements removed.
+func (p *Parser) withoutVolatile(volatileSelectors []string) *goquery.Selection {
+	doc := p.Document.Selection.Clone()
+	for _, selector := range volatileSelectors {
+		doc.Find(selector).Remove()
+	}
+
+	return doc
+}
+
+func normalizeAttrs(attrs []html.Attribute) string {
+	if len(attrs) == 0 {
+		return ""
+	}
+
+	kvs := make([]string, 0, len(attrs))
+	for _, attr := range attrs {
+		kvs = append(kvs, fmt.Sprintf("%s=%q", attr.Key, normalizeSpace(attr.Val)))
+	}
+	sort.Strings(kvs)
+
+	return "[" + strings.Join(kvs, " ") + "]"
+}
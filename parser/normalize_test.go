@@ -0,0 +1,36 @@
+package parser_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/wanliqun/web-fetcher/parser"
+)
+
+func TestContentHash(t *testing.T) {
+	newParser := func(html string) *parser.Parser {
+		p, err := parser.NewParser(strings.NewReader(html))
+		assert.NoError(t, err)
+		return p
+	}
+
+	p1 := newParser(`<html><body><p class="a  b">Price: $10</p><span class="time">10:00</span></body></html>`)
+	p2 := newParser("<html>\n<body>\n  <!-- comment -->\n  <p class=\"a b\">Price:   $10</p>\n" +
+		"  <span class=\"time\">11:00</span>\n</body>\n</html>")
+	p3 := newParser(`<html><body><p class="a b">Price: $12</p><span class="time">10:00</span></body></html>`)
+
+	// Whitespace and comments are ignored, but not the volatile elements.
+	assert.NotEqual(t, p1.ContentHash(), p2.ContentHash())
+	assert.Equal(t, p1.ContentHash(".time"), p2.ContentHash(".time"))
+	assert.NotEqual(t, p1.ContentHash(".time"), p3.ContentHash(".time"))
+
+	assert.Equal(t, []string{
+		"/html[1]",
+		"/html[1]/head[1]",
+		"/html[1]/body[1]",
+		`/html[1]/body[1]/p[1] [class="a b"]`,
+		`/html[1]/body[1]/p[1]/text(): "Price: $10"`,
+	}, p2.DOMLines(".time"))
+	assert.Equal(t, []string{"Price: $10", "11:00"}, p2.TextLines())
+}
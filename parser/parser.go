@@ -38,6 +38,28 @@ func (p *Parser) ExtractMetadata() *types.Metadata {
 	}
 }
 
+// ExtractLinks extracts the non-empty link URLs within the document.
+func (p *Parser) ExtractLinks() (links []string) {
+	p.Document.Find("a[href]").Each(func(i int, s *goquery.Selection) {
+		if link, _ := s.Attr("href"); len(link) > 0 {
+			links = append(links, link)
+		}
+	})
+
+	return links
+}
+
+// ReplaceLinks replaces the link URLs within the document using the provided
+// transformation function.
+func (p *Parser) ReplaceLinks(transformer URLTransformer) {
+	p.Document.Find("a[href], area[href]").Each(func(i int, s *goquery.Selection) {
+		link, _ := s.Attr("href")
+		if newLink, ok := transformer(link); ok {
+			s.SetAttr("href", newLink)
+		}
+	})
+}
+
 // URLTransformer is a function type that transforms URLs.
 type URLTransformer func(string) (string, bool)
 
@@ -0,0 +1,500 @@
+package parser
+
+import (
+	"fmt"
+	"math"
+	"regexp"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/PuerkitoBio/goquery"
+	"github.com/wanliqun/web-fetcher/types"
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+)
+
+var (
+	// Elements that never belong to the main content.
+	boilerplateSelectors = "script, style, noscript, template, iframe, svg, canvas, " +
+		"nav, aside, footer, form, button, input, select, textarea"
+
+	// Elements whose text is taken into account for content scoring.
+	scoringSelectors = "p, pre, td, blockquote"
+
+	positiveClassRegexp = regexp.MustCompile(
+		`(?i)article|body|content|entry|main|page|post|story|text|blog`,
+	)
+	negativeClassRegexp = regexp.MustCompile(
+		`(?i)banner|combx|comment|community|disqus|footer|header|menu|modal|nav|` +
+			`related|remark|share|shoutbox|sidebar|sponsor|social|ad-|advert|popup|cookie`,
+	)
+
+	whitespaceRegexp = regexp.MustCompile(`\s+`)
+)
+
+// ExtractReadableContent finds the main content block of the document and strips
+// the boilerplate around it, the result is formatted as both Markdown and plain
+// text. The algorithm is a simplified variant of the Arc90 readability: paragraphs
+// contribute their scores to the ancestors, and the ancestor with the highest score
+// (penalized by its link density) is chosen as the main content.
+func (p *Parser) ExtractReadableContent() *types.ReadableContent {
+	content := &types.ReadableContent{
+		Title: strings.TrimSpace(p.Document.Find("title").First().Text()),
+	}
+
+	// Work on a copy so that the original document stays untouched.
+	main := p.findMainContent().Clone()
+	main.Find(boilerplateSelectors).Remove()
+	main.Find("*").Each(func(i int, s *goquery.Selection) {
+		if classWeight(s) < 0 && linkDensity(s) > 0.5 {
+			s.Remove()
+		}
+	})
+
+	for _, n := range main.Nodes {
+		content.Markdown += newContentRenderer(true).render(n)
+		content.Text += newContentRenderer(false).render(n)
+	}
+
+	return content
+}
+
+// findMainContent returns the top scored candidate for the main content, or the
+// document body if no candidate is found.
+func (p *Parser) findMainContent() *goquery.Selection {
+	body := p.Document.Find("body").First()
+	if body.Length() == 0 {
+		return p.Document.Selection
+	}
+
+	scores := make(map[*html.Node]float64)
+	var candidates []*goquery.Selection
+
+	addScore := func(s *goquery.Selection, score float64) {
+		if s.Length() == 0 || s.Is("body, html") {
+			return
+		}
+
+		node := s.Get(0)
+		if _, ok := scores[node]; !ok {
+			scores[node] = tagWeight(s) + float64(classWeight(s))
+			candidates = append(candidates, s)
+		}
+		scores[node] += score
+	}
+
+	body.Find(scoringSelectors).Each(func(i int, s *goquery.Selection) {
+		text := normalizeSpace(s.Text())
+		if utf8.RuneCountInString(text) < 25 {
+			return
+		}
+
+		// One base point, plus one point per comma and one point per 100
+		// characters (up to 3 points).
+		score := 1 + float64(strings.Count(text, ",")+strings.Count(text, "，"))
+		score += math.Min(float64(utf8.RuneCountInString(text))/100, 3)
+
+		addScore(s.Parent(), score)
+		addScore(s.Parent().Parent(), score/2)
+	})
+
+	var (
+		top      *goquery.Selection
+		topScore float64
+	)
+	for _, s := range candidates {
+		score := scores[s.Get(0)] * (1 - linkDensity(s))
+		if top == nil || score > topScore {
+			top, topScore = s, score
+		}
+	}
+
+	if top == nil {
+		return body
+	}
+
+	return top
+}
+
+// tagWeight returns the initial score of a candidate element by its tag name.
+func tagWeight(s *goquery.Selection) float64 {
+	switch {
+	case s.Is("article, main"):
+		return 10
+	case s.Is("div"):
+		return 5
+	case s.Is("pre, td, blockquote"):
+		return 3
+	case s.Is("address, ol, ul, dl, dd, dt, li, form"):
+		return -3
+	case s.Is("h1, h2, h3, h4, h5, h6, th"):
+		return -5
+	}
+
+	return 0
+}
+
+// classWeight returns the score of an element by its class name and ID.
+func classWeight(s *goquery.Selection) (weight int) {
+	for _, attr := range []string{"class", "id"} {
+		val, ok := s.Attr(attr)
+		if !ok || len(val) == 0 {
+			continue
+		}
+
+		if negativeClassRegexp.MatchString(val) {
+			weight -= 25
+		}
+
+		if positiveClassRegexp.MatchString(val) {
+			weight += 25
+		}
+	}
+
+	return weight
+}
+
+// linkDensity returns the ratio of the link text length against the total text
+// length of an element.
+func linkDensity(s *goquery.Selection) float64 {
+	textLen := utf8.RuneCountInString(normalizeSpace(s.Text()))
+	if textLen == 0 {
+		return 0
+	}
+
+	linkLen := utf8.RuneCountInString(normalizeSpace(s.Find("a").Text()))
+	return float64(linkLen) / float64(textLen)
+}
+
+func normalizeSpace(s string) string {
+	return strings.TrimSpace(whitespaceRegexp.ReplaceAllString(s, " "))
+}
+
+// contentRenderer renders an HTML node tree into Markdown or plain text.
+type contentRenderer struct {
+	// Whether to render with Markdown syntax or plain text.
+	markdown bool
+}
+
+func newContentRenderer(markdown bool) *contentRenderer {
+	return &contentRenderer{markdown: markdown}
+}
+
+func (r *contentRenderer) render(n *html.Node) string {
+	blocks := r.renderBlocks(n)
+	if len(blocks) == 0 {
+		return ""
+	}
+
+	return strings.Join(blocks, "\n\n") + "\n"
+}
+
+// renderBlocks renders the child nodes into blocks, with consecutive inline nodes
+// grouped into a single paragraph block.
+func (r *contentRenderer) renderBlocks(n *html.Node) (blocks []string) {
+	var inline strings.Builder
+	flushInline := func() {
+		if text := strings.TrimSpace(inline.String()); len(text) > 0 {
+			blocks = append(blocks, text)
+		}
+		inline.Reset()
+	}
+
+	for c := n.FirstChild; c != nil; c = c.NextSibling {
+		if !isBlockNode(c) {
+			inline.WriteString(r.renderInline(c))
+			continue
+		}
+
+		flushInline()
+		blocks = append(blocks, r.renderBlock(c)...)
+	}
+	flushInline()
+
+	return blocks
+}
+
+func (r *contentRenderer) renderBlock(n *html.Node) []string {
+	switch n.DataAtom {
+	case atom.H1, atom.H2, atom.H3, atom.H4, atom.H5, atom.H6:
+		text := strings.TrimSpace(r.renderInlineChildren(n))
+		if len(text) == 0 {
+			return nil
+		}
+
+		if r.markdown {
+			level := int(n.Data[1] - '0')
+			text = strings.Repeat("#", level) + " " + text
+		}
+		return []string{text}
+	case atom.P:
+		if text := strings.TrimSpace(r.renderInlineChildren(n)); len(text) > 0 {
+			return []string{text}
+		}
+		return nil
+	case atom.Pre:
+		return []string{r.renderPre(n)}
+	case atom.Ul, atom.Ol:
+		if list := r.renderList(n); len(list) > 0 {
+			return []string{list}
+		}
+		return nil
+	case atom.Blockquote:
+		blocks := r.renderBlocks(n)
+		if !r.markdown || len(blocks) == 0 {
+			return blocks
+		}
+		return []string{prefixLines(strings.Join(blocks, "\n\n"), "> ", "> ")}
+	case atom.Hr:
+		if r.markdown {
+			return []string{"---"}
+		}
+		return nil
+	case atom.Table:
+		if table := r.renderTable(n); len(table) > 0 {
+			return []string{table}
+		}
+		return nil
+	}
+
+	// Generic containers such as `div`, `section` and `article`.
+	return r.renderBlocks(n)
+}
+
+func (r *contentRenderer) renderPre(n *html.Node) string {
+	code := strings.Trim(nodeText(n), "\n")
+	if !r.markdown {
+		return code
+	}
+
+	// Detect the code language from class like `language-go` or `lang-go`.
+	var lang string
+	for _, node := range []*html.Node{n, n.FirstChild} {
+		if node == nil || node.Type != html.ElementNode {
+			continue
+		}
+
+		for _, class := range strings.Fields(attrValue(node, "class")) {
+			if l, ok := strings.CutPrefix(class, "language-"); ok {
+				lang = l
+			} else if l, ok := strings.CutPrefix(class, "lang-"); ok {
+				lang = l
+			}
+		}
+	}
+
+	fence := "```"
+	for strings.Contains(code, fence) {
+		fence += "`"
+	}
+
+	return fence + lang + "\n" + code + "\n" + fence
+}
+
+func (r *contentRenderer) renderList(n *html.Node) string {
+	var items []string
+
+	index := 1
+	for c := n.FirstChild; c != nil; c = c.NextSibling {
+		if c.DataAtom != atom.Li {
+			continue
+		}
+
+		marker := "- "
+		if n.DataAtom == atom.Ol {
+			marker = fmt.Sprintf("%d. ", index)
+		}
+		index++
+
+		item := strings.Join(r.renderBlocks(c), "\n")
+		if len(item) == 0 {
+			continue
+		}
+
+		indent := strings.Repeat(" ", len(marker))
+		items = append(items, prefixLines(item, marker, indent))
+	}
+
+	return strings.Join(items, "\n")
+}
+
+func (r *contentRenderer) renderTable(n *html.Node) string {
+	var rows [][]string
+
+	var walk func(*html.Node)
+	walk = func(n *html.Node) {
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			if c.DataAtom != atom.Tr {
+				walk(c)
+				continue
+			}
+
+			var row []string
+			for cell := c.FirstChild; cell != nil; cell = cell.NextSibling {
+				if cell.DataAtom == atom.Td || cell.DataAtom == atom.Th {
+					text := normalizeSpace(r.renderInlineChildren(cell))
+					row = append(row, strings.ReplaceAll(text, "|", `\|`))
+				}
+			}
+
+			if len(row) > 0 {
+				rows = append(rows, row)
+			}
+		}
+	}
+	walk(n)
+
+	if len(rows) == 0 {
+		return ""
+	}
+
+	lines := make([]string, 0, len(rows)+1)
+	for i, row := range rows {
+		if !r.markdown {
+			lines = append(lines, strings.Join(row, "\t"))
+			continue
+		}
+
+		lines = append(lines, "| "+strings.Join(row, " | ")+" |")
+		if i == 0 { // Take the first row as the table header.
+			lines = append(lines, "|"+strings.Repeat(" --- |", len(row)))
+		}
+	}
+
+	return strings.Join(lines, "\n")
+}
+
+func (r *contentRenderer) renderInlineChildren(n *html.Node) string {
+	var sb strings.Builder
+	for c := n.FirstChild; c != nil; c = c.NextSibling {
+		sb.WriteString(r.renderInline(c))
+	}
+
+	return sb.String()
+}
+
+func (r *contentRenderer) renderInline(n *html.Node) string {
+	switch n.Type {
+	case html.TextNode:
+		return whitespaceRegexp.ReplaceAllString(n.Data, " ")
+	case html.ElementNode:
+	default:
+		return ""
+	}
+
+	switch n.DataAtom {
+	case atom.Br:
+		if r.markdown {
+			return "  \n"
+		}
+		return "\n"
+	case atom.Img:
+		alt := attrValue(n, "alt")
+		if !r.markdown {
+			return alt
+		}
+
+		src := attrValue(n, "src")
+		if len(src) == 0 {
+			return alt
+		}
+		return fmt.Sprintf("![%s](%s)", alt, src)
+	case atom.Code, atom.Kbd, atom.Samp:
+		code := nodeText(n)
+		if !r.markdown || len(code) == 0 {
+			return code
+		}
+		return "`" + code + "`"
+	}
+
+	text := r.renderInlineChildren(n)
+	if !r.markdown || len(strings.TrimSpace(text)) == 0 {
+		return text
+	}
+
+	switch n.DataAtom {
+	case atom.A:
+		href := attrValue(n, "href")
+		if len(href) == 0 || strings.HasPrefix(href, "#") ||
+			strings.HasPrefix(strings.ToLower(href), "javascript:") {
+			return text
+		}
+		return fmt.Sprintf("[%s](%s)", strings.TrimSpace(text), href)
+	case atom.Strong, atom.B:
+		return wrapInline(text, "**")
+	case atom.Em, atom.I:
+		return wrapInline(text, "_")
+	case atom.Del, atom.S, atom.Strike:
+		return wrapInline(text, "~~")
+	}
+
+	return text
+}
+
+// wrapInline wraps the text with the Markdown delimiter, with leading and trailing
+// spaces kept outside of the delimiters.
+func wrapInline(text, delim string) string {
+	trimmed := strings.TrimSpace(text)
+	start := strings.Index(text, trimmed)
+	return text[:start] + delim + trimmed + delim + text[start+len(trimmed):]
+}
+
+// prefixLines prefixes the first line and the subsequent lines of the text.
+func prefixLines(text, first, rest string) string {
+	lines := strings.Split(text, "\n")
+	for i := range lines {
+		prefix := rest
+		if i == 0 {
+			prefix = first
+		}
+
+		if len(lines[i]) == 0 {
+			lines[i] = strings.TrimRight(prefix, " ")
+		} else {
+			lines[i] = prefix + lines[i]
+		}
+	}
+
+	return strings.Join(lines, "\n")
+}
+
+// isBlockNode checks if the node is a block-level element.
+func isBlockNode(n *html.Node) bool {
+	if n.Type != html.ElementNode {
+		return false
+	}
+
+	switch n.DataAtom {
+	case atom.Address, atom.Article, atom.Blockquote, atom.Dd, atom.Details, atom.Div,
+		atom.Dl, atom.Dt, atom.Figcaption, atom.Figure, atom.H1, atom.H2, atom.H3,
+		atom.H4, atom.H5, atom.H6, atom.Header, atom.Hr, atom.Li, atom.Main, atom.Ol,
+		atom.P, atom.Pre, atom.Section, atom.Summary, atom.Table, atom.Ul:
+		return true
+	}
+
+	return false
+}
+
+// nodeText returns the raw text content of the node without whitespace collapsed.
+func nodeText(n *html.Node) string {
+	if n.Type == html.TextNode {
+		return n.Data
+	}
+
+	var sb strings.Builder
+	for c := n.FirstChild; c != nil; c = c.NextSibling {
+		sb.WriteString(nodeText(c))
+	}
+
+	return sb.String()
+}
+
+func attrValue(n *html.Node, key string) string {
+	for _, attr := range n.Attr {
+		if attr.Key == key {
+			return attr.Val
+		}
+	}
+
+	return ""
+}
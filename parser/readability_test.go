@@ -0,0 +1,56 @@
+package parser_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/wanliqun/web-fetcher/parser"
+)
+
+const testArticleHTMLString = `
+<!DOCTYPE html>
+<html>
+<head><title>Test Article</title></head>
+<body>
+<nav class="menu"><a href="/">Home</a> <a href="/about">About</a></nav>
+<div id="sidebar" class="sidebar"><a href="/a">Related A</a><a href="/b">Related B</a></div>
+<div class="post-content">
+<h1>Hello <em>World</em></h1>
+<p>This is the first paragraph, which has enough text, commas, and a <a href="https://example.com">link</a> inside it.</p>
+<p>This is the second paragraph, with some <strong>bold text</strong> and <code>inline code</code> for testing.</p>
+<ul><li>First item</li><li>Second item</li></ul>
+<ol><li>Step one</li><li>Step two</li></ol>
+<pre><code class="language-go">fmt.Println("hello")</code></pre>
+<script>alert("boilerplate");</script>
+</div>
+<footer>Copyright notice, all rights reserved, do not copy anything from here.</footer>
+</body>
+</html>
+`
+
+func TestExtractReadableContent(t *testing.T) {
+	p, err := parser.NewParser(strings.NewReader(testArticleHTMLString))
+	assert.NoError(t, err)
+
+	content := p.ExtractReadableContent()
+	assert.Equal(t, "Test Article", content.Title)
+
+	expectedMarkdown := "# Hello _World_\n\n" +
+		"This is the first paragraph, which has enough text, commas, and a " +
+		"[link](https://example.com) inside it.\n\n" +
+		"This is the second paragraph, with some **bold text** and `inline code` for testing.\n\n" +
+		"- First item\n- Second item\n\n" +
+		"1. Step one\n2. Step two\n\n" +
+		"```go\nfmt.Println(\"hello\")\n```\n"
+	assert.Equal(t, expectedMarkdown, content.Markdown)
+
+	for _, boilerplate := range []string{"Home", "Related", "Copyright", "alert"} {
+		assert.NotContains(t, content.Text, boilerplate)
+	}
+	assert.Contains(t, content.Text, "Hello World\n\n")
+	assert.Contains(t, content.Text, "with some bold text and inline code")
+
+	// The original document should stay untouched.
+	assert.Equal(t, 1, p.Document.Find("script").Length())
+}
@@ -0,0 +1,248 @@
+package parser
+
+import (
+	"net/url"
+	"strings"
+
+	"github.com/PuerkitoBio/goquery"
+	"github.com/pkg/errors"
+	"golang.org/x/net/html"
+)
+
+// Names of the builtin sanitization profiles.
+const (
+	SanitizeNone   = "none"
+	SanitizeBasic  = "basic"
+	SanitizeStrict = "strict"
+)
+
+var (
+	// DefaultTrackerDomains are the domains of known trackers and ad networks,
+	// whose subdomains are matched as well.
+	DefaultTrackerDomains = []string{
+		"google-analytics.com", "googletagmanager.com", "googlesyndication.com",
+		"googleadservices.com", "doubleclick.net", "facebook.net", "hotjar.com",
+		"scorecardresearch.com", "quantserve.com", "segment.com", "segment.io",
+		"mixpanel.com", "bat.bing.com", "analytics.twitter.com", "ads-twitter.com",
+		"criteo.com", "criteo.net", "taboola.com", "outbrain.com", "adnxs.com",
+		"amazon-adsystem.com", "nr-data.net", "clarity.ms", "chartbeat.com",
+	}
+
+	// OfflineCSP is the content security policy which blocks network access,
+	// with inline styles and local or data URL resources allowed only.
+	OfflineCSP = "default-src 'none'; img-src 'self' file: data:; style-src 'self' file: data: 'unsafe-inline'; " +
+		"font-src 'self' file: data:; media-src 'self' file: data:; form-action 'none'"
+
+	// Elements whose URL attributes are checked against the tracker domains.
+	trackerUrlAttrs = map[string]string{
+		"script": "src", "img": "src", "iframe": "src", "frame": "src", "link": "href",
+		"source": "src", "embed": "src", "object": "data", "audio": "src", "video": "src",
+	}
+
+	// Attributes which may carry `javascript:` or `data:` URLs.
+	scriptableUrlAttrs = []string{"href", "src", "action", "formaction", "data", "xlink:href"}
+)
+
+// SanitizeProfile determines how the document is sanitized for safe offline
+// viewing, so that opening it never runs scripts or phones home.
+type SanitizeProfile struct {
+	// StripScripts removes the scripts, plugins such as `<object>` and `<embed>`,
+	// inline event handlers, `srcdoc` frames, `javascript:` URLs and `data:` URLs
+	// other than images, with JSON-LD structured data kept.
+	StripScripts bool
+	// StripTrackers removes the elements loaded from the tracker domains, and
+	// tracking pixels such as 1x1 images.
+	StripTrackers bool
+	// TrackerDomains are the tracker domains to strip, DefaultTrackerDomains
+	// if empty.
+	TrackerDomains []string
+	// NeutralizeRefresh removes `<meta http-equiv=refresh>` redirects.
+	NeutralizeRefresh bool
+	// StripThirdPartyFrames removes the frames from hosts other than the page.
+	StripThirdPartyFrames bool
+	// BlockNetwork adds the OfflineCSP as a CSP meta tag, which blocks network
+	// access of the resources left.
+	BlockNetwork bool
+}
+
+// SanitizeProfileByName looks up the builtin sanitization profile by name, nil
+// is returned for the `none` profile.
+func SanitizeProfileByName(name string) (*SanitizeProfile, error) {
+	switch strings.ToLower(name) {
+	case "", SanitizeNone:
+		return nil, nil
+	case SanitizeBasic:
+		return &SanitizeProfile{StripScripts: true, NeutralizeRefresh: true}, nil
+	case SanitizeStrict:
+		return &SanitizeProfile{
+			StripScripts:          true,
+			StripTrackers:         true,
+			NeutralizeRefresh:     true,
+			StripThirdPartyFrames: true,
+			BlockNetwork:          true,
+		}, nil
+	}
+
+	return nil, errors.Errorf(
+		"sanitize profile expected %v, %v or %v got %q", SanitizeNone, SanitizeBasic, SanitizeStrict, name,
+	)
+}
+
+// Sanitize sanitizes the document by the profile. Relative URLs are resolved
+// against the base URL, and hosts other than the page host are third-party.
+func (p *Parser) Sanitize(profile *SanitizeProfile, pageURL, baseURL *url.URL) {
+	if profile == nil {
+		return
+	}
+
+	doc := p.Document
+
+	if profile.StripScripts {
+		doc.Find(`script:not([type="application/ld+json"]), link[rel~=modulepreload], link[as=script], ` +
+			`object, embed, applet`).Remove()
+
+		doc.Find("*").Each(func(i int, s *goquery.Selection) {
+			n := s.Nodes[0]
+
+			attrs := n.Attr[:0]
+			for _, attr := range n.Attr {
+				if isEventHandler(attr) || strings.EqualFold(attr.Key, "srcdoc") || isScriptableURL(n.Data, attr) {
+					continue
+				}
+				attrs = append(attrs, attr)
+			}
+			n.Attr = attrs
+		})
+	}
+
+	if profile.NeutralizeRefresh {
+		doc.Find("meta[http-equiv]").Each(func(i int, s *goquery.Selection) {
+			if equiv, _ := s.Attr("http-equiv"); strings.EqualFold(strings.TrimSpace(equiv), "refresh") {
+				s.Remove()
+			}
+		})
+	}
+
+	if profile.StripTrackers {
+		domains := profile.TrackerDomains
+		if len(domains) == 0 {
+			domains = DefaultTrackerDomains
+		}
+
+		for tag, attr := range trackerUrlAttrs {
+			doc.Find(tag).Each(func(i int, s *goquery.Selection) {
+				if urlObj, ok := resolveURL(s, attr, baseURL); ok && matchDomain(urlObj.Hostname(), domains) {
+					s.Remove()
+				}
+			})
+		}
+
+		doc.Find("img").Each(func(i int, s *goquery.Selection) {
+			if width, _ := s.Attr("width"); strings.TrimSpace(width) == "1" {
+				if height, _ := s.Attr("height"); strings.TrimSpace(height) == "1" {
+					s.Remove()
+				}
+			}
+		})
+
+		doc.Find("a[ping], area[ping]").RemoveAttr("ping")
+	}
+
+	if profile.StripThirdPartyFrames {
+		doc.Find("iframe, frame").Each(func(i int, s *goquery.Selection) {
+			if urlObj, ok := resolveURL(s, "src", baseURL); ok && !strings.EqualFold(urlObj.Host, pageURL.Host) {
+				s.Remove()
+			}
+		})
+	}
+
+	if profile.BlockNetwork {
+		// The policy must precede the resources to apply, so it goes first.
+		head := doc.Find("head").First()
+		head.PrependNodes(&html.Node{
+			Type: html.ElementNode,
+			Data: "meta",
+			Attr: []html.Attribute{
+				{Key: "http-equiv", Val: "Content-Security-Policy"},
+				{Key: "content", Val: OfflineCSP},
+			},
+		})
+	}
+}
+
+// isEventHandler checks if the attribute is an inline event handler such as `onclick`.
+func isEventHandler(attr html.Attribute) bool {
+	return len(attr.Namespace) == 0 && strings.HasPrefix(strings.ToLower(attr.Key), "on")
+}
+
+// isScriptableURL checks if the URL attribute of the element is a `javascript:`
+// URL, or a `data:` URL other than images which may be a document with scripts,
+// with the whitespace and control characters ignored as browsers do. SVG images
+// are only safe within `<img>`, where scripts never run.
+func isScriptableURL(tag string, attr html.Attribute) bool {
+	if len(attr.Namespace) > 0 {
+		attr.Key = attr.Namespace + ":" + attr.Key
+	}
+
+	for _, key := range scriptableUrlAttrs {
+		if !strings.EqualFold(attr.Key, key) {
+			continue
+		}
+
+		val := strings.ToLower(strings.Map(func(r rune) rune {
+			if r <= ' ' {
+				return -1
+			}
+			return r
+		}, attr.Val))
+
+		if strings.HasPrefix(val, "javascript:") {
+			return true
+		}
+
+		mediaType, ok := strings.CutPrefix(val, "data:")
+		if !ok {
+			return false
+		}
+
+		if i := strings.IndexAny(mediaType, ";,"); i >= 0 {
+			mediaType = mediaType[:i]
+		}
+
+		if mediaType == "image/svg+xml" {
+			return tag != "img"
+		}
+		return !strings.HasPrefix(mediaType, "image/")
+	}
+
+	return false
+}
+
+// resolveURL resolves the URL attribute against the base URL, which is false if
+// the attribute is missing or invalid.
+func resolveURL(s *goquery.Selection, attr string, baseURL *url.URL) (*url.URL, bool) {
+	val, ok := s.Attr(attr)
+	if !ok || len(strings.TrimSpace(val)) == 0 {
+		return nil, false
+	}
+
+	urlObj, err := url.Parse(strings.TrimSpace(val))
+	if err != nil {
+		return nil, false
+	}
+
+	return baseURL.ResolveReference(urlObj), true
+}
+
+// matchDomain checks if the host is any of the domains or their subdomains.
+func matchDomain(host string, domains []string) bool {
+	host = strings.ToLower(host)
+	for _, domain := range domains {
+		domain = strings.ToLower(domain)
+		if host == domain || strings.HasSuffix(host, "."+domain) {
+			return true
+		}
+	}
+
+	return false
+}
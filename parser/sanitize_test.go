@@ -0,0 +1,99 @@
+package parser_test
+
+import (
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/wanliqun/web-fetcher/parser"
+)
+
+const testUnsafeHTMLString = `
+<html>
+<head>
+<meta http-equiv="Refresh" content="0; url=https://example.org/">
+<script src="/app.js"></script>
+<script type="application/ld+json">{"@type": "Article"}</script>
+<script src="https://www.googletagmanager.com/gtag/js"></script>
+</head>
+<body onload="track()">
+<a href=" java&#x09;script:alert(1)" ping="https://t.example.org/">Click</a>
+<img src="/logo.png">
+<img src="https://stats.g.doubleclick.net/p.gif">
+<img src="https://cdn.example.org/pixel.gif" width="1" height="1">
+<iframe src="/embed"></iframe>
+<iframe src="https://ads.example.org/frame"></iframe>
+</body>
+</html>
+`
+
+func TestSanitize(t *testing.T) {
+	pageURL, _ := url.Parse("https://example.com/post")
+
+	profile, err := parser.SanitizeProfileByName(parser.SanitizeStrict)
+	assert.NoError(t, err)
+
+	p, err := parser.NewParser(strings.NewReader(testUnsafeHTMLString))
+	assert.NoError(t, err)
+	p.Sanitize(profile, pageURL, pageURL)
+
+	html, err := p.Document.Html()
+	assert.NoError(t, err)
+
+	for _, s := range []string{
+		"Refresh", "/app.js", "googletagmanager", "onload", "javascript", "ping",
+		"doubleclick", "pixel.gif", "ads.example.org",
+	} {
+		assert.NotContains(t, html, s)
+	}
+
+	for _, s := range []string{
+		"application/ld+json", `<img src="/logo.png"/>`, `<iframe src="/embed">`,
+		`<head><meta http-equiv="Content-Security-Policy" content="default-src &#39;none&#39;;`,
+	} {
+		assert.Contains(t, html, s)
+	}
+
+	profile, err = parser.SanitizeProfileByName(parser.SanitizeNone)
+	assert.NoError(t, err)
+	assert.Nil(t, profile)
+
+	_, err = parser.SanitizeProfileByName("paranoid")
+	assert.Error(t, err)
+}
+
+func TestSanitizeScriptableContent(t *testing.T) {
+	pageURL, _ := url.Parse("https://example.com/post")
+	profile, _ := parser.SanitizeProfileByName(parser.SanitizeBasic)
+
+	testCases := []struct {
+		html, unexpected string
+	}{
+		{`<iframe srcdoc="<script>alert(1)</script>"></iframe>`, "srcdoc"},
+		{`<iframe src="data:text/html,<script>alert(1)</script>"></iframe>`, "data:"},
+		{`<a href="DATA:text/html;base64,PHNjcmlwdD4=">x</a>`, "base64"},
+		{`<iframe src="data:image/svg+xml,<svg onload=alert(1)>"></iframe>`, "data:"},
+		{`<svg><a xlink:href="data:text/html,x">x</a></svg>`, "data:"},
+		{`<object data="/movie.swf"></object>`, "<object"},
+		{`<embed src="/movie.swf"/>`, "<embed"},
+	}
+
+	for _, tc := range testCases {
+		p, err := parser.NewParser(strings.NewReader(tc.html))
+		assert.NoError(t, err)
+		p.Sanitize(profile, pageURL, pageURL)
+
+		html, err := p.Document.Html()
+		assert.NoError(t, err)
+		assert.NotContains(t, html, tc.unexpected, tc.html)
+	}
+
+	// Data URLs of images are kept.
+	p, err := parser.NewParser(strings.NewReader(
+		`<img src="data:image/png;base64,iVBORw0KGgo="><img src="data:image/svg+xml,<svg></svg>">`,
+	))
+	assert.NoError(t, err)
+	p.Sanitize(profile, pageURL, pageURL)
+	assert.Equal(t, 2, p.Document.Find(`img[src^="data:image/"]`).Length())
+}
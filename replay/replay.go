@@ -0,0 +1,467 @@
+package replay
+
+import (
+	"bytes"
+	"html/template"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+	"github.com/wanliqun/web-fetcher/fetcher"
+	"github.com/wanliqun/web-fetcher/parser"
+	"github.com/wanliqun/web-fetcher/store"
+	"github.com/wanliqun/web-fetcher/types"
+)
+
+const (
+	// URL path prefix to replay archived web pages: `/web/<timestamp>/<url>`.
+	webPathPrefix = "/web/"
+	// URL path prefix to serve the stored files: `/files/<path>`.
+	filesPathPrefix = "/files/"
+
+	timestampLayout = "20060102150405"
+)
+
+var indexTemplate = template.Must(template.New("index").Parse(`<!DOCTYPE html>
+<html>
+<head><meta charset="utf-8"><title>Web Fetcher Archive</title></head>
+<body>
+<h1>Web Fetcher Archive</h1>
+<table border="1" cellpadding="4" cellspacing="0">
+<tr><th>URL</th><th>Fetched At</th><th>Links</th><th>Images</th><th>Words</th><th>Versions</th></tr>
+{{range .}}<tr>
+<td><a href="{{.ReplayPath}}">{{.Metadata.URL}}</a></td>
+<td>{{.Metadata.FetchedAt.Format "2006-01-02 15:04:05 MST"}}</td>
+<td>{{.Metadata.NumLinks}}</td>
+<td>{{.Metadata.NumImages}}</td>
+<td>{{.Metadata.NumWords}}</td>
+<td>{{range .Versions}}<a href="{{.ReplayPath}}">{{.Version}}</a> {{end}}</td>
+</tr>
+{{end}}</table>
+</body>
+</html>
+`))
+
+// Handler serves the archived web pages within the store root directory, with
+// links rewritten on the fly to replay the archive.
+type Handler struct {
+	// Root directory of the store.
+	rootDir string
+	// Serves the stored files such as the downloaded assets, which are limited
+	// to the files of the archived web pages.
+	fileServer http.Handler
+
+	mu sync.Mutex
+	// Archive cached until the root directory is modified.
+	archive        map[string]*archivedDoc
+	archiveModTime time.Time
+}
+
+// NewHandler creates a replay handler for the store root directory.
+func NewHandler(rootDir string) *Handler {
+	rootDir = store.ResolveRootDir(rootDir)
+	fileServer := http.FileServer(http.Dir(rootDir))
+
+	return &Handler{
+		rootDir:    rootDir,
+		fileServer: http.StripPrefix(strings.TrimSuffix(filesPathPrefix, "/"), fileServer),
+	}
+}
+
+// ServeHTTP implements the http.Handler interface. The request path is routed
+// manually rather than by http.ServeMux, which would clean the `//` within the
+// archived URL.
+func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	switch path := r.URL.Path; {
+	case path == "/":
+		h.serveIndex(w, r)
+	case strings.HasPrefix(path, webPathPrefix):
+		h.serveWeb(w, r)
+	case strings.HasPrefix(path, filesPathPrefix):
+		h.serveFile(w, r)
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+// archivedDoc is an archived web page within the store.
+type archivedDoc struct {
+	Metadata *types.Metadata
+
+	fs *store.FileStore
+	// Versions cached until the history manifest is modified, which is guarded
+	// by the handler mutex.
+	versions        []*archivedVersion
+	versionsModTime time.Time
+}
+
+// indexEntry is an archived web page listed on the index page.
+type indexEntry struct {
+	*archivedDoc
+	Versions []*archivedVersion
+}
+
+// archivedVersion is a version kept in history of an archived web page.
+type archivedVersion struct {
+	*store.HistoryVersion
+	ReplayPath string
+}
+
+// ReplayPath returns the replay path of the latest fetch.
+func (d *archivedDoc) ReplayPath() string {
+	return replayPath(d.Metadata.FetchedAt.UTC().Format(timestampLayout), d.Metadata.URL)
+}
+
+func replayPath(timestamp, pageURL string) string {
+	return webPathPrefix + timestamp + "/" + pageURL
+}
+
+// loadArchive loads the archived web pages keyed by the normalized URL, which
+// are cached until the modification time of the store root directory changes,
+// as the metadata files are replaced upon each fetch.
+func (h *Handler) loadArchive() (map[string]*archivedDoc, error) {
+	info, err := os.Stat(h.rootDir)
+	if err != nil {
+		return nil, errors.WithMessage(err, "failed to stat directory")
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if h.archive != nil && info.ModTime().Equal(h.archiveModTime) {
+		return h.archive, nil
+	}
+
+	archive, err := h.scanArchive()
+	if err != nil {
+		return nil, err
+	}
+
+	h.archive, h.archiveModTime = archive, info.ModTime()
+	return archive, nil
+}
+
+// scanArchive scans the store root directory for the archived web pages by their
+// metadata files, keyed by the normalized URL.
+func (h *Handler) scanArchive() (map[string]*archivedDoc, error) {
+	entries, err := os.ReadDir(h.rootDir)
+	if err != nil {
+		return nil, errors.WithMessage(err, "failed to read directory")
+	}
+
+	docs := make(map[string]*archivedDoc)
+	for _, entry := range entries {
+		name := entry.Name()
+		if entry.IsDir() || filepath.Ext(name) != ".json" {
+			continue
+		}
+
+		fs, err := store.NewFileStore(h.rootDir, strings.TrimSuffix(name, ".json"))
+		if err != nil {
+			return nil, errors.WithMessage(err, "failed to new file store")
+		}
+
+		// Skip the JSON files which are not metadata.
+		metadata, err := fs.LoadMetadata()
+		if err != nil || metadata == nil || len(metadata.URL) == 0 {
+			continue
+		}
+
+		docs[normalizeURL(metadata.URL)] = &archivedDoc{Metadata: metadata, fs: fs}
+	}
+
+	return docs, nil
+}
+
+// loadVersions loads the versions kept in history of the archived web page,
+// which are cached until the history manifest is modified.
+func (h *Handler) loadVersions(doc *archivedDoc) []*archivedVersion {
+	// No version is kept if the manifest is missing, which is zero time.
+	var modTime time.Time
+	if info, err := os.Stat(doc.fs.ManifestFilePath()); err == nil {
+		modTime = info.ModTime()
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if modTime.Equal(doc.versionsModTime) {
+		return doc.versions
+	}
+
+	versions, err := doc.fs.ListVersions()
+	if err != nil {
+		logrus.WithField("URL", doc.Metadata.URL).WithError(err).Warn("Failed to list versions.")
+	}
+
+	doc.versions = nil
+	for _, v := range versions {
+		doc.versions = append(doc.versions, &archivedVersion{
+			HistoryVersion: v,
+			ReplayPath:     replayPath(v.Version, doc.Metadata.URL),
+		})
+	}
+	doc.versionsModTime = modTime
+
+	return doc.versions
+}
+
+func (h *Handler) serveIndex(w http.ResponseWriter, r *http.Request) {
+	archive, err := h.loadArchive()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	entries := make([]*indexEntry, 0, len(archive))
+	for _, doc := range archive {
+		entries = append(entries, &indexEntry{archivedDoc: doc, Versions: h.loadVersions(doc)})
+	}
+
+	sort.Slice(entries, func(i, j int) bool {
+		return entries[i].Metadata.URL < entries[j].Metadata.URL
+	})
+
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	if err := indexTemplate.Execute(w, entries); err != nil {
+		logrus.WithError(err).Error("Failed to render index page.")
+	}
+}
+
+func (h *Handler) serveWeb(w http.ResponseWriter, r *http.Request) {
+	timestamp, pageURL, ok := parseWebPath(r)
+	if !ok {
+		http.Error(w, "expected path /web/<timestamp>/<url>", http.StatusBadRequest)
+		return
+	}
+
+	archive, err := h.loadArchive()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	doc, ok := archive[normalizeURL(pageURL)]
+	if !ok {
+		http.NotFound(w, r)
+		return
+	}
+
+	// Non-HTML content such as PDFs and images are served as they are.
+	if contentType := doc.Metadata.ContentType; !store.IsHTMLType(contentType) {
+		w.Header().Set("Content-Type", contentType)
+		http.ServeFile(w, r, doc.fs.DocFilePath(contentType))
+		return
+	}
+
+	docPath, timestamp := h.resolveDocPath(doc, h.loadVersions(doc), timestamp)
+
+	content, err := os.ReadFile(docPath)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	content, err = h.rewrite(content, doc.Metadata.URL, timestamp, archive)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Write(content)
+}
+
+// resolveDocPath resolves the HTML document path of the version closest to the
+// timestamp, or the latest fetch if there is no history.
+func (h *Handler) resolveDocPath(
+	doc *archivedDoc, versions []*archivedVersion, timestamp string) (string, string) {
+
+	if len(versions) == 0 {
+		return doc.fs.HtmlDocPath(), doc.Metadata.FetchedAt.UTC().Format(timestampLayout)
+	}
+
+	// Pick the latest version at or before the timestamp, or the earliest one.
+	version := versions[0]
+	for _, v := range versions {
+		if v.Version <= timestamp {
+			version = v
+		}
+	}
+
+	return doc.fs.VersionStore(version.Version).HtmlDocPath(), version.Version
+}
+
+// rewrite rewrites the asset URLs of local files to the stored file paths, and
+// other URLs to the replay paths if archived or to absolute URLs otherwise.
+func (h *Handler) rewrite(
+	content []byte, pageURL, timestamp string, archive map[string]*archivedDoc) ([]byte, error) {
+
+	domParser, err := parser.NewParser(bytes.NewReader(content))
+	if err != nil {
+		return nil, errors.WithMessage(err, "failed to new DOM parser")
+	}
+
+	pageUrlObj, err := url.Parse(pageURL)
+	if err != nil {
+		return nil, errors.WithMessage(err, "invalid page URL")
+	}
+
+	baseUrlObj := pageUrlObj
+	if base, ok := domParser.Document.Find("base[href]").Attr("href"); ok {
+		if baseRefObj, err := url.Parse(base); err == nil {
+			baseUrlObj = pageUrlObj.ResolveReference(baseRefObj)
+		}
+	}
+	domParser.Document.Find("base").Remove()
+
+	transformer := func(rawURL string) (string, bool) {
+		urlObj, err := url.Parse(rawURL)
+		if err != nil || strings.HasPrefix(rawURL, "#") {
+			return "", false
+		}
+
+		if urlObj.Scheme == "file" {
+			relPath, err := filepath.Rel(h.rootDir, urlObj.Path)
+			if err != nil || strings.HasPrefix(relPath, "..") {
+				return "", false
+			}
+			return filesPathPrefix + filepath.ToSlash(relPath), true
+		}
+
+		absUrlObj := baseUrlObj.ResolveReference(urlObj)
+		if absUrlObj.Scheme != "http" && absUrlObj.Scheme != "https" {
+			return "", false
+		}
+
+		fragment := absUrlObj.Fragment
+		absUrlObj.Fragment = ""
+		if doc, ok := archive[normalizeURL(absUrlObj.String())]; ok {
+			path := replayPath(timestamp, doc.Metadata.URL)
+			if len(fragment) > 0 {
+				path += "#" + fragment
+			}
+			return path, true
+		}
+
+		absUrlObj.Fragment = fragment
+		return absUrlObj.String(), true
+	}
+
+	domParser.ReplaceAssets(transformer)
+	domParser.ReplaceLinks(transformer)
+
+	html, err := domParser.Document.Html()
+	if err != nil {
+		return nil, errors.WithMessage(err, "invalid HTML document")
+	}
+
+	return []byte(html), nil
+}
+
+// serveFile serves the stored files of the archived web pages, such as the
+// documents, assets and versions, while others within the store root directory
+// such as the index, journals or cookies are never exposed.
+func (h *Handler) serveFile(w http.ResponseWriter, r *http.Request) {
+	archive, err := h.loadArchive()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	relPath := strings.TrimPrefix(path.Clean(r.URL.Path), filesPathPrefix)
+	if !isArchivedFile(relPath, archive) {
+		http.NotFound(w, r)
+		return
+	}
+
+	h.fileServer.ServeHTTP(w, r)
+}
+
+// isArchivedFile checks whether the file path relative to the store root
+// directory is a document file of any archived web page, or within its asset or
+// versions directory. Hidden files are never archived.
+func isArchivedFile(relPath string, archive map[string]*archivedDoc) bool {
+	segments := strings.Split(relPath, "/")
+	for _, segment := range segments {
+		if len(segment) == 0 || strings.HasPrefix(segment, ".") {
+			return false
+		}
+	}
+
+	docNames := make(map[string]bool, len(archive))
+	for _, doc := range archive {
+		docNames[doc.fs.DocName()] = true
+	}
+
+	name := segments[0]
+	if len(segments) > 1 {
+		return docNames[name] || docNames[strings.TrimSuffix(name, ".versions")]
+	}
+
+	for _, ext := range []string{".html", ".md", ".txt"} {
+		if docNames[strings.TrimSuffix(name, ext)] {
+			return true
+		}
+	}
+
+	i := strings.LastIndex(name, ".content")
+	return i > 0 && docNames[name[:i]]
+}
+
+// parseWebPath parses the timestamp and archived URL from the request path in
+// the format of `/web/<timestamp>/<url>`.
+func parseWebPath(r *http.Request) (timestamp, pageURL string, ok bool) {
+	rest := strings.TrimPrefix(r.URL.Path, webPathPrefix)
+
+	timestamp, pageURL, ok = strings.Cut(rest, "/")
+	if !ok || len(timestamp) == 0 || len(pageURL) == 0 {
+		return "", "", false
+	}
+
+	// Pad partial timestamps such as `2023` to 14 digits.
+	if len(timestamp) < len(timestampLayout) {
+		timestamp += strings.Repeat("9", len(timestampLayout)-len(timestamp))
+	}
+
+	// Restore the `//` after the scheme which may be merged by proxies or browsers.
+	for _, scheme := range []string{"http:/", "https:/"} {
+		if strings.HasPrefix(pageURL, scheme) && !strings.HasPrefix(pageURL, scheme+"/") {
+			pageURL = scheme + "/" + strings.TrimPrefix(pageURL, scheme)
+		}
+	}
+
+	if len(r.URL.RawQuery) > 0 {
+		pageURL += "?" + r.URL.RawQuery
+	}
+
+	return timestamp, pageURL, true
+}
+
+// normalizeURL canonicalizes the URL as the fetcher does by default.
+func normalizeURL(rawURL string) string {
+	policy := fetcher.DefaultCanonicalPolicy()
+	canonURL, err := policy.Canonicalize(rawURL)
+	if err != nil {
+		return rawURL
+	}
+
+	return canonURL
+}
+
+// NewServer creates an HTTP server of the replay handler on the address.
+func NewServer(addr, rootDir string) *http.Server {
+	return &http.Server{
+		Addr:              addr,
+		Handler:           NewHandler(rootDir),
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+}
@@ -0,0 +1,126 @@
+package replay_test
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/wanliqun/web-fetcher/replay"
+	"github.com/wanliqun/web-fetcher/store"
+	"github.com/wanliqun/web-fetcher/types"
+)
+
+func TestHandler(t *testing.T) {
+	rootDir := t.TempDir()
+
+	pages := map[string]string{
+		"http://example.com/a.html": `<html><body><a href="b.html?utm_source=x#top">B</a>` +
+			`<a href="https://other.com/">Other</a><img src="file://` + rootDir + `/a/logo.png"></body></html>`,
+		"http://example.com/b.html": `<html><body><a href="/a.html">A</a></body></html>`,
+	}
+
+	for pageURL, html := range pages {
+		fs, err := store.NewFileStore(rootDir, pageURL)
+		assert.NoError(t, err)
+
+		assert.NoError(t, fs.SaveMetadata(&types.Metadata{URL: pageURL, FetchedAt: time.Now()}))
+		assert.NoError(t, os.WriteFile(fs.HtmlDocPath(), []byte(html), 0644))
+	}
+
+	server := httptest.NewServer(replay.NewHandler(rootDir))
+	defer server.Close()
+
+	get := func(path string) (int, string) {
+		resp, err := http.Get(server.URL + path)
+		assert.NoError(t, err)
+		defer resp.Body.Close()
+
+		body, err := io.ReadAll(resp.Body)
+		assert.NoError(t, err)
+		return resp.StatusCode, string(body)
+	}
+
+	status, body := get("/")
+	assert.Equal(t, http.StatusOK, status)
+	assert.Contains(t, body, "http://example.com/a.html")
+	assert.Contains(t, body, "http://example.com/b.html")
+
+	status, body = get("/web/20230101000000/http://example.com/a.html")
+	assert.Equal(t, http.StatusOK, status)
+	assert.Regexp(t, `href="/web/\d{14}/http://example.com/b.html#top"`, body)
+	assert.Contains(t, body, `href="https://other.com/"`)
+	assert.Contains(t, body, `src="/files/a/logo.png"`)
+
+	status, _ = get("/web/2023/http://example.com/missing.html")
+	assert.Equal(t, http.StatusNotFound, status)
+
+	// Only the files of the archived web pages are served.
+	fs, err := store.NewFileStore(rootDir, "http://example.com/a.html")
+	assert.NoError(t, err)
+	assert.NoError(t, os.MkdirAll(filepath.Join(rootDir, fs.DocName()), 0755))
+	assert.NoError(t, os.WriteFile(filepath.Join(rootDir, fs.DocName(), "logo.png"), []byte("png"), 0644))
+	for _, name := range []string{"index.jsonl", "cookies.txt", ".journal"} {
+		assert.NoError(t, os.WriteFile(filepath.Join(rootDir, name), []byte("secret"), 0644))
+	}
+
+	status, body = get("/files/" + fs.DocName() + "/logo.png")
+	assert.Equal(t, http.StatusOK, status)
+	assert.Equal(t, "png", body)
+
+	status, _ = get("/files/" + fs.DocName() + ".html")
+	assert.Equal(t, http.StatusOK, status)
+
+	for _, path := range []string{"", "index.jsonl", "cookies.txt", ".journal", fs.DocName() + ".json"} {
+		status, _ = get("/files/" + path)
+		assert.Equal(t, http.StatusNotFound, status, path)
+	}
+
+	// Web pages fetched later are replayed as well.
+	fs, err = store.NewFileStore(rootDir, "http://example.com/missing.html")
+	assert.NoError(t, err)
+	assert.NoError(t, fs.SaveMetadata(&types.Metadata{URL: "http://example.com/missing.html", FetchedAt: time.Now()}))
+	assert.NoError(t, os.WriteFile(fs.HtmlDocPath(), []byte(`<html><body>found</body></html>`), 0644))
+
+	status, body = get("/web/2023/http://example.com/missing.html")
+	assert.Equal(t, http.StatusOK, status)
+	assert.Contains(t, body, "found")
+	// Versions archived later are listed as well.
+	assert.NoError(t, fs.ArchiveVersion(&types.Metadata{
+		URL: "http://example.com/missing.html", FetchedAt: time.Now(), Version: "20230102030405",
+	}))
+
+	status, body = get("/")
+	assert.Equal(t, http.StatusOK, status)
+	assert.Contains(t, body, `href="/web/20230102030405/http://example.com/missing.html"`)
+}
+
+func TestHandlerNonHTML(t *testing.T) {
+	rootDir := t.TempDir()
+
+	pageURL := "http://example.com/doc.pdf"
+	fs, err := store.NewFileStore(rootDir, pageURL)
+	assert.NoError(t, err)
+
+	assert.NoError(t, fs.SaveMetadata(&types.Metadata{
+		URL: pageURL, ContentType: "application/pdf", FetchedAt: time.Now(),
+	}))
+	assert.NoError(t, fs.SaveContent(".pdf", []byte("%PDF-1.7\n")))
+
+	server := httptest.NewServer(replay.NewHandler(rootDir))
+	defer server.Close()
+
+	resp, err := http.Get(server.URL + "/web/2023/" + pageURL)
+	assert.NoError(t, err)
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, "application/pdf", resp.Header.Get("Content-Type"))
+	assert.Equal(t, "%PDF-1.7\n", string(body))
+}
@@ -0,0 +1,121 @@
+package rpc
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/wanliqun/web-fetcher/rpc/pb"
+	"github.com/wanliqun/web-fetcher/types"
+	"google.golang.org/protobuf/types/known/durationpb"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// toProtoFetchResult converts the fetch record to the proto fetch result.
+func toProtoFetchResult(record *types.FetchRecord) *pb.FetchResult {
+	if record == nil {
+		return nil
+	}
+
+	return &pb.FetchResult{
+		Url:         record.URL,
+		Tags:        record.Tags,
+		FinalUrl:    record.FinalURL,
+		Status:      int32(record.Status),
+		Headers:     record.Headers,
+		Metadata:    toProtoMetadata(record.Metadata),
+		Files:       toProtoStoredFiles(record.Files),
+		StartedAt:   toProtoTimestamp(record.StartedAt),
+		FinishedAt:  toProtoTimestamp(record.FinishedAt),
+		Error:       record.Error,
+		Timings:     toProtoTimings(record.Timings),
+		DuplicateOf: record.DuplicateOf,
+		SkippedBy:   record.SkippedBy,
+	}
+}
+
+// toProtoMetadata converts the metadata to the proto metadata.
+func toProtoMetadata(metadata *types.Metadata) *pb.Metadata {
+	if metadata == nil {
+		return nil
+	}
+
+	pbMetadata := &pb.Metadata{
+		Url:              metadata.URL,
+		NumLinks:         int32(metadata.NumLinks),
+		NumImages:        int32(metadata.NumImages),
+		NumWords:         int32(metadata.NumWords),
+		ContentHash:      metadata.ContentHash,
+		ContentChanged:   metadata.ContentChanged,
+		SelectorHashes:   metadata.SelectorHashes,
+		ChangedSelectors: metadata.ChangedSelectors,
+		Version:          metadata.Version,
+		FetchedAt:        toProtoTimestamp(metadata.FetchedAt),
+		ContentType:      metadata.ContentType,
+		Annotations:      metadata.Annotations,
+	}
+
+	if metadata.LastFetchedAt != nil {
+		pbMetadata.LastFetchedAt = toProtoTimestamp(*metadata.LastFetchedAt)
+	}
+
+	if len(metadata.Fields) > 0 {
+		pbMetadata.Fields = make(map[string]*pb.FieldValues, len(metadata.Fields))
+		for name, value := range metadata.Fields {
+			switch v := value.(type) {
+			case string:
+				pbMetadata.Fields[name] = &pb.FieldValues{Values: []string{v}}
+			case []string:
+				pbMetadata.Fields[name] = &pb.FieldValues{Values: v}
+			case []any: // Decoded from the metadata file.
+				values := make([]string, 0, len(v))
+				for _, val := range v {
+					values = append(values, fmt.Sprint(val))
+				}
+				pbMetadata.Fields[name] = &pb.FieldValues{Values: values}
+			}
+		}
+	}
+
+	return pbMetadata
+}
+
+func toProtoStoredFiles(files *types.StoredFiles) *pb.StoredFiles {
+	if files == nil {
+		return nil
+	}
+
+	return &pb.StoredFiles{
+		Html:     files.HTML,
+		Metadata: files.Metadata,
+		Markdown: files.Markdown,
+		Text:     files.Text,
+		Snapshot: files.Snapshot,
+		Assets:   files.Assets,
+		Content:  files.Content,
+	}
+}
+
+func toProtoTimings(timings *types.Timings) *pb.Timings {
+	if timings == nil {
+		return nil
+	}
+
+	return &pb.Timings{
+		Dns:      durationpb.New(timings.DNS),
+		Connect:  durationpb.New(timings.Connect),
+		Tls:      durationpb.New(timings.TLS),
+		Ttfb:     durationpb.New(timings.TTFB),
+		Download: durationpb.New(timings.Download),
+		Parse:    durationpb.New(timings.Parse),
+		Assets:   durationpb.New(timings.Assets),
+		Store:    durationpb.New(timings.Store),
+	}
+}
+
+func toProtoTimestamp(t time.Time) *timestamppb.Timestamp {
+	if t.IsZero() {
+		return nil
+	}
+
+	return timestamppb.New(t)
+}
@@ -0,0 +1,211 @@
+// Code generated by protoc-gen-go-grpc. DO NOT EDIT.
+// versions:
+// - protoc-gen-go-grpc v1.3.0
+// - protoc             (unknown)
+// source: fetcher.proto
+
+package pb
+
+import (
+	context "context"
+	grpc "google.golang.org/grpc"
+	codes "google.golang.org/grpc/codes"
+	status "google.golang.org/grpc/status"
+)
+
+// This is a compile-time assertion to ensure that this generated file
+// is compatible with the grpc package it is being compiled against.
+// Requires gRPC-Go v1.32.0 or later.
+const _ = grpc.SupportPackageIsVersion7
+
+const (
+	FetcherService_Fetch_FullMethodName       = "/webfetcher.v1.FetcherService/Fetch"
+	FetcherService_FetchBatch_FullMethodName  = "/webfetcher.v1.FetcherService/FetchBatch"
+	FetcherService_GetMetadata_FullMethodName = "/webfetcher.v1.FetcherService/GetMetadata"
+)
+
+// FetcherServiceClient is the client API for FetcherService service.
+//
+// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
+type FetcherServiceClient interface {
+	Fetch(ctx context.Context, in *FetchRequest, opts ...grpc.CallOption) (*FetchResult, error)
+	FetchBatch(ctx context.Context, in *FetchBatchRequest, opts ...grpc.CallOption) (FetcherService_FetchBatchClient, error)
+	GetMetadata(ctx context.Context, in *GetMetadataRequest, opts ...grpc.CallOption) (*Metadata, error)
+}
+
+type fetcherServiceClient struct {
+	cc grpc.ClientConnInterface
+}
+
+func NewFetcherServiceClient(cc grpc.ClientConnInterface) FetcherServiceClient {
+	return &fetcherServiceClient{cc}
+}
+
+func (c *fetcherServiceClient) Fetch(ctx context.Context, in *FetchRequest, opts ...grpc.CallOption) (*FetchResult, error) {
+	out := new(FetchResult)
+	err := c.cc.Invoke(ctx, FetcherService_Fetch_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *fetcherServiceClient) FetchBatch(ctx context.Context, in *FetchBatchRequest, opts ...grpc.CallOption) (FetcherService_FetchBatchClient, error) {
+	stream, err := c.cc.NewStream(ctx, &FetcherService_ServiceDesc.Streams[0], FetcherService_FetchBatch_FullMethodName, opts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &fetcherServiceFetchBatchClient{stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+type FetcherService_FetchBatchClient interface {
+	Recv() (*FetchResult, error)
+	grpc.ClientStream
+}
+
+type fetcherServiceFetchBatchClient struct {
+	grpc.ClientStream
+}
+
+func (x *fetcherServiceFetchBatchClient) Recv() (*FetchResult, error) {
+	m := new(FetchResult)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func (c *fetcherServiceClient) GetMetadata(ctx context.Context, in *GetMetadataRequest, opts ...grpc.CallOption) (*Metadata, error) {
+	out := new(Metadata)
+	err := c.cc.Invoke(ctx, FetcherService_GetMetadata_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// FetcherServiceServer is the server API for FetcherService service.
+// All implementations must embed UnimplementedFetcherServiceServer
+// for forward compatibility
+type FetcherServiceServer interface {
+	Fetch(context.Context, *FetchRequest) (*FetchResult, error)
+	FetchBatch(*FetchBatchRequest, FetcherService_FetchBatchServer) error
+	GetMetadata(context.Context, *GetMetadataRequest) (*Metadata, error)
+	mustEmbedUnimplementedFetcherServiceServer()
+}
+
+// UnimplementedFetcherServiceServer must be embedded to have forward compatible implementations.
+type UnimplementedFetcherServiceServer struct {
+}
+
+func (UnimplementedFetcherServiceServer) Fetch(context.Context, *FetchRequest) (*FetchResult, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Fetch not implemented")
+}
+func (UnimplementedFetcherServiceServer) FetchBatch(*FetchBatchRequest, FetcherService_FetchBatchServer) error {
+	return status.Errorf(codes.Unimplemented, "method FetchBatch not implemented")
+}
+func (UnimplementedFetcherServiceServer) GetMetadata(context.Context, *GetMetadataRequest) (*Metadata, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetMetadata not implemented")
+}
+func (UnimplementedFetcherServiceServer) mustEmbedUnimplementedFetcherServiceServer() {}
+
+// UnsafeFetcherServiceServer may be embedded to opt out of forward compatibility for this service.
+// Use of this interface is not recommended, as added methods to FetcherServiceServer will
+// result in compilation errors.
+type UnsafeFetcherServiceServer interface {
+	mustEmbedUnimplementedFetcherServiceServer()
+}
+
+func RegisterFetcherServiceServer(s grpc.ServiceRegistrar, srv FetcherServiceServer) {
+	s.RegisterService(&FetcherService_ServiceDesc, srv)
+}
+
+func _FetcherService_Fetch_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(FetchRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(FetcherServiceServer).Fetch(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: FetcherService_Fetch_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(FetcherServiceServer).Fetch(ctx, req.(*FetchRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _FetcherService_FetchBatch_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(FetchBatchRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(FetcherServiceServer).FetchBatch(m, &fetcherServiceFetchBatchServer{stream})
+}
+
+type FetcherService_FetchBatchServer interface {
+	Send(*FetchResult) error
+	grpc.ServerStream
+}
+
+type fetcherServiceFetchBatchServer struct {
+	grpc.ServerStream
+}
+
+func (x *fetcherServiceFetchBatchServer) Send(m *FetchResult) error {
+	return x.ServerStream.SendMsg(m)
+}
+
+func _FetcherService_GetMetadata_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetMetadataRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(FetcherServiceServer).GetMetadata(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: FetcherService_GetMetadata_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(FetcherServiceServer).GetMetadata(ctx, req.(*GetMetadataRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+// FetcherService_ServiceDesc is the grpc.ServiceDesc for FetcherService service.
+// It's only intended for direct use with grpc.RegisterService,
+// and not to be introspected or modified (even as a copy)
+var FetcherService_ServiceDesc = grpc.ServiceDesc{
+	ServiceName: "webfetcher.v1.FetcherService",
+	HandlerType: (*FetcherServiceServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "Fetch",
+			Handler:    _FetcherService_Fetch_Handler,
+		},
+		{
+			MethodName: "GetMetadata",
+			Handler:    _FetcherService_GetMetadata_Handler,
+		},
+	},
+	Streams: []grpc.StreamDesc{
+		{
+			StreamName:    "FetchBatch",
+			Handler:       _FetcherService_FetchBatch_Handler,
+			ServerStreams: true,
+		},
+	},
+	Metadata: "fetcher.proto",
+}
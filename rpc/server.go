@@ -0,0 +1,164 @@
+package rpc
+
+import (
+	"context"
+	"strings"
+
+	"github.com/pkg/errors"
+	"github.com/wanliqun/web-fetcher/fetcher"
+	"github.com/wanliqun/web-fetcher/rpc/pb"
+	"github.com/wanliqun/web-fetcher/types"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+//go:generate protoc -I pb --go_out=pb --go_opt=paths=source_relative --go-grpc_out=pb --go-grpc_opt=paths=source_relative fetcher.proto
+
+// Server implements the gRPC fetcher service by wrapping the fetcher.
+type Server struct {
+	pb.UnimplementedFetcherServiceServer
+
+	// The options to create fetchers for the calls.
+	options []fetcher.FetcherOption
+	// The HTTP client shared by the fetchers of all calls, so that the politeness
+	// limits apply to the server as a whole.
+	client *fetcher.ThrottleClient
+}
+
+// NewServer creates a gRPC fetcher service with the fetcher options.
+func NewServer(options ...fetcher.FetcherOption) *Server {
+	return &Server{options: options, client: fetcher.NewFetcher(options...).Client()}
+}
+
+// Register registers the fetcher service to the gRPC server.
+func (s *Server) Register(server *grpc.Server) {
+	pb.RegisterFetcherServiceServer(server, s)
+}
+
+// Fetch fetches a web page and returns the fetch result once done. Fetch errors
+// are reported within the fetch result rather than as the call status.
+func (s *Server) Fetch(ctx context.Context, req *pb.FetchRequest) (*pb.FetchResult, error) {
+	fetchReq, err := toFetchRequest(req)
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+
+	var record *types.FetchRecord
+	f := s.newFetcher(ctx, false)
+	f.OnFetched(func(result *types.FetchResult) {
+		// Skip the results of the followed links.
+		if result.Request == fetchReq {
+			record = types.NewFetchRecord(result)
+		}
+	})
+
+	f.FetchRequest(fetchReq)
+
+	if err := ctx.Err(); err != nil {
+		return nil, status.FromContextError(err).Err()
+	}
+
+	return toProtoFetchResult(record), nil
+}
+
+// FetchBatch fetches web pages concurrently and streams the fetch results as
+// they complete, including those of the followed links.
+func (s *Server) FetchBatch(req *pb.FetchBatchRequest, stream pb.FetcherService_FetchBatchServer) error {
+	if len(req.Requests) == 0 {
+		return status.Error(codes.InvalidArgument, "no URL to fetch")
+	}
+
+	var fetchReqs []*types.FetchRequest
+	for _, r := range req.Requests {
+		fetchReq, err := toFetchRequest(r)
+		if err != nil {
+			return status.Error(codes.InvalidArgument, err.Error())
+		}
+		fetchReqs = append(fetchReqs, fetchReq)
+	}
+
+	ctx := stream.Context()
+	records := make(chan *types.FetchRecord)
+
+	f := s.newFetcher(ctx, true)
+	f.OnFetched(func(result *types.FetchResult) {
+		select {
+		case records <- types.NewFetchRecord(result):
+		case <-ctx.Done():
+		}
+	})
+
+	// No more web pages are submitted once the call is cancelled.
+	for _, fetchReq := range fetchReqs {
+		if err := f.FetchRequest(fetchReq); err != nil {
+			break
+		}
+	}
+
+	go func() {
+		f.Wait()
+		close(records)
+	}()
+
+	for {
+		select {
+		case record, ok := <-records:
+			if !ok {
+				return nil
+			}
+
+			if err := stream.Send(toProtoFetchResult(record)); err != nil {
+				return err
+			}
+		case <-ctx.Done():
+			return status.FromContextError(ctx.Err()).Err()
+		}
+	}
+}
+
+// GetMetadata returns the stored metadata of a fetched web page.
+func (s *Server) GetMetadata(ctx context.Context, req *pb.GetMetadataRequest) (*pb.Metadata, error) {
+	if len(req.Url) == 0 {
+		return nil, status.Error(codes.InvalidArgument, "missing URL")
+	}
+
+	fs, err := s.newFetcher(ctx, false).OpenFileStore(req.Url)
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+
+	metadata, err := fs.LoadMetadata()
+	if err != nil {
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+
+	if metadata == nil {
+		return nil, status.Error(codes.NotFound, "metadata not found")
+	}
+
+	return toProtoMetadata(metadata), nil
+}
+
+// newFetcher creates a fetcher for the call, which is stopped once the call is
+// cancelled.
+func (s *Server) newFetcher(ctx context.Context, async bool) *fetcher.Fetcher {
+	options := append([]fetcher.FetcherOption{}, s.options...)
+	options = append(options, fetcher.HTTPClient(s.client), fetcher.Context(ctx), fetcher.Async(async))
+	return fetcher.NewFetcher(options...)
+}
+
+// toFetchRequest validates and converts the proto fetch request.
+func toFetchRequest(req *pb.FetchRequest) (*types.FetchRequest, error) {
+	if !strings.HasPrefix(req.Url, "http://") && !strings.HasPrefix(req.Url, "https://") {
+		return nil, errors.Errorf("invalid web URL %v", req.Url)
+	}
+
+	return &types.FetchRequest{
+		URL:     req.Url,
+		Headers: req.Headers,
+		Mirror:  req.Mirror,
+		Depth:   int(req.Depth),
+		Tags:    req.Tags,
+	}, nil
+}
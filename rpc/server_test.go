@@ -0,0 +1,205 @@
+package rpc_test
+
+import (
+	"context"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/wanliqun/web-fetcher/fetcher"
+	"github.com/wanliqun/web-fetcher/rpc"
+	"github.com/wanliqun/web-fetcher/rpc/pb"
+	"github.com/wanliqun/web-fetcher/types"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+)
+
+func TestServer(t *testing.T) {
+	site := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/missing" {
+			http.NotFound(w, r)
+			return
+		}
+
+		w.Header().Set("Content-Type", "text/html")
+		w.Write([]byte(`<html><body><a href="/a">A</a><img src="/b.png"></body></html>`))
+	}))
+	defer site.Close()
+
+	client := newTestClient(t, fetcher.RootDir(t.TempDir()))
+	ctx := context.Background()
+
+	_, err := client.Fetch(ctx, &pb.FetchRequest{Url: "ftp://example.com"})
+	assert.Equal(t, codes.InvalidArgument, status.Code(err))
+
+	result, err := client.Fetch(ctx, &pb.FetchRequest{Url: site.URL + "/page", Tags: []string{"grpc"}})
+	assert.NoError(t, err)
+	assert.Empty(t, result.Error)
+	assert.Equal(t, []string{"grpc"}, result.Tags)
+	assert.EqualValues(t, 1, result.Metadata.NumLinks)
+	if assert.NotNil(t, result.Timings) {
+		assert.Positive(t, result.Timings.Ttfb.AsDuration())
+	}
+
+	metadata, err := client.GetMetadata(ctx, &pb.GetMetadataRequest{Url: site.URL + "/page"})
+	assert.NoError(t, err)
+	assert.EqualValues(t, 1, metadata.NumImages)
+
+	_, err = client.GetMetadata(ctx, &pb.GetMetadataRequest{Url: site.URL + "/unknown"})
+	assert.Equal(t, codes.NotFound, status.Code(err))
+
+	stream, err := client.FetchBatch(ctx, &pb.FetchBatchRequest{
+		Requests: []*pb.FetchRequest{{Url: site.URL + "/page"}, {Url: site.URL + "/missing"}},
+	})
+	assert.NoError(t, err)
+
+	errs := make(map[string]string)
+	for {
+		result, err := stream.Recv()
+		if err == io.EOF {
+			break
+		}
+		assert.NoError(t, err)
+		errs[result.Url] = result.Error
+	}
+
+	assert.Len(t, errs, 2)
+	assert.Empty(t, errs[site.URL+"/page"])
+	assert.Contains(t, errs[site.URL+"/missing"], "404")
+}
+
+func TestServerSkipped(t *testing.T) {
+	site := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html")
+		w.Write([]byte(`<html><body>page</body></html>`))
+	}))
+	defer site.Close()
+
+	client := newTestClient(t, fetcher.RootDir(t.TempDir()), fetcher.Use(&fetcher.Middleware{
+		Name: "veto",
+		AfterResponse: func(ctx context.Context, resp *http.Response, result *types.FetchResult) error {
+			return fetcher.ErrSkip
+		},
+	}))
+
+	result, err := client.Fetch(context.Background(), &pb.FetchRequest{Url: site.URL})
+	assert.NoError(t, err)
+	assert.Empty(t, result.Error)
+	assert.Equal(t, "veto", result.SkippedBy)
+}
+
+func TestServerCancellation(t *testing.T) {
+	var numRequests atomic.Int32
+	aborted := make(chan string, 10)
+
+	site := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		numRequests.Add(1)
+
+		select {
+		case <-r.Context().Done():
+			aborted <- r.URL.Path
+		case <-time.After(5 * time.Second):
+		}
+	}))
+	defer site.Close()
+
+	client := newTestClient(t, fetcher.RootDir(t.TempDir()), fetcher.Parallelism(1))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+
+	// The HTTP request in flight is aborted once the call is cancelled.
+	_, err := client.Fetch(ctx, &pb.FetchRequest{Url: site.URL + "/slow"})
+	assert.Equal(t, codes.DeadlineExceeded, status.Code(err))
+	assert.Equal(t, "/slow", waitAborted(t, aborted))
+
+	ctx, cancel = context.WithCancel(context.Background())
+	stream, err := client.FetchBatch(ctx, &pb.FetchBatchRequest{
+		Requests: []*pb.FetchRequest{
+			{Url: site.URL + "/1"}, {Url: site.URL + "/2"}, {Url: site.URL + "/3"},
+		},
+	})
+	assert.NoError(t, err)
+
+	time.AfterFunc(100*time.Millisecond, cancel)
+	_, err = stream.Recv()
+	assert.Equal(t, codes.Canceled, status.Code(err))
+
+	// Only one web page of the batch is requested due to the parallelism, and
+	// the others are never requested after cancellation.
+	assert.Contains(t, []string{"/1", "/2", "/3"}, waitAborted(t, aborted))
+	time.Sleep(100 * time.Millisecond)
+	assert.EqualValues(t, 2, numRequests.Load())
+}
+
+// waitAborted waits for the HTTP request to be aborted, and returns the path.
+func TestServerParallelism(t *testing.T) {
+	var numInFlight, maxInFlight atomic.Int32
+	site := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n := numInFlight.Add(1)
+		defer numInFlight.Add(-1)
+		for m := maxInFlight.Load(); n > m && !maxInFlight.CompareAndSwap(m, n); m = maxInFlight.Load() {
+		}
+
+		time.Sleep(20 * time.Millisecond)
+		w.Header().Set("Content-Type", "text/html")
+		w.Write([]byte(`<html><body>page</body></html>`))
+	}))
+	defer site.Close()
+
+	client := newTestClient(t, fetcher.RootDir(t.TempDir()), fetcher.Parallelism(1))
+
+	// The parallelism limits the server as a whole rather than each call.
+	done := make(chan error)
+	for _, path := range []string{"/a", "/b", "/c"} {
+		go func(path string) {
+			_, err := client.Fetch(context.Background(), &pb.FetchRequest{Url: site.URL + path})
+			done <- err
+		}(path)
+	}
+	for i := 0; i < 3; i++ {
+		assert.NoError(t, <-done)
+	}
+
+	assert.EqualValues(t, 1, maxInFlight.Load())
+}
+
+func waitAborted(t *testing.T, aborted <-chan string) string {
+	select {
+	case path := <-aborted:
+		return path
+	case <-time.After(time.Second):
+		assert.Fail(t, "HTTP request not aborted")
+		return ""
+	}
+}
+
+// newTestClient serves the fetcher service over an in-memory listener, and
+// returns the client connected to it.
+func newTestClient(t *testing.T, options ...fetcher.FetcherOption) pb.FetcherServiceClient {
+	listener := bufconn.Listen(1 << 20)
+	grpcServer := grpc.NewServer()
+	rpc.NewServer(options...).Register(grpcServer)
+
+	go grpcServer.Serve(listener)
+	t.Cleanup(grpcServer.Stop)
+
+	conn, err := grpc.Dial("bufnet",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) {
+			return listener.DialContext(ctx)
+		}),
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+	)
+	assert.NoError(t, err)
+	t.Cleanup(func() { conn.Close() })
+
+	return pb.NewFetcherServiceClient(conn)
+}
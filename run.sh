@@ -2,11 +2,12 @@
 
 # check if there are any arguments
 if [ $# -eq 0 ]; then
-  echo "Usage: ./run.sh [--build | --metadata | --mirror] [urls]"
+  echo "Usage: ./run.sh [--build | --metadata | --mirror | --readable] [urls]"
   echo "Example: ./run.sh --build --metadata --mirror https://www.google.com https://www.autify.com"
   echo "The --build flag (optional) builds the Docker image (first time only)"
   echo "The --metadata flag (optional) enables printing metadata about fetched web page"
   echo "The --mirror flag (optional) enables downloading linked assets, such as images, stylesheets, and scripts."
+  echo "The --readable flag (optional) enables extracting readable main content as Markdown and plain text."
   exit 1
 fi
 
@@ -14,6 +15,7 @@ fi
 args=()
 metaArg=""
 mirrorArg=""
+readableArg=""
 buildDockerImage=false
 
 for arg in "$@"; do
@@ -21,6 +23,8 @@ for arg in "$@"; do
     metaArg="$arg"
   elif [[ $arg == "--mirror" || $arg == "-m" ]]; then
     mirrorArg="$arg"
+  elif [[ $arg == "--readable" || $arg == "-r" ]]; then
+    readableArg="$arg"
   elif [[ $arg == "--build" ]]; then
     buildDockerImage=true
   else
@@ -49,4 +53,4 @@ if [ -z "$args" ]; then
 fi
 
 # Run the Docker container with the specified arguments
-docker run --rm -v ${PWD}:/app/output web-fetcher ${metaArg} ${mirrorArg} "${args[@]}"
\ No newline at end of file
+docker run --rm -v ${PWD}:/app/output web-fetcher ${metaArg} ${mirrorArg} ${readableArg} "${args[@]}"
\ No newline at end of file
@@ -0,0 +1,111 @@
+package store
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"github.com/pkg/errors"
+)
+
+var (
+	// Guards the document locks keyed by the document path prefix.
+	docLocksMu sync.Mutex
+	docLocks   = make(map[string]*docLock)
+)
+
+// docLock is a reference counted lock of a document, which is released from
+// docLocks once no longer referenced.
+type docLock struct {
+	mu   sync.Mutex
+	refs int
+}
+
+// Lock locks the document of the file store, so that concurrent writers of the
+// same document within the process don't interleave. The returned function
+// unlocks the document.
+func (fs *FileStore) Lock() (unlock func()) {
+	key := filepath.Join(fs.rootDir, fs.docName)
+
+	docLocksMu.Lock()
+	lock, ok := docLocks[key]
+	if !ok {
+		lock = &docLock{}
+		docLocks[key] = lock
+	}
+	lock.refs++
+	docLocksMu.Unlock()
+
+	lock.mu.Lock()
+
+	return func() {
+		lock.mu.Unlock()
+
+		docLocksMu.Lock()
+		if lock.refs--; lock.refs == 0 {
+			delete(docLocks, key)
+		}
+		docLocksMu.Unlock()
+	}
+}
+
+// writeFileAtomic writes the file atomically, by writing to a temp file within
+// the same directory, which is synced to disk and renamed to the file path. The
+// temp file is removed on failure, so that there are never half-written files.
+func writeFileAtomic(filePath string, write func(w io.Writer) error) (err error) {
+	dir := filepath.Dir(filePath)
+
+	tmpFile, err := os.CreateTemp(dir, "."+filepath.Base(filePath)+".tmp-*")
+	if err != nil {
+		return errors.WithMessage(err, "failed to create temp file")
+	}
+
+	defer func() {
+		if err != nil {
+			tmpFile.Close()
+			os.Remove(tmpFile.Name())
+		}
+	}()
+
+	if err := write(tmpFile); err != nil {
+		return err
+	}
+
+	if err := tmpFile.Sync(); err != nil {
+		return errors.WithMessage(err, "failed to sync temp file")
+	}
+
+	if err := tmpFile.Close(); err != nil {
+		return errors.WithMessage(err, "failed to close temp file")
+	}
+
+	// Temp files are created with mode 0600.
+	if err := os.Chmod(tmpFile.Name(), 0644); err != nil {
+		return errors.WithMessage(err, "failed to chmod temp file")
+	}
+
+	if err := os.Rename(tmpFile.Name(), filePath); err != nil {
+		return errors.WithMessage(err, "failed to rename temp file")
+	}
+
+	syncDir(dir)
+	return nil
+}
+
+// writeFileBytesAtomic writes the content to the file atomically.
+func writeFileBytesAtomic(filePath string, content []byte) error {
+	return writeFileAtomic(filePath, func(w io.Writer) error {
+		_, err := w.Write(content)
+		return err
+	})
+}
+
+// syncDir syncs the directory so that the renamed entries are durable, which is
+// best effort as not all platforms support it.
+func syncDir(dir string) {
+	if d, err := os.Open(dir); err == nil {
+		d.Sync()
+		d.Close()
+	}
+}
@@ -7,6 +7,9 @@ import (
 	"os"
 	"path"
 	"path/filepath"
+	"sort"
+	"strings"
+	"time"
 
 	"github.com/PuerkitoBio/goquery"
 	"github.com/kennygrant/sanitize"
@@ -36,17 +39,30 @@ type FileStore struct {
 	docName string
 }
 
-func NewFileStore(rootDir, docName string) (*FileStore, error) {
+// ResolveRootDir resolves the root directory, which defaults to the current
+// working directory if empty.
+func ResolveRootDir(rootDir string) string {
 	if len(rootDir) == 0 {
-		rootDir = defaultFileStoreRootDir
+		return defaultFileStoreRootDir
 	}
 
+	return rootDir
+}
+
+func NewFileStore(rootDir, docName string) (*FileStore, error) {
+	rootDir = ResolveRootDir(rootDir)
+
 	return &FileStore{
 		rootDir: rootDir,
 		docName: sanitize.BaseName(docName),
 	}, nil
 }
 
+// DocName returns the document base name of the file store.
+func (fs *FileStore) DocName() string {
+	return fs.docName
+}
+
 // SaveDoc saves HTML document object.
 func (fs *FileStore) SaveDoc(doc *goquery.Document) error {
 	content, err := doc.Html()
@@ -54,7 +70,7 @@ func (fs *FileStore) SaveDoc(doc *goquery.Document) error {
 		return errors.WithMessage(err, "invalid HTML document")
 	}
 
-	return os.WriteFile(fs.HtmlDocPath(), []byte(content), 0644)
+	return writeFileBytesAtomic(fs.HtmlDocPath(), []byte(content))
 }
 
 // Abosulte HTML document file format: `${rootDir}/${docName}.html`.
@@ -69,7 +85,7 @@ func (fs *FileStore) SaveMetadata(metadata *types.Metadata) error {
 		return errors.WithMessage(err, "JSON marshal error")
 	}
 
-	return os.WriteFile(fs.MetadataFilePath(), []byte(content), 0644)
+	return writeFileBytesAtomic(fs.MetadataFilePath(), content)
 }
 
 // LoadMetadata loads metadata from json file.
@@ -96,24 +112,163 @@ func (fs *FileStore) MetadataFilePath() string {
 	return filepath.Join(fs.rootDir, fs.docName+".json")
 }
 
-// SaveAsset saves embedded asset files.
-func (fs *FileStore) SaveAsset(as *types.EmbeddedAsset) error {
-	assetFilePath := fs.AssetFilePath(as)
-	if err := os.MkdirAll(filepath.Dir(assetFilePath), 0755); err != nil {
+// SaveReadableContent saves the readable main content as both Markdown and plain
+// text files.
+func (fs *FileStore) SaveReadableContent(content *types.ReadableContent) error {
+	if err := writeFileBytesAtomic(fs.MarkdownFilePath(), []byte(content.Markdown)); err != nil {
+		return errors.WithMessage(err, "failed to write Markdown file")
+	}
+
+	if err := writeFileBytesAtomic(fs.TextFilePath(), []byte(content.Text)); err != nil {
+		return errors.WithMessage(err, "failed to write text file")
+	}
+
+	return nil
+}
+
+// SaveContent saves the raw content of a non-HTML resource, such as a PDF or
+// JSON file, with the file extension such as `.pdf`.
+func (fs *FileStore) SaveContent(ext string, content []byte) error {
+	return writeFileBytesAtomic(fs.ContentFilePath(ext), content)
+}
+
+// Content file path format: `${rootDir}/${docName}.content${ext}`
+func (fs *FileStore) ContentFilePath(ext string) string {
+	return filepath.Join(fs.rootDir, fs.docName+".content"+ext)
+}
+
+// DocFilePath returns the path of the document file stored for the content type,
+// which is the HTML document for HTML pages or unknown content types, such as
+// stored before the content type was recorded.
+func (fs *FileStore) DocFilePath(contentType string) string {
+	if IsHTMLType(contentType) {
+		return fs.HtmlDocPath()
+	}
+
+	return fs.ContentFilePath(ContentExtension(contentType))
+}
+
+// Markdown file path format: `${rootDir}/${docName}.md`
+func (fs *FileStore) MarkdownFilePath() string {
+	return filepath.Join(fs.rootDir, fs.docName+".md")
+}
+
+// Plain text file path format: `${rootDir}/${docName}.txt`
+func (fs *FileStore) TextFilePath() string {
+	return filepath.Join(fs.rootDir, fs.docName+".txt")
+}
+
+// SnapshotVersion returns the snapshot version of the fetching time, which is
+// a 14-digit UTC timestamp in the format of `YYYYMMDDhhmmss`.
+func SnapshotVersion(t time.Time) string {
+	return t.UTC().Format("20060102150405")
+}
+
+// NewVersion returns the snapshot version of the fetching time, with a suffix
+// such as `-1` appended if the version already exists in the snapshots or the
+// history, so that fetches within the same second never overwrite each other.
+func (fs *FileStore) NewVersion(t time.Time) string {
+	version := SnapshotVersion(t)
+	for i := 1; fs.versionExists(version); i++ {
+		version = fmt.Sprintf("%v-%d", SnapshotVersion(t), i)
+	}
+
+	return version
+}
+
+func (fs *FileStore) versionExists(version string) bool {
+	for _, path := range []string{fs.SnapshotFilePath(version), fs.VersionDir(version)} {
+		if _, err := os.Stat(path); err == nil {
+			return true
+		}
+	}
+
+	return len(fs.contentSnapshotFiles(version)) > 0
+}
+
+// SaveSnapshot saves the raw HTML content as a versioned snapshot.
+func (fs *FileStore) SaveSnapshot(version string, content []byte) error {
+	snapshotFilePath := fs.SnapshotFilePath(version)
+	if err := os.MkdirAll(filepath.Dir(snapshotFilePath), 0755); err != nil {
 		return errors.WithMessage(err, "failed to create directory")
 	}
 
-	file, err := os.Create(assetFilePath)
+	return writeFileBytesAtomic(snapshotFilePath, content)
+}
+
+// SaveContentSnapshot saves the content of a non-HTML resource as a versioned
+// snapshot with the file extension.
+func (fs *FileStore) SaveContentSnapshot(version, ext string, content []byte) error {
+	snapshotFilePath := fs.ContentSnapshotFilePath(version, ext)
+	if err := os.MkdirAll(filepath.Dir(snapshotFilePath), 0755); err != nil {
+		return errors.WithMessage(err, "failed to create directory")
+	}
+
+	return writeFileBytesAtomic(snapshotFilePath, content)
+}
+
+// LoadSnapshot loads the raw HTML content of the versioned snapshot.
+func (fs *FileStore) LoadSnapshot(version string) ([]byte, error) {
+	return os.ReadFile(fs.SnapshotFilePath(version))
+}
+
+// ListSnapshots lists the snapshot versions in ascending order.
+func (fs *FileStore) ListSnapshots() ([]string, error) {
+	entries, err := os.ReadDir(fs.snapshotDir())
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+
 	if err != nil {
-		return errors.WithMessage(err, "failed to create file")
+		return nil, errors.WithMessage(err, "failed to read directory")
 	}
-	defer file.Close()
 
-	if _, err = io.Copy(file, as.DataReader); err != nil {
-		return errors.WithMessage(err, "failed to write file")
+	var versions []string
+	for _, entry := range entries {
+		if name := entry.Name(); !entry.IsDir() && filepath.Ext(name) == ".html" {
+			versions = append(versions, strings.TrimSuffix(name, ".html"))
+		}
 	}
+	sort.Strings(versions)
 
-	return nil
+	return versions, nil
+}
+
+// Snapshot file path format: `${rootDir}/${docName}.versions/${version}.html`
+func (fs *FileStore) SnapshotFilePath(version string) string {
+	return filepath.Join(fs.snapshotDir(), sanitize.BaseName(version)+".html")
+}
+
+// Content snapshot file path format: `${rootDir}/${docName}.versions/${version}.content${ext}`
+func (fs *FileStore) ContentSnapshotFilePath(version, ext string) string {
+	return filepath.Join(fs.snapshotDir(), sanitize.BaseName(version)+".content"+ext)
+}
+
+// contentSnapshotFiles returns the content snapshot files of the version.
+func (fs *FileStore) contentSnapshotFiles(version string) []string {
+	pattern := filepath.Join(fs.snapshotDir(), sanitize.BaseName(version)+".content*")
+	filePaths, _ := filepath.Glob(pattern)
+	return filePaths
+}
+
+func (fs *FileStore) snapshotDir() string {
+	return filepath.Join(fs.rootDir, fs.docName+".versions")
+}
+
+// SaveAsset saves embedded asset files, partially downloaded assets are never
+// left behind on failure.
+func (fs *FileStore) SaveAsset(as *types.EmbeddedAsset) error {
+	assetFilePath := fs.AssetFilePath(as)
+	if err := os.MkdirAll(filepath.Dir(assetFilePath), 0755); err != nil {
+		return errors.WithMessage(err, "failed to create directory")
+	}
+
+	return writeFileAtomic(assetFilePath, func(w io.Writer) error {
+		if _, err := io.Copy(w, as.DataReader); err != nil {
+			return errors.WithMessage(err, "failed to write file")
+		}
+		return nil
+	})
 }
 
 // Absolute asset file path format:
@@ -123,22 +278,20 @@ func (fs *FileStore) AssetFilePath(as *types.EmbeddedAsset) string {
 }
 
 // Relative asset file path format:
-// `${docName}/${assetFilePath}/${assetFileName}`.
+// `${docName}/${assetFilePath}/${assetFileName}`, where the asset file path
+// never escapes the document directory, and the asset file name is suffixed by
+// the short hash of the query if any, with the extension inferred from the
+// content type if missing.
 func (fs *FileStore) RelativeAssetFilePath(as *types.EmbeddedAsset) string {
-	paths := []string{fs.docName}
-
 	dir, file := path.Split(as.AbsURL.Path)
-	if len(dir) > 0 {
-		paths = append(paths, dir)
-	}
 
-	if len(as.AbsURL.RawQuery) > 0 {
-		file = fmt.Sprintf("%v_%v", as.AbsURL.RawQuery, file)
-	}
+	paths := append([]string{fs.docName}, sanitizeAssetDir(dir)...)
 
-	if len(file) > 0 {
-		paths = append(paths, sanitize.Name(file))
+	file = sanitizeAssetFileName(file, as.ContentType)
+	if len(as.AbsURL.RawQuery) > 0 {
+		ext := path.Ext(file)
+		file = DocName(strings.TrimSuffix(file, ext), as.AbsURL.RawQuery) + ext
 	}
 
-	return filepath.Join(paths...)
+	return filepath.Join(append(paths, file)...)
 }
@@ -0,0 +1,124 @@
+package store_test
+
+import (
+	"errors"
+	"io"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/wanliqun/web-fetcher/store"
+	"github.com/wanliqun/web-fetcher/types"
+)
+
+func TestDocName(t *testing.T) {
+	// Distinct keys never share the same name, even if sanitized the same.
+	assert.NotEqual(t, store.DocName("a/b", "http://x/a/b"), store.DocName("a-b", "http://x/a-b"))
+	assert.Equal(t, store.DocName("a/b", "http://x/a/b"), store.DocName("a/b", "http://x/a/b"))
+	assert.True(t, strings.HasPrefix(store.DocName("example.com/a", "key"), "example-com-a-"))
+
+	longName := store.DocName(strings.Repeat("é", 300), "key")
+	assert.LessOrEqual(t, len(longName+".versions"), 255)
+}
+
+func TestRelativeAssetFilePath(t *testing.T) {
+	fs, err := store.NewFileStore(t.TempDir(), "page")
+	assert.NoError(t, err)
+
+	testCases := []struct {
+		url, contentType, expected string
+	}{
+		{"http://x/img/a.png", "", "page/img/a.png"},
+		{"http://x/img/%2e%2e/%2e%2e/%2e%2e/etc/passwd", "", "page/img/etc/passwd"},
+		{"http://x/avatar", "image/jpeg; charset=binary", "page/avatar.jpg"},
+		{"http://x/css/", "text/css", "page/css/index.css"},
+	}
+
+	for _, tc := range testCases {
+		assetURL, err := url.Parse(tc.url)
+		assert.NoError(t, err)
+
+		as := &types.EmbeddedAsset{AbsURL: assetURL, ContentType: tc.contentType}
+		assert.Equal(t, filepath.FromSlash(tc.expected), fs.RelativeAssetFilePath(as), tc.url)
+	}
+
+	// Assets of distinct queries are named apart.
+	v1, _ := url.Parse("http://x/app.js?v=1")
+	v2, _ := url.Parse("http://x/app.js?v=2")
+	path1 := fs.RelativeAssetFilePath(&types.EmbeddedAsset{AbsURL: v1})
+	path2 := fs.RelativeAssetFilePath(&types.EmbeddedAsset{AbsURL: v2})
+	assert.NotEqual(t, path1, path2)
+	assert.Equal(t, ".js", filepath.Ext(path1))
+}
+
+func TestIndexURL(t *testing.T) {
+	rootDir := t.TempDir()
+
+	fs, err := store.NewFileStore(rootDir, store.DocName("example.com/a", "http://example.com/a"))
+	assert.NoError(t, err)
+
+	assert.NoError(t, fs.IndexURL("http://example.com/a", fs.HtmlDocPath()))
+	assert.NoError(t, fs.IndexURL("http://example.com/a", fs.HtmlDocPath()))
+	assert.NoError(t, fs.IndexURL("http://example.com/a.pdf", fs.DocFilePath("application/pdf")))
+	assert.Error(t, fs.IndexURL("http://example.com/b", "/elsewhere/b.html"))
+
+	index, err := store.LoadIndex(rootDir)
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]string{
+		"http://example.com/a":     fs.DocName() + ".html",
+		"http://example.com/a.pdf": fs.DocName() + ".content.pdf",
+	}, index)
+}
+
+func TestIndexURLAfterTornLine(t *testing.T) {
+	rootDir := t.TempDir()
+
+	// Simulate a half written entry due to crash.
+	indexPath := store.IndexFilePath(rootDir)
+	assert.NoError(t, os.WriteFile(indexPath, []byte(`{"URL":"http://example.com/a","Path":"a.html"}`+"\n"+`{"URL":"http://exa`), 0644))
+
+	fs, err := store.NewFileStore(rootDir, "b")
+	assert.NoError(t, err)
+	assert.NoError(t, fs.IndexURL("http://example.com/b", fs.HtmlDocPath()))
+
+	// Entries put later are not merged into the torn line.
+	content, err := os.ReadFile(indexPath)
+	assert.NoError(t, err)
+	assert.Equal(t, `{"URL":"http://example.com/a","Path":"a.html"}`+"\n"+
+		`{"URL":"http://example.com/b","Path":"b.html"}`+"\n", string(content))
+}
+
+type failingReader struct{}
+
+func (failingReader) Read(p []byte) (int, error) {
+	return 0, errors.New("connection reset")
+}
+
+func TestSaveAssetAtomic(t *testing.T) {
+	rootDir := t.TempDir()
+	fs, err := store.NewFileStore(rootDir, "page")
+	assert.NoError(t, err)
+
+	assetURL, _ := url.Parse("http://x/img/a.png")
+	as := &types.EmbeddedAsset{
+		AbsURL:     assetURL,
+		DataReader: io.MultiReader(strings.NewReader("partial"), failingReader{}),
+	}
+
+	// Partially downloaded assets are never left behind.
+	assert.Error(t, fs.SaveAsset(as))
+
+	entries, err := os.ReadDir(filepath.Dir(fs.AssetFilePath(as)))
+	assert.NoError(t, err)
+	assert.Empty(t, entries)
+
+	as.DataReader = strings.NewReader("png")
+	assert.NoError(t, fs.SaveAsset(as))
+
+	content, err := os.ReadFile(fs.AssetFilePath(as))
+	assert.NoError(t, err)
+	assert.Equal(t, "png", string(content))
+}
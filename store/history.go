@@ -0,0 +1,267 @@
+package store
+
+import (
+	"encoding/json"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/kennygrant/sanitize"
+	"github.com/pkg/errors"
+	"github.com/wanliqun/web-fetcher/types"
+)
+
+var (
+	// Guards the read-modify-write of history manifests.
+	manifestMu sync.Mutex
+)
+
+// HistoryVersion describes a version kept in the history.
+type HistoryVersion struct {
+	// Version: The snapshot version, a 14-digit UTC timestamp with a collision
+	// suffix such as `-1` if fetched more than once within the same second.
+	Version string
+	// FetchedAt: The time the version was fetched.
+	FetchedAt time.Time
+	// ContentHash: The content hash of the version.
+	ContentHash string `json:",omitempty"`
+}
+
+// HistoryManifest lists all the versions kept in the history of a document.
+type HistoryManifest struct {
+	// Versions in ascending order.
+	Versions []*HistoryVersion
+}
+
+// RetentionPolicy determines which versions to be kept in the history. Versions
+// are kept if they satisfy all the non-zero rules.
+type RetentionPolicy struct {
+	// KeepLast keeps the last N versions if positive.
+	KeepLast int
+	// KeepFor keeps the versions fetched within the duration if positive.
+	KeepFor time.Duration
+}
+
+// VersionStore returns the file store of the version, the layout of which is
+// the same as the root file store but within the version directory.
+func (fs *FileStore) VersionStore(version string) *FileStore {
+	return &FileStore{rootDir: fs.VersionDir(version), docName: fs.docName}
+}
+
+// Version directory format: `${rootDir}/${docName}.versions/${version}`
+func (fs *FileStore) VersionDir(version string) string {
+	return filepath.Join(fs.snapshotDir(), sanitize.BaseName(version))
+}
+
+// Manifest file path format: `${rootDir}/${docName}.versions/manifest.json`
+func (fs *FileStore) ManifestFilePath() string {
+	return filepath.Join(fs.snapshotDir(), "manifest.json")
+}
+
+// ArchiveVersion copies the document, metadata and readable content files into
+// the version directory, and adds the version to the history manifest.
+func (fs *FileStore) ArchiveVersion(metadata *types.Metadata) error {
+	if len(metadata.Version) == 0 {
+		return errors.New("missing version")
+	}
+
+	vfs := fs.VersionStore(metadata.Version)
+	if err := os.MkdirAll(vfs.rootDir, 0755); err != nil {
+		return errors.WithMessage(err, "failed to create directory")
+	}
+
+	if err := copyDocFiles(fs, vfs); err != nil {
+		return errors.WithMessage(err, "failed to copy files")
+	}
+
+	manifestMu.Lock()
+	defer manifestMu.Unlock()
+
+	manifest, err := fs.LoadManifest()
+	if err != nil {
+		return errors.WithMessage(err, "failed to load manifest")
+	}
+
+	version := &HistoryVersion{
+		Version:     metadata.Version,
+		FetchedAt:   metadata.FetchedAt,
+		ContentHash: metadata.ContentHash,
+	}
+
+	// Replace the version if exists, otherwise append it.
+	i := sort.Search(len(manifest.Versions), func(i int) bool {
+		return manifest.Versions[i].Version >= version.Version
+	})
+	if i < len(manifest.Versions) && manifest.Versions[i].Version == version.Version {
+		manifest.Versions[i] = version
+	} else {
+		manifest.Versions = append(manifest.Versions[:i],
+			append([]*HistoryVersion{version}, manifest.Versions[i:]...)...)
+	}
+
+	return fs.saveManifest(manifest)
+}
+
+// LoadManifest loads the history manifest, an empty manifest is returned if
+// there is no history yet.
+func (fs *FileStore) LoadManifest() (*HistoryManifest, error) {
+	data, err := os.ReadFile(fs.ManifestFilePath())
+	if os.IsNotExist(err) {
+		return &HistoryManifest{}, nil
+	}
+
+	if err != nil {
+		return nil, errors.WithMessage(err, "failed to read file")
+	}
+
+	var manifest HistoryManifest
+	if err := json.Unmarshal(data, &manifest); err != nil {
+		return nil, errors.WithMessage(err, "JSON unmarshal error")
+	}
+
+	return &manifest, nil
+}
+
+func (fs *FileStore) saveManifest(manifest *HistoryManifest) error {
+	content, err := json.MarshalIndent(manifest, "", "  ")
+	if err != nil {
+		return errors.WithMessage(err, "JSON marshal error")
+	}
+
+	return writeFileBytesAtomic(fs.ManifestFilePath(), content)
+}
+
+// ListVersions lists the versions kept in the history in ascending order.
+func (fs *FileStore) ListVersions() ([]*HistoryVersion, error) {
+	manifest, err := fs.LoadManifest()
+	if err != nil {
+		return nil, err
+	}
+
+	return manifest.Versions, nil
+}
+
+// VersionAt returns the latest version fetched at or before the point in time,
+// nil is returned if there is no such version.
+func (fs *FileStore) VersionAt(t time.Time) (*HistoryVersion, error) {
+	versions, err := fs.ListVersions()
+	if err != nil {
+		return nil, err
+	}
+
+	for i := len(versions) - 1; i >= 0; i-- {
+		if !versions[i].FetchedAt.After(t) {
+			return versions[i], nil
+		}
+	}
+
+	return nil, nil
+}
+
+// RestoreVersion restores the document, metadata and readable content files of
+// the version to the root file store.
+func (fs *FileStore) RestoreVersion(version string) error {
+	vfs := fs.VersionStore(version)
+	if _, err := os.Stat(vfs.MetadataFilePath()); err != nil {
+		return errors.WithMessagef(err, "version %v not found", version)
+	}
+
+	return copyDocFiles(vfs, fs)
+}
+
+// PruneVersions removes the versions not satisfying the retention policy from
+// the history, and returns the removed versions.
+func (fs *FileStore) PruneVersions(policy RetentionPolicy) ([]string, error) {
+	if policy.KeepLast <= 0 && policy.KeepFor <= 0 {
+		return nil, nil
+	}
+
+	manifestMu.Lock()
+	defer manifestMu.Unlock()
+
+	manifest, err := fs.LoadManifest()
+	if err != nil {
+		return nil, errors.WithMessage(err, "failed to load manifest")
+	}
+
+	var kept []*HistoryVersion
+	var pruned []string
+
+	deadline := time.Now().Add(-policy.KeepFor)
+	for i, v := range manifest.Versions {
+		keep := true
+		if policy.KeepLast > 0 && i < len(manifest.Versions)-policy.KeepLast {
+			keep = false
+		}
+		if policy.KeepFor > 0 && v.FetchedAt.Before(deadline) {
+			keep = false
+		}
+
+		if keep {
+			kept = append(kept, v)
+			continue
+		}
+
+		if err := os.RemoveAll(fs.VersionDir(v.Version)); err != nil {
+			return pruned, errors.WithMessage(err, "failed to remove version directory")
+		}
+
+		snapshotFiles := append(fs.contentSnapshotFiles(v.Version), fs.SnapshotFilePath(v.Version))
+		for _, filePath := range snapshotFiles {
+			if err := os.Remove(filePath); err != nil && !os.IsNotExist(err) {
+				return pruned, errors.WithMessage(err, "failed to remove snapshot file")
+			}
+		}
+
+		pruned = append(pruned, v.Version)
+	}
+
+	manifest.Versions = kept
+	return pruned, fs.saveManifest(manifest)
+}
+
+// copyDocFiles copies the document, metadata and readable content files from one
+// file store to another, missing files are skipped. The content file of non-HTML
+// resources is copied by the content type of the metadata.
+func copyDocFiles(from, to *FileStore) error {
+	pairs := [][2]string{
+		{from.HtmlDocPath(), to.HtmlDocPath()},
+		{from.MetadataFilePath(), to.MetadataFilePath()},
+		{from.MarkdownFilePath(), to.MarkdownFilePath()},
+		{from.TextFilePath(), to.TextFilePath()},
+	}
+
+	metadata, err := from.LoadMetadata()
+	if err != nil {
+		return errors.WithMessage(err, "failed to load metadata")
+	}
+
+	if metadata != nil && !IsHTMLType(metadata.ContentType) {
+		ext := ContentExtension(metadata.ContentType)
+		pairs = append(pairs, [2]string{from.ContentFilePath(ext), to.ContentFilePath(ext)})
+	}
+
+	for _, pair := range pairs {
+		if err := copyFile(pair[0], pair[1]); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func copyFile(src, dst string) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	return writeFileAtomic(dst, func(w io.Writer) error {
+		_, err := io.Copy(w, in)
+		return err
+	})
+}
@@ -0,0 +1,94 @@
+package store_test
+
+import (
+	"os"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/wanliqun/web-fetcher/store"
+	"github.com/wanliqun/web-fetcher/types"
+)
+
+func TestHistory(t *testing.T) {
+	fs, err := store.NewFileStore(t.TempDir(), "example.com/page")
+	assert.NoError(t, err)
+
+	now := time.Now()
+	fetchedAts := []time.Time{now.Add(-72 * time.Hour), now.Add(-time.Hour), now}
+
+	var versions []string
+	for i, fetchedAt := range fetchedAts {
+		metadata := &types.Metadata{
+			NumLinks:  i,
+			FetchedAt: fetchedAt,
+			Version:   store.SnapshotVersion(fetchedAt),
+		}
+		versions = append(versions, metadata.Version)
+
+		assert.NoError(t, fs.SaveMetadata(metadata))
+		assert.NoError(t, os.WriteFile(fs.HtmlDocPath(), []byte(metadata.Version), 0644))
+		assert.NoError(t, fs.ArchiveVersion(metadata))
+	}
+
+	listed, err := fs.ListVersions()
+	assert.NoError(t, err)
+	assert.Len(t, listed, 3)
+
+	v, err := fs.VersionAt(now.Add(-30 * time.Minute))
+	assert.NoError(t, err)
+	assert.Equal(t, versions[1], v.Version)
+
+	v, err = fs.VersionAt(now.Add(-100 * time.Hour))
+	assert.NoError(t, err)
+	assert.Nil(t, v)
+
+	// Restore the first version.
+	assert.NoError(t, fs.RestoreVersion(versions[0]))
+	content, err := os.ReadFile(fs.HtmlDocPath())
+	assert.NoError(t, err)
+	assert.Equal(t, versions[0], string(content))
+
+	metadata, err := fs.LoadMetadata()
+	assert.NoError(t, err)
+	assert.Equal(t, 0, metadata.NumLinks)
+
+	// Prune the versions older than 1 day.
+	pruned, err := fs.PruneVersions(store.RetentionPolicy{KeepFor: 24 * time.Hour})
+	assert.NoError(t, err)
+	assert.Equal(t, versions[:1], pruned)
+
+	_, err = os.Stat(fs.VersionDir(versions[0]))
+	assert.True(t, os.IsNotExist(err))
+
+	// Prune all but the last version.
+	pruned, err = fs.PruneVersions(store.RetentionPolicy{KeepLast: 1})
+	assert.NoError(t, err)
+	assert.Equal(t, versions[1:2], pruned)
+
+	listed, err = fs.ListVersions()
+	assert.NoError(t, err)
+	assert.Len(t, listed, 1)
+	assert.Equal(t, versions[2], listed[0].Version)
+}
+
+func TestNewVersion(t *testing.T) {
+	fs, err := store.NewFileStore(t.TempDir(), "example.com/page")
+	assert.NoError(t, err)
+
+	now := time.Now()
+	version := store.SnapshotVersion(now)
+	assert.Equal(t, version, fs.NewVersion(now))
+
+	// Versions fetched within the same second never overwrite each other.
+	assert.NoError(t, fs.SaveSnapshot(version, []byte("v0")))
+	assert.Equal(t, version+"-1", fs.NewVersion(now))
+
+	assert.NoError(t, fs.ArchiveVersion(&types.Metadata{FetchedAt: now, Version: version + "-1"}))
+	assert.Equal(t, version+"-2", fs.NewVersion(now))
+
+	assert.NoError(t, fs.SaveSnapshot(version+"-2", []byte("v2")))
+	snapshots, err := fs.ListSnapshots()
+	assert.NoError(t, err)
+	assert.Equal(t, []string{version, version + "-2"}, snapshots)
+}
@@ -0,0 +1,182 @@
+package store
+
+import (
+	"bufio"
+	"encoding/json"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+)
+
+// Index file name within the store root directory.
+const indexFileName = "index.jsonl"
+
+var (
+	// Guards the loaded indexes keyed by the index file path.
+	indexesMu sync.Mutex
+	indexes   = make(map[string]*urlIndex)
+)
+
+// IndexEntry maps a web page URL to the path of its document file.
+type IndexEntry struct {
+	// URL: The web page URL.
+	URL string
+	// Path: The document file path relative to the store root directory, such
+	// as the HTML document or the content file of non-HTML resources.
+	Path string
+}
+
+// urlIndex is the append-only URL to path index file within the store root
+// directory, the latest entry of each URL wins.
+type urlIndex struct {
+	mu       sync.Mutex
+	filePath string
+	// Loaded paths keyed by the URL.
+	paths map[string]string
+}
+
+// IndexFilePath returns the URL to path index file path of the root directory.
+func IndexFilePath(rootDir string) string {
+	return filepath.Join(ResolveRootDir(rootDir), indexFileName)
+}
+
+// LoadIndex loads the URL to path index of the root directory.
+func LoadIndex(rootDir string) (map[string]string, error) {
+	idx, err := openIndex(rootDir)
+	if err != nil {
+		return nil, err
+	}
+
+	idx.mu.Lock()
+	defer idx.mu.Unlock()
+
+	paths := make(map[string]string, len(idx.paths))
+	for url, path := range idx.paths {
+		paths[url] = path
+	}
+
+	return paths, nil
+}
+
+// IndexURL records the document file path of the web page URL into the index
+// file of the store root directory, unless already recorded.
+func (fs *FileStore) IndexURL(url, docPath string) error {
+	relPath, err := filepath.Rel(fs.rootDir, docPath)
+	if err != nil || strings.HasPrefix(relPath, "..") {
+		return errors.Errorf("document %v not within the store", docPath)
+	}
+
+	idx, err := openIndex(fs.rootDir)
+	if err != nil {
+		return err
+	}
+
+	return idx.put(&IndexEntry{URL: url, Path: filepath.ToSlash(relPath)})
+}
+
+// openIndex opens the index of the root directory, which is loaded once and
+// shared within the process.
+func openIndex(rootDir string) (*urlIndex, error) {
+	filePath := IndexFilePath(rootDir)
+	if absPath, err := filepath.Abs(filePath); err == nil {
+		filePath = absPath
+	}
+
+	indexesMu.Lock()
+	defer indexesMu.Unlock()
+
+	if idx, ok := indexes[filePath]; ok {
+		return idx, nil
+	}
+
+	idx := &urlIndex{filePath: filePath, paths: make(map[string]string)}
+	if err := idx.load(); err != nil {
+		return nil, errors.WithMessage(err, "failed to load index file")
+	}
+	indexes[filePath] = idx
+
+	return idx, nil
+}
+
+// load loads the index file, and truncates the half written last line if any
+// due to crash, so that the entries put later won't be merged into it.
+func (idx *urlIndex) load() error {
+	file, err := os.OpenFile(idx.filePath, os.O_RDWR, 0644)
+	if os.IsNotExist(err) {
+		return nil
+	}
+
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	reader := bufio.NewReader(file)
+
+	// Offset of the end of the last complete line.
+	var offset int64
+	for {
+		line, err := reader.ReadBytes('\n')
+		if err == io.EOF {
+			if len(line) > 0 {
+				logrus.WithField("offset", offset).Warn("Half written index entry truncated.")
+				return file.Truncate(offset)
+			}
+			return nil
+		}
+
+		if err != nil {
+			return err
+		}
+
+		offset += int64(len(line))
+
+		var entry IndexEntry
+		if err := json.Unmarshal(line, &entry); err != nil {
+			logrus.WithError(err).Warn("Corrupted index entry skipped.")
+			continue
+		}
+
+		idx.paths[entry.URL] = entry.Path
+	}
+}
+
+func (idx *urlIndex) put(entry *IndexEntry) error {
+	idx.mu.Lock()
+	defer idx.mu.Unlock()
+
+	if path, ok := idx.paths[entry.URL]; ok && path == entry.Path {
+		return nil
+	}
+
+	data, err := json.Marshal(entry)
+	if err != nil {
+		return errors.WithMessage(err, "JSON marshal error")
+	}
+
+	if err := os.MkdirAll(filepath.Dir(idx.filePath), 0755); err != nil {
+		return errors.WithMessage(err, "failed to create directory")
+	}
+
+	file, err := os.OpenFile(idx.filePath, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return errors.WithMessage(err, "failed to open index file")
+	}
+	defer file.Close()
+
+	if _, err := file.Write(append(data, '\n')); err != nil {
+		return errors.WithMessage(err, "failed to write index file")
+	}
+
+	if err := file.Sync(); err != nil {
+		return errors.WithMessage(err, "failed to sync index file")
+	}
+
+	idx.paths[entry.URL] = entry.Path
+	return nil
+}
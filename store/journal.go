@@ -0,0 +1,186 @@
+package store
+
+import (
+	"bufio"
+	"encoding/json"
+	"io"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+	"github.com/wanliqun/web-fetcher/types"
+)
+
+// JournalState is the state of a fetch request within the journal.
+type JournalState string
+
+const (
+	JournalStatePending    JournalState = "pending"
+	JournalStateInProgress JournalState = "in-progress"
+	JournalStateDone       JournalState = "done"
+	JournalStateFailed     JournalState = "failed"
+)
+
+// JournalEntry is a state transition record of a fetch request.
+type JournalEntry struct {
+	// URL: The web page URL of the fetch request.
+	URL string
+	// State: The state transitioned to.
+	State JournalState
+	// Request: The fetch request, only recorded for the pending state.
+	Request *types.FetchRequest `json:",omitempty"`
+	// Error: The fetch error message for the failed state.
+	Error string `json:",omitempty"`
+	// Time: The time the state transitioned at.
+	Time time.Time
+}
+
+// Journal is an append-only journal file that durably tracks the states of fetch
+// requests, so that an interrupted run can be resumed after crash.
+type Journal struct {
+	mu   sync.Mutex
+	file *os.File
+	// Latest entry of each URL replayed from the journal file.
+	entries map[string]*JournalEntry
+	// URLs in the order of being first recorded.
+	urls []string
+}
+
+// OpenJournal opens the journal file. The journal file will be replayed to
+// restore the states of previous run if resume is true, otherwise truncated.
+func OpenJournal(filePath string, resume bool) (*Journal, error) {
+	flag := os.O_CREATE | os.O_RDWR | os.O_APPEND
+	if !resume {
+		flag |= os.O_TRUNC
+	}
+
+	file, err := os.OpenFile(filePath, flag, 0644)
+	if err != nil {
+		return nil, errors.WithMessage(err, "failed to open journal file")
+	}
+
+	j := &Journal{file: file, entries: make(map[string]*JournalEntry)}
+	if err := j.replay(); err != nil {
+		file.Close()
+		return nil, errors.WithMessage(err, "failed to replay journal file")
+	}
+
+	return j, nil
+}
+
+// replay replays the journal file, and truncates the half written last line if
+// any due to crash, so that the entries recorded later won't be merged into it.
+func (j *Journal) replay() error {
+	reader := bufio.NewReader(j.file)
+
+	// Offset of the end of the last complete line.
+	var offset int64
+	for {
+		line, err := reader.ReadBytes('\n')
+		if err == io.EOF {
+			if len(line) > 0 {
+				logrus.WithField("offset", offset).Warn("Half written journal entry truncated.")
+				return j.file.Truncate(offset)
+			}
+			return nil
+		}
+
+		if err != nil {
+			return err
+		}
+
+		offset += int64(len(line))
+
+		var entry JournalEntry
+		if err := json.Unmarshal(line, &entry); err != nil {
+			logrus.WithError(err).Warn("Corrupted journal entry skipped.")
+			continue
+		}
+
+		j.apply(&entry)
+	}
+}
+
+func (j *Journal) apply(entry *JournalEntry) {
+	prev, ok := j.entries[entry.URL]
+	if !ok {
+		j.urls = append(j.urls, entry.URL)
+	}
+
+	// Keep the fetch request which is only recorded for the pending state.
+	if entry.Request == nil && prev != nil {
+		entry.Request = prev.Request
+	}
+
+	j.entries[entry.URL] = entry
+}
+
+// Record appends a state transition of the fetch request to the journal file.
+func (j *Journal) Record(entry *JournalEntry) error {
+	if entry.Time.IsZero() {
+		entry.Time = time.Now()
+	}
+
+	data, err := json.Marshal(entry)
+	if err != nil {
+		return errors.WithMessage(err, "JSON marshal error")
+	}
+
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	if _, err := j.file.Write(append(data, '\n')); err != nil {
+		return errors.WithMessage(err, "failed to write journal file")
+	}
+
+	if err := j.file.Sync(); err != nil {
+		return errors.WithMessage(err, "failed to sync journal file")
+	}
+
+	j.apply(entry)
+	return nil
+}
+
+// State returns the latest state of the URL within the journal.
+func (j *Journal) State(url string) (JournalState, bool) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	if entry, ok := j.entries[url]; ok {
+		return entry.State, true
+	}
+
+	return "", false
+}
+
+// Unfinished returns the fetch requests that are not done yet, in the order of
+// being first recorded.
+func (j *Journal) Unfinished() (reqs []*types.FetchRequest) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	for _, url := range j.urls {
+		entry := j.entries[url]
+		if entry.State == JournalStateDone {
+			continue
+		}
+
+		req := entry.Request
+		if req == nil {
+			req = &types.FetchRequest{URL: url}
+		}
+		reqs = append(reqs, req)
+	}
+
+	return reqs
+}
+
+// Close closes the journal file.
+func (j *Journal) Close() error {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	return j.file.Close()
+}
@@ -0,0 +1,82 @@
+package store_test
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/wanliqun/web-fetcher/store"
+	"github.com/wanliqun/web-fetcher/types"
+)
+
+func TestJournalResume(t *testing.T) {
+	journalPath := filepath.Join(t.TempDir(), "journal.jsonl")
+
+	j, err := store.OpenJournal(journalPath, false)
+	assert.NoError(t, err)
+
+	for _, url := range []string{"http://a.com", "http://b.com", "http://c.com"} {
+		assert.NoError(t, j.Record(&store.JournalEntry{
+			URL:     url,
+			State:   store.JournalStatePending,
+			Request: &types.FetchRequest{URL: url, Tags: []string{"test"}},
+		}))
+	}
+
+	assert.NoError(t, j.Record(&store.JournalEntry{URL: "http://a.com", State: store.JournalStateDone}))
+	assert.NoError(t, j.Record(&store.JournalEntry{URL: "http://b.com", State: store.JournalStateInProgress}))
+	assert.NoError(t, j.Close())
+
+	// Simulate a half written entry due to crash.
+	file, err := os.OpenFile(journalPath, os.O_APPEND|os.O_WRONLY, 0644)
+	assert.NoError(t, err)
+	_, err = file.WriteString(`{"URL":"http://c.com","Sta`)
+	assert.NoError(t, err)
+	assert.NoError(t, file.Close())
+
+	j, err = store.OpenJournal(journalPath, true)
+	assert.NoError(t, err)
+	defer j.Close()
+
+	state, ok := j.State("http://a.com")
+	assert.True(t, ok)
+	assert.Equal(t, store.JournalStateDone, state)
+
+	unfinished := j.Unfinished()
+	assert.Len(t, unfinished, 2)
+	assert.Equal(t, "http://b.com", unfinished[0].URL)
+	assert.Equal(t, []string{"test"}, unfinished[0].Tags)
+	assert.Equal(t, "http://c.com", unfinished[1].URL)
+}
+
+func TestJournalRecordAfterTornLine(t *testing.T) {
+	journalPath := filepath.Join(t.TempDir(), "journal.jsonl")
+
+	j, err := store.OpenJournal(journalPath, false)
+	assert.NoError(t, err)
+	assert.NoError(t, j.Record(&store.JournalEntry{URL: "http://a.com", State: store.JournalStatePending}))
+	assert.NoError(t, j.Close())
+
+	// Simulate a half written entry due to crash.
+	file, err := os.OpenFile(journalPath, os.O_APPEND|os.O_WRONLY, 0644)
+	assert.NoError(t, err)
+	_, err = file.WriteString(`{"URL":"http://a.com","Sta`)
+	assert.NoError(t, err)
+	assert.NoError(t, file.Close())
+
+	// Entries recorded after resuming are not merged into the torn line.
+	j, err = store.OpenJournal(journalPath, true)
+	assert.NoError(t, err)
+	assert.NoError(t, j.Record(&store.JournalEntry{URL: "http://a.com", State: store.JournalStateDone}))
+	assert.NoError(t, j.Close())
+
+	j, err = store.OpenJournal(journalPath, true)
+	assert.NoError(t, err)
+	defer j.Close()
+
+	state, ok := j.State("http://a.com")
+	assert.True(t, ok)
+	assert.Equal(t, store.JournalStateDone, state)
+	assert.Empty(t, j.Unfinished())
+}
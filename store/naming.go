@@ -0,0 +1,152 @@
+package store
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"mime"
+	"path"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/kennygrant/sanitize"
+)
+
+const (
+	// Max length of the readable part of file names, leaving room for the hash
+	// and suffixes such as `.versions` within the 255-byte file name limit.
+	maxReadableNameLength = 200
+	// Length of the hash appended to document names in hex.
+	docNameHashLength = 12
+	// Default asset file name for the asset URLs ending with `/`.
+	defaultAssetFileName = "index"
+)
+
+// preferredExtensions are the preferred file extensions of the common asset
+// content types, which are ambiguous by the mime package.
+var preferredExtensions = map[string]string{
+	"image/jpeg":               ".jpg",
+	"image/png":                ".png",
+	"image/gif":                ".gif",
+	"image/webp":               ".webp",
+	"image/svg+xml":            ".svg",
+	"image/x-icon":             ".ico",
+	"image/vnd.microsoft.icon": ".ico",
+	"text/css":                 ".css",
+	"text/javascript":          ".js",
+	"application/javascript":   ".js",
+	"application/json":         ".json",
+	"text/html":                ".html",
+	"text/plain":               ".txt",
+	"font/woff":                ".woff",
+	"font/woff2":               ".woff2",
+}
+
+// DocName creates the collision-safe document name, which is the readable name
+// sanitized and truncated, followed by the short hash of the unique key such as
+// the web page URL, eg., `example.com-a-b-3f2a9c01d4e5`.
+func DocName(readable, key string) string {
+	hash := sha256.Sum256([]byte(key))
+	suffix := hex.EncodeToString(hash[:])[:docNameHashLength]
+
+	readable = truncateName(sanitize.BaseName(readable), maxReadableNameLength)
+	if len(readable) == 0 {
+		return suffix
+	}
+
+	return readable + "-" + suffix
+}
+
+// truncateName truncates the name to the max length in bytes, without breaking
+// UTF-8 characters.
+func truncateName(name string, maxLen int) string {
+	if len(name) <= maxLen {
+		return name
+	}
+
+	name = name[:maxLen]
+	for len(name) > 0 && !utf8.ValidString(name) {
+		name = name[:len(name)-1]
+	}
+
+	return name
+}
+
+// sanitizeAssetDir sanitizes the directory segments of the asset URL path, with
+// the empty, `.` and `..` segments dropped so that the asset files never escape
+// the document directory.
+func sanitizeAssetDir(dir string) []string {
+	var segments []string
+	for _, segment := range strings.Split(dir, "/") {
+		if segment == "" || segment == "." || segment == ".." {
+			continue
+		}
+
+		if segment = sanitize.Name(segment); segment == "" || segment == "." || segment == ".." {
+			continue
+		}
+		segments = append(segments, truncateName(segment, maxReadableNameLength))
+	}
+
+	return segments
+}
+
+// sanitizeAssetFileName sanitizes the asset file name, with the extension
+// inferred from the content type if missing.
+func sanitizeAssetFileName(file, contentType string) string {
+	file = sanitize.Name(file)
+	if file == "." || file == ".." {
+		file = ""
+	}
+
+	ext := path.Ext(file)
+	if len(ext) == 0 {
+		ext = ExtensionByType(contentType)
+	}
+
+	base := strings.TrimSuffix(file, ext)
+	if len(base) == 0 {
+		base = defaultAssetFileName
+	}
+
+	return truncateName(base, maxReadableNameLength) + ext
+}
+
+// ContentExtension returns the file extension of the non-HTML content stored for
+// the content type, which is `.bin` for binary or unknown content types.
+func ContentExtension(contentType string) string {
+	mediaType, _, _ := mime.ParseMediaType(contentType)
+	if ext := ExtensionByType(contentType); len(ext) > 0 && mediaType != "application/octet-stream" {
+		return ext
+	}
+
+	return ".bin"
+}
+
+// IsHTMLType checks if the content type is HTML, which is assumed if empty.
+func IsHTMLType(contentType string) bool {
+	mediaType, _, _ := mime.ParseMediaType(contentType)
+	switch strings.ToLower(mediaType) {
+	case "", "text/html", "application/xhtml+xml":
+		return true
+	}
+
+	return false
+}
+
+// ExtensionByType returns the file extension of the content type, empty if unknown.
+func ExtensionByType(contentType string) string {
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return ""
+	}
+
+	if ext, ok := preferredExtensions[mediaType]; ok {
+		return ext
+	}
+
+	if exts, _ := mime.ExtensionsByType(mediaType); len(exts) > 0 {
+		return exts[0]
+	}
+
+	return ""
+}
@@ -0,0 +1,70 @@
+package types
+
+import (
+	"net/http"
+	"time"
+)
+
+var (
+	// HTTP response headers to be included in the fetch record.
+	recordHeaderKeys = []string{
+		"Content-Type", "Content-Length", "Last-Modified", "ETag", "Cache-Control", "Server",
+	}
+)
+
+// FetchRecord is the machine-readable record of a fetch result.
+type FetchRecord struct {
+	URL         string
+	Tags        []string          `json:",omitempty"`
+	FinalURL    string            `json:",omitempty"`
+	Status      int               `json:",omitempty"`
+	Headers     map[string]string `json:",omitempty"`
+	Metadata    *Metadata         `json:",omitempty"`
+	Files       *StoredFiles      `json:",omitempty"`
+	StartedAt   time.Time
+	FinishedAt  time.Time
+	DurationMs  int64
+	Timings     *Timings `json:",omitempty"`
+	DuplicateOf string   `json:",omitempty"`
+	SkippedBy   string   `json:",omitempty"`
+	Error       string   `json:",omitempty"`
+}
+
+// NewFetchRecord creates the machine-readable record of the fetch result.
+func NewFetchRecord(result *FetchResult) *FetchRecord {
+	record := &FetchRecord{
+		URL:         result.URL,
+		Metadata:    result.Metadata,
+		Files:       result.Files,
+		StartedAt:   result.StartedAt,
+		FinishedAt:  result.FinishedAt,
+		DurationMs:  result.FinishedAt.Sub(result.StartedAt).Milliseconds(),
+		Timings:     result.Timings,
+		DuplicateOf: result.DuplicateOf,
+		SkippedBy:   result.SkippedBy,
+	}
+
+	if result.Request != nil {
+		record.Tags = result.Request.Tags
+	}
+
+	if resp := result.Response; resp != nil {
+		record.Status = resp.StatusCode
+		if resp.Request != nil {
+			record.FinalURL = resp.Request.URL.String()
+		}
+
+		record.Headers = make(map[string]string)
+		for _, key := range recordHeaderKeys {
+			if val := resp.Header.Get(key); len(val) > 0 {
+				record.Headers[http.CanonicalHeaderKey(key)] = val
+			}
+		}
+	}
+
+	if result.Err != nil {
+		record.Error = result.Err.Error()
+	}
+
+	return record
+}
@@ -1,7 +1,9 @@
 package types
 
 import (
+	"encoding/json"
 	"io"
+	"math"
 	"net/http"
 	"net/url"
 	"time"
@@ -9,14 +11,118 @@ import (
 
 // Metadata describes the structure and information of an HTML page.
 type Metadata struct {
+	// URL: The final URL of the HTML page after redirection.
+	URL string `json:",omitempty"`
 	// NumLinks: The total number of links found within the HTML page.
 	NumLinks int
 	// NumImages: The total number of images found within the HTML page.
 	NumImages int
+	// NumWords: The total number of words within the readable main content,
+	// only available if readability extraction is turned on.
+	NumWords int `json:",omitempty"`
+	// ContentHash: The SHA-256 hash of the HTML page content in hex format.
+	ContentHash string `json:",omitempty"`
+	// ContentChanged: Whether the content hash changed since the last fetch.
+	ContentChanged bool `json:",omitempty"`
+	// SelectorHashes: The content hashes of the elements matched by each of
+	// the tracked CSS selectors.
+	SelectorHashes map[string]string `json:",omitempty"`
+	// ChangedSelectors: The tracked CSS selectors whose content hashes changed
+	// since the last fetch.
+	ChangedSelectors []string `json:",omitempty"`
+	// Version: The snapshot version of the HTML page if snapshot is turned on.
+	Version string `json:",omitempty"`
 	// LastFetchedAt: The last time the HTML page was fetched.
 	LastFetchedAt *time.Time
 	// FetchedAt: The current time the HTML page was fetched.
 	FetchedAt time.Time
+	// Timings: The timing breakdown of the current fetch.
+	Timings *Timings `json:",omitempty"`
+	// ContentType: The media type of the content, such as `text/html` or
+	// `application/pdf`.
+	ContentType string `json:",omitempty"`
+	// Fields: The custom fields extracted by the extraction rules, each valued
+	// by a string or a list of strings.
+	Fields map[string]any `json:",omitempty"`
+	// Annotations: The annotations of the fetch result by the middlewares.
+	Annotations map[string]string `json:",omitempty"`
+}
+
+// Timings is the timing breakdown of fetching an HTML page, encoded as JSON in
+// milliseconds such as `TTFBMs`, which are fractional to keep the precision of
+// the sub-millisecond timings.
+type Timings struct {
+	// DNS: The time of the DNS lookup.
+	DNS time.Duration
+	// Connect: The time of establishing the TCP connection.
+	Connect time.Duration
+	// TLS: The time of the TLS handshake.
+	TLS time.Duration
+	// TTFB: The time from starting the request to the first response byte,
+	// including the DNS lookup, connecting and TLS handshake.
+	TTFB time.Duration
+	// Download: The time of reading the response body.
+	Download time.Duration
+	// Parse: The time of parsing the HTML document.
+	Parse time.Duration
+	// Assets: The time of downloading and saving the assets.
+	Assets time.Duration
+	// Store: The total time of the file store writes, including the assets.
+	Store time.Duration
+}
+
+// timingsJSON is the JSON encoding of the timings in milliseconds.
+type timingsJSON struct {
+	DNSMs      float64 `json:",omitempty"`
+	ConnectMs  float64 `json:",omitempty"`
+	TLSMs      float64 `json:",omitempty"`
+	TTFBMs     float64 `json:",omitempty"`
+	DownloadMs float64 `json:",omitempty"`
+	ParseMs    float64 `json:",omitempty"`
+	AssetsMs   float64 `json:",omitempty"`
+	StoreMs    float64 `json:",omitempty"`
+}
+
+// MarshalJSON implements the json.Marshaler interface.
+func (t Timings) MarshalJSON() ([]byte, error) {
+	return json.Marshal(&timingsJSON{
+		DNSMs:      toMs(t.DNS),
+		ConnectMs:  toMs(t.Connect),
+		TLSMs:      toMs(t.TLS),
+		TTFBMs:     toMs(t.TTFB),
+		DownloadMs: toMs(t.Download),
+		ParseMs:    toMs(t.Parse),
+		AssetsMs:   toMs(t.Assets),
+		StoreMs:    toMs(t.Store),
+	})
+}
+
+// UnmarshalJSON implements the json.Unmarshaler interface.
+func (t *Timings) UnmarshalJSON(data []byte) error {
+	var v timingsJSON
+	if err := json.Unmarshal(data, &v); err != nil {
+		return err
+	}
+
+	*t = Timings{
+		DNS:      fromMs(v.DNSMs),
+		Connect:  fromMs(v.ConnectMs),
+		TLS:      fromMs(v.TLSMs),
+		TTFB:     fromMs(v.TTFBMs),
+		Download: fromMs(v.DownloadMs),
+		Parse:    fromMs(v.ParseMs),
+		Assets:   fromMs(v.AssetsMs),
+		Store:    fromMs(v.StoreMs),
+	}
+	return nil
+}
+
+func toMs(d time.Duration) float64 {
+	return float64(d) / float64(time.Millisecond)
+}
+
+func fromMs(ms float64) time.Duration {
+	return time.Duration(math.Round(ms * float64(time.Millisecond)))
 }
 
 // EmbeddedAsset represents an embedded asset within an HTML page.
@@ -26,16 +132,93 @@ type EmbeddedAsset struct {
 	// DataReader: The io.ReadCloser interface provides methods to read
 	// the asset's data.
 	DataReader io.Reader
+	// ContentType: The content type of the asset once downloaded, which is
+	// used to infer the file extension if missing.
+	ContentType string
+}
+
+// ReadableContent represents the main content of an HTML page with the
+// boilerplate (such as navigation bars, sidebars and footers) stripped.
+type ReadableContent struct {
+	// Title: The title of the HTML page.
+	Title string
+	// Markdown: The main content formatted as Markdown.
+	Markdown string
+	// Text: The main content formatted as plain text.
+	Text string
+}
+
+// StoredFiles represents the paths of the files stored for an HTML page.
+type StoredFiles struct {
+	// HTML: The HTML document file path, only for HTML pages.
+	HTML string `json:",omitempty"`
+	// Content: The raw content file path, only for non-HTML resources.
+	Content string `json:",omitempty"`
+	// Metadata: The metadata file path.
+	Metadata string
+	// Markdown: The readable content Markdown file path if any.
+	Markdown string `json:",omitempty"`
+	// Text: The readable content plain text file path if any.
+	Text string `json:",omitempty"`
+	// Snapshot: The versioned snapshot file path if any.
+	Snapshot string `json:",omitempty"`
+	// Assets: The downloaded asset file paths if any.
+	Assets []string `json:",omitempty"`
+}
+
+// FetchRequest represents a request for fetching an HTML page, with overrides
+// on the fetcher configurations.
+type FetchRequest struct {
+	// URL: The web page URL.
+	URL string
+	// Headers: The extra HTTP headers sent along with the request.
+	Headers map[string]string `json:",omitempty"`
+	// Mirror: Overrides whether to download asset resources if set.
+	Mirror *bool `json:",omitempty"`
+	// Depth: The depth of links within the same domain host to be followed.
+	Depth int `json:",omitempty"`
+	// Tags: The user-defined tags to label the request.
+	Tags []string `json:",omitempty"`
 }
 
 // FetchResult represents the outcome of fetching an HTML page.
 type FetchResult struct {
 	// Web page URL
 	URL string
+	// Request which the fetch result is for.
+	Request *FetchRequest
 	// Metadata extracted from the HTML page.
 	Metadata *Metadata
+	// Files stored for the HTML page.
+	Files *StoredFiles
+	// The time the fetch started at.
+	StartedAt time.Time
+	// The time the fetch finished at.
+	FinishedAt time.Time
+	// Timing breakdown of the fetch.
+	Timings *Timings
+	// Total number of bytes downloaded, including the page and assets.
+	NumBytes int64
+	// Number of assets skipped due to external domain hosts.
+	NumSkippedAssets int
+	// Canonical URL of the web page already fetched, which the fetch is skipped
+	// as a duplicate of, such as redirected to or linked as canonical by the page.
+	DuplicateOf string
+	// Name of the middleware which vetoed the web page, which is skipped.
+	SkippedBy string
+	// Annotations by the middlewares, which are stored into the metadata if
+	// annotated before the web page is saved.
+	Annotations map[string]string
 	// HTTP response received from the fetch request.
 	Response *http.Response
 	// Fetch error if any.
 	Err error
 }
+
+// Annotate annotates the fetch result with the key and value.
+func (r *FetchResult) Annotate(key, value string) {
+	if r.Annotations == nil {
+		r.Annotations = make(map[string]string)
+	}
+	r.Annotations[key] = value
+}
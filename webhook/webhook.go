@@ -0,0 +1,288 @@
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+	"github.com/wanliqun/web-fetcher/types"
+)
+
+const (
+	// HTTP header of the webhook event type.
+	EventHeader = "X-Webhook-Event"
+	// HTTP header of the HMAC-SHA256 signature over the request body, in the
+	// format of `sha256=${hex}`.
+	SignatureHeader = "X-Webhook-Signature"
+)
+
+// Event is the type of webhook notification.
+type Event string
+
+const (
+	// EventFetchFailed is notified when a web page fails to be fetched.
+	EventFetchFailed Event = "fetch.failed"
+	// EventContentChanged is notified when the content hash of a web page
+	// changes since the last fetch.
+	EventContentChanged Event = "content.changed"
+	// EventSelectorChanged is notified when the content of the elements matched
+	// by tracked selectors changes since the last fetch.
+	EventSelectorChanged Event = "selector.changed"
+)
+
+// Payload is the JSON payload posted to the webhook URLs.
+type Payload struct {
+	Event Event
+	// The web page URL.
+	URL string
+	// The time the event occurred at.
+	Time time.Time
+	// The fetch error message for the failed event.
+	Error string `json:",omitempty"`
+	// The changed tracked selectors for the selector changed event.
+	Selectors []string `json:",omitempty"`
+	// The metadata of the web page for the changed events.
+	Metadata *types.Metadata `json:",omitempty"`
+	// The user-defined tags of the fetch request.
+	Tags []string `json:",omitempty"`
+}
+
+// Config configures the webhook notifier.
+type Config struct {
+	// URLs to post the notifications to.
+	URLs []string
+	// Secret to sign the request body with HMAC-SHA256, no signature if empty.
+	Secret string
+	// MaxRetries is the max number of retries upon failures.
+	MaxRetries int
+	// RetryInterval is the initial interval between retries, which doubles
+	// upon each retry.
+	RetryInterval time.Duration
+	// Timeout of each HTTP request.
+	Timeout time.Duration
+	// QueueSize is the max number of notifications pending to send, beyond which
+	// notifications are dropped.
+	QueueSize int
+}
+
+// Notifier posts webhook notifications upon fetch failures and content changes.
+// Notifications are queued and sent by a worker goroutine in the background, so
+// that slow webhooks never stall the fetching.
+type Notifier struct {
+	*Config
+
+	client *http.Client
+
+	mu     sync.RWMutex
+	closed bool
+	queue  chan *Payload
+	done   chan struct{}
+}
+
+// NewNotifier creates a webhook notifier, which must be closed to flush the
+// pending notifications.
+func NewNotifier(config *Config) *Notifier {
+	timeout := config.Timeout
+	if timeout <= 0 {
+		timeout = 10 * time.Second
+	}
+
+	queueSize := config.QueueSize
+	if queueSize <= 0 {
+		queueSize = 1000
+	}
+
+	n := &Notifier{
+		Config: config,
+		client: &http.Client{Timeout: timeout},
+		queue:  make(chan *Payload, queueSize),
+		done:   make(chan struct{}),
+	}
+
+	go n.run()
+
+	return n
+}
+
+// OnFetched queues the events of the fetch result to notify, it can be
+// registered as the fetched callback of fetchers.
+func (n *Notifier) OnFetched(result *types.FetchResult) {
+	n.mu.RLock()
+	defer n.mu.RUnlock()
+
+	for _, payload := range newPayloads(result) {
+		logger := logrus.WithFields(logrus.Fields{
+			"URL":   result.URL,
+			"event": payload.Event,
+		})
+
+		if n.closed {
+			logger.Warn("Webhook notification dropped since the notifier is closed.")
+			continue
+		}
+
+		select {
+		case n.queue <- payload:
+		default:
+			logger.Warn("Webhook notification dropped since the queue is full.")
+		}
+	}
+}
+
+// Close stops queueing notifications, and waits until the pending ones are sent.
+func (n *Notifier) Close() {
+	n.mu.Lock()
+	if !n.closed {
+		n.closed = true
+		close(n.queue)
+	}
+	n.mu.Unlock()
+
+	<-n.done
+}
+
+// run sends the queued notifications one by one until the queue is closed.
+func (n *Notifier) run() {
+	defer close(n.done)
+
+	for payload := range n.queue {
+		if err := n.Notify(context.Background(), payload); err != nil {
+			logrus.WithFields(logrus.Fields{
+				"URL":   payload.URL,
+				"event": payload.Event,
+			}).WithError(err).Error("Failed to notify webhook.")
+		}
+	}
+}
+
+// newPayloads creates the payloads of all events occurred for the fetch result.
+func newPayloads(result *types.FetchResult) (payloads []*Payload) {
+	newPayload := func(event Event) *Payload {
+		payload := &Payload{Event: event, URL: result.URL, Time: time.Now()}
+		if result.Request != nil {
+			payload.Tags = result.Request.Tags
+		}
+
+		return payload
+	}
+
+	if result.Err != nil {
+		payload := newPayload(EventFetchFailed)
+		payload.Error = result.Err.Error()
+		return append(payloads, payload)
+	}
+
+	if result.Metadata == nil {
+		return nil
+	}
+
+	if result.Metadata.ContentChanged {
+		payload := newPayload(EventContentChanged)
+		payload.Metadata = result.Metadata
+		payloads = append(payloads, payload)
+	}
+
+	if len(result.Metadata.ChangedSelectors) > 0 {
+		payload := newPayload(EventSelectorChanged)
+		payload.Metadata = result.Metadata
+		payload.Selectors = result.Metadata.ChangedSelectors
+		payloads = append(payloads, payload)
+	}
+
+	return payloads
+}
+
+// Notify posts the payload to all the webhook URLs with retries.
+func (n *Notifier) Notify(ctx context.Context, payload *Payload) error {
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return errors.WithMessage(err, "JSON marshal error")
+	}
+
+	var lastErr error
+	for _, webhookURL := range n.URLs {
+		if err := n.post(ctx, webhookURL, payload.Event, body); err != nil {
+			lastErr = errors.WithMessagef(err, "failed to post to %v", webhookURL)
+		}
+	}
+
+	return lastErr
+}
+
+// post posts the request body to the webhook URL, retrying upon network errors,
+// 5xx and 429 status codes.
+func (n *Notifier) post(ctx context.Context, webhookURL string, event Event, body []byte) error {
+	interval := n.RetryInterval
+	if interval <= 0 {
+		interval = time.Second
+	}
+
+	var err error
+	for attempt := 0; ; attempt++ {
+		var retryable bool
+		if retryable, err = n.doPost(ctx, webhookURL, event, body); err == nil {
+			return nil
+		}
+
+		if !retryable || attempt >= n.MaxRetries {
+			return err
+		}
+
+		logrus.WithFields(logrus.Fields{
+			"webhookURL": webhookURL,
+			"attempt":    attempt + 1,
+		}).WithError(err).Debug("Webhook notification retrying.")
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(interval):
+		}
+		interval *= 2
+	}
+}
+
+func (n *Notifier) doPost(
+	ctx context.Context, webhookURL string, event Event, body []byte) (retryable bool, err error) {
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, webhookURL, bytes.NewReader(body))
+	if err != nil {
+		return false, errors.WithMessage(err, "failed to create HTTP request")
+	}
+
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(EventHeader, string(event))
+	if len(n.Secret) > 0 {
+		req.Header.Set(SignatureHeader, "sha256="+Sign(n.Secret, body))
+	}
+
+	resp, err := n.client.Do(req)
+	if err != nil {
+		return true, errors.WithMessage(err, "failed to do HTTP request")
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, resp.Body)
+
+	if statusCode := resp.StatusCode; statusCode < 200 || statusCode > 299 {
+		retryable := statusCode >= 500 || statusCode == http.StatusTooManyRequests
+		return retryable, errors.Errorf("bad HTTP status code: %d", statusCode)
+	}
+
+	return false, nil
+}
+
+// Sign computes the HMAC-SHA256 signature of the body with the secret in hex format.
+func Sign(secret string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	return hex.EncodeToString(mac.Sum(nil))
+}
@@ -0,0 +1,124 @@
+package webhook_test
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/wanliqun/web-fetcher/types"
+	"github.com/wanliqun/web-fetcher/webhook"
+)
+
+func TestNotifierOnFetched(t *testing.T) {
+	const secret = "test-secret"
+
+	var numRequests atomic.Int32
+	payloads := make(chan *webhook.Payload, 10)
+
+	receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// Fail the first request to test retrying.
+		if numRequests.Add(1) == 1 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+
+		body, err := io.ReadAll(r.Body)
+		assert.NoError(t, err)
+		assert.Equal(t, "sha256="+webhook.Sign(secret, body), r.Header.Get(webhook.SignatureHeader))
+
+		var payload webhook.Payload
+		assert.NoError(t, json.Unmarshal(body, &payload))
+		assert.Equal(t, string(payload.Event), r.Header.Get(webhook.EventHeader))
+
+		payloads <- &payload
+	}))
+	defer receiver.Close()
+
+	notifier := webhook.NewNotifier(&webhook.Config{
+		URLs:          []string{receiver.URL},
+		Secret:        secret,
+		MaxRetries:    2,
+		RetryInterval: 10 * time.Millisecond,
+	})
+
+	notifier.OnFetched(&types.FetchResult{
+		URL: "http://example.com",
+		Err: errors.New("bad HTTP status code: 404"),
+	})
+
+	notifier.OnFetched(&types.FetchResult{
+		URL:     "http://example.com",
+		Request: &types.FetchRequest{URL: "http://example.com", Tags: []string{"pricing"}},
+		Metadata: &types.Metadata{
+			ContentChanged:   true,
+			ChangedSelectors: []string{".price"},
+		},
+	})
+
+	// No changes should be notified.
+	notifier.OnFetched(&types.FetchResult{URL: "http://example.com", Metadata: &types.Metadata{}})
+
+	// Pending notifications are flushed upon closing.
+	notifier.Close()
+	close(payloads)
+
+	var events []webhook.Event
+	for payload := range payloads {
+		events = append(events, payload.Event)
+
+		switch payload.Event {
+		case webhook.EventFetchFailed:
+			assert.Equal(t, "bad HTTP status code: 404", payload.Error)
+		case webhook.EventSelectorChanged:
+			assert.Equal(t, []string{".price"}, payload.Selectors)
+			assert.Equal(t, []string{"pricing"}, payload.Tags)
+		}
+	}
+
+	assert.Equal(t, []webhook.Event{
+		webhook.EventFetchFailed, webhook.EventContentChanged, webhook.EventSelectorChanged,
+	}, events)
+	assert.EqualValues(t, 4, numRequests.Load())
+}
+
+func TestNotifierQueue(t *testing.T) {
+	var numRequests atomic.Int32
+	unblock := make(chan struct{})
+
+	receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-unblock
+		numRequests.Add(1)
+	}))
+	defer receiver.Close()
+
+	notifier := webhook.NewNotifier(&webhook.Config{
+		URLs:      []string{receiver.URL},
+		QueueSize: 1,
+	})
+
+	failed := &types.FetchResult{URL: "http://example.com", Err: errors.New("timeout")}
+
+	// Notifications never block the fetching even if the webhook is stalled.
+	start := time.Now()
+	for i := 0; i < 5; i++ {
+		notifier.OnFetched(failed)
+	}
+	assert.Less(t, time.Since(start), time.Second)
+
+	close(unblock)
+	notifier.Close()
+
+	// The one in flight and the one queued are sent, while the others are dropped.
+	assert.LessOrEqual(t, numRequests.Load(), int32(2))
+	assert.GreaterOrEqual(t, numRequests.Load(), int32(1))
+
+	// Notifications after closing are dropped.
+	notifier.OnFetched(failed)
+	notifier.Close()
+}